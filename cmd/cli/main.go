@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"flag"
 	"log"
 
@@ -11,6 +12,7 @@ import (
 
 	"github.com/baibikov/jellydb/internal/cli"
 	"github.com/baibikov/jellydb/pkg/notifyctx"
+	"github.com/baibikov/jellydb/pkg/tlsconfig"
 )
 
 func main() {
@@ -27,18 +29,44 @@ func main() {
 
 type Flags struct {
 	addr string
+
+	tls           bool
+	tlsCA         string
+	tlsCert       string
+	tlsKey        string
+	tlsServerName string
 }
 
 func parse() (*Flags, error) {
 	var addr string
 	flag.StringVar(&addr, "addr", "", "an init")
 
+	var useTLS bool
+	flag.BoolVar(&useTLS, "tls", false, "connect by tls with the system roots, implied by the other tls flags")
+
+	var tlsCA string
+	flag.StringVar(&tlsCA, "tls-ca", "", "CA file to verify the server certificate")
+
+	var tlsCert string
+	flag.StringVar(&tlsCert, "tls-cert", "", "client certificate file for mTLS")
+
+	var tlsKey string
+	flag.StringVar(&tlsKey, "tls-key", "", "client key file for mTLS")
+
+	var tlsServerName string
+	flag.StringVar(&tlsServerName, "tls-server-name", "", "server name to verify the server certificate")
+
 	flag.Parse()
 	if addr == "" {
 		return nil, errors.New("addr is required param")
 	}
 	return &Flags{
-		addr: addr,
+		addr:          addr,
+		tls:           useTLS || tlsCA != "" || tlsCert != "" || tlsKey != "" || tlsServerName != "",
+		tlsCA:         tlsCA,
+		tlsCert:       tlsCert,
+		tlsKey:        tlsKey,
+		tlsServerName: tlsServerName,
 	}, nil
 }
 
@@ -47,8 +75,17 @@ func runApp(f *Flags) (err error) {
 	ctx, cancel := notifyctx.WrapExitContext(ctx)
 	defer cancel()
 
+	var tlsConfig *tls.Config
+	if f.tls {
+		tlsConfig, err = tlsconfig.Client(f.tlsCA, f.tlsCert, f.tlsKey, f.tlsServerName)
+		if err != nil {
+			return err
+		}
+	}
+
 	c, err := cli.New(&cli.Config{
 		Addr: f.addr,
+		TLS:  tlsConfig,
 	})
 	if err != nil {
 		return err
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/baibikov/jellydb/internal/pkg/jell"
+	"github.com/baibikov/jellydb/internal/pkg/jellystore"
+)
+
+// loadProgressInterval interval of logging the loading progress
+const loadProgressInterval = 5 * time.Second
+
+// load loads the jelly logging the progress of the store. The keys failed
+// to load are logged and not served, the rest of the keys are served.
+func load(ctx context.Context, jelly jell.Jelly, store *jellystore.Store) error {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(loadProgressInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				logProgress(store.LoadProgress(), "load progress")
+			}
+		}
+	}()
+
+	err := jelly.Load(ctx)
+	close(done)
+	logProgress(store.LoadProgress(), "load finished")
+
+	if loadErr, ok := err.(*jellystore.LoadError); ok {
+		for key, kerr := range loadErr.Keys {
+			logrus.WithField("key", key).Errorf("load key: %v", kerr)
+		}
+		return nil
+	}
+	return err
+}
+
+func logProgress(p jellystore.LoadProgress, msg string) {
+	logrus.WithFields(logrus.Fields{
+		"keys":   p.Keys,
+		"loaded": p.Loaded,
+		"failed": p.Failed,
+		"bytes":  p.Bytes,
+	}).Info(msg)
+}
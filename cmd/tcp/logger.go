@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+
+	logOutputStdout = "stdout"
+	logOutputStderr = "stderr"
+)
+
+// initLogger configures the standard logger, output is stdout, stderr or a file path.
+// Returns the closer of the output file, nil for the standard streams.
+func initLogger(level, format, output string) (io.Closer, error) {
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse log level")
+	}
+	logrus.SetLevel(lvl)
+
+	switch format {
+	case logFormatText:
+		logrus.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	case logFormatJSON:
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return nil, errors.Errorf("undefined log format %s, allowed %s or %s", format, logFormatText, logFormatJSON)
+	}
+
+	switch output {
+	case logOutputStdout:
+		logrus.SetOutput(os.Stdout)
+	case logOutputStderr, "":
+		logrus.SetOutput(os.Stderr)
+	default:
+		file, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, errors.Wrapf(err, "open log file by path - %s", output)
+		}
+		logrus.SetOutput(file)
+		return file, nil
+	}
+
+	return nil, nil
+}
+
+// envOr value of the environment variable or the default value if it is not set.
+func envOr(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
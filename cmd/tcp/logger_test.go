@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitLogger(t *testing.T) {
+	defer func() {
+		logrus.SetLevel(logrus.InfoLevel)
+		logrus.SetFormatter(&logrus.TextFormatter{})
+		logrus.SetOutput(os.Stderr)
+	}()
+
+	closer, err := initLogger("debug", logFormatJSON, logOutputStdout)
+	require.NoError(t, err)
+	require.Nil(t, closer)
+	require.Equal(t, logrus.DebugLevel, logrus.GetLevel())
+	require.IsType(t, &logrus.JSONFormatter{}, logrus.StandardLogger().Formatter)
+	require.Equal(t, os.Stdout, logrus.StandardLogger().Out)
+
+	closer, err = initLogger("warn", logFormatText, "")
+	require.NoError(t, err)
+	require.Nil(t, closer)
+	require.Equal(t, logrus.WarnLevel, logrus.GetLevel())
+	require.IsType(t, &logrus.TextFormatter{}, logrus.StandardLogger().Formatter)
+	require.Equal(t, os.Stderr, logrus.StandardLogger().Out)
+
+	// the file output is appended and closed by the caller
+	path := filepath.Join(t.TempDir(), "jelly.log")
+	closer, err = initLogger("info", logFormatJSON, path)
+	require.NoError(t, err)
+	require.NotNil(t, closer)
+	logrus.Info("message")
+	logrus.SetOutput(os.Stderr)
+	require.NoError(t, closer.Close())
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(b), `"msg":"message"`)
+
+	_, err = initLogger("verbose", logFormatText, logOutputStderr)
+	require.Error(t, err)
+	_, err = initLogger("info", "xml", logOutputStderr)
+	require.Error(t, err)
+	_, err = initLogger("info", logFormatText, filepath.Join(t.TempDir(), "absent", "jelly.log"))
+	require.Error(t, err)
+}
+
+func TestEnvOr(t *testing.T) {
+	const key = "JELLY_TEST_ENV_OR"
+
+	require.Equal(t, "default", envOr(key, "default"))
+
+	t.Setenv(key, "")
+	require.Equal(t, "", envOr(key, "default"))
+
+	t.Setenv(key, "value")
+	require.Equal(t, "value", envOr(key, "default"))
+}
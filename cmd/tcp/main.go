@@ -2,22 +2,26 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"flag"
+	"net"
+	"net/http"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"go.uber.org/multierr"
 
+	"github.com/baibikov/jellydb/internal/auth"
+	"github.com/baibikov/jellydb/internal/metrics"
 	"github.com/baibikov/jellydb/internal/pkg/jellystore"
 	"github.com/baibikov/jellydb/internal/tcp"
+	"github.com/baibikov/jellydb/pkg/tlsconfig"
+	"github.com/baibikov/jellydb/pkg/utils"
 )
 
-func init() {
-	logrus.SetLevel(logrus.DebugLevel)
-}
-
 func main() {
 	flags, err := parse()
 	if err != nil {
@@ -25,14 +29,52 @@ func main() {
 		return
 	}
 
+	logFile, err := initLogger(flags.logLevel, flags.logFormat, flags.logOutput)
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+	if logFile != nil {
+		defer logFile.Close()
+	}
+
 	if err := runApp(flags); err != nil {
 		logrus.Fatalln(err)
 	}
 }
 
 type Flags struct {
-	addr string
-	path string
+	addr        string
+	path        string
+	metricsAddr string
+	metricsKeys int
+
+	logLevel  string
+	logFormat string
+	logOutput string
+
+	shutdownTimeout time.Duration
+	readTimeout     time.Duration
+	writeTimeout    time.Duration
+	idleTimeout     time.Duration
+
+	maxConnections    int
+	maxInFlight       int
+	requestsPerSecond float64
+	bytesPerSecond    float64
+
+	tlsCert     string
+	tlsKey      string
+	tlsClientCA string
+
+	authConfig   string
+	quotaConfig  string
+	memoryBudget int64
+
+	shards          int
+	loadConcurrency int
+	lazyLoad        bool
+	dedupWindow     time.Duration
 }
 
 func parse() (*Flags, error) {
@@ -42,13 +84,110 @@ func parse() (*Flags, error) {
 	var path string
 	flag.StringVar(&path, "path", "./.data", "")
 
+	var metricsAddr string
+	flag.StringVar(&metricsAddr, "metrics-addr", "", "address of the http /metrics endpoint, empty switches it off")
+
+	var metricsKeys int
+	flag.IntVar(&metricsKeys, "metrics-keys", 0, "keys of a namespace exposed as the series of their own, 0 exposes only the namespaces")
+
+	var logLevel string
+	flag.StringVar(&logLevel, "log-level", envOr("JELLY_LOG_LEVEL", "info"), "log level: trace, debug, info, warn, error (env JELLY_LOG_LEVEL)")
+
+	var logFormat string
+	flag.StringVar(&logFormat, "log-format", envOr("JELLY_LOG_FORMAT", logFormatText), "log format: text or json (env JELLY_LOG_FORMAT)")
+
+	var logOutput string
+	flag.StringVar(&logOutput, "log-output", envOr("JELLY_LOG_OUTPUT", logOutputStderr), "log output: stdout, stderr or a file path (env JELLY_LOG_OUTPUT)")
+
+	var shutdownTimeout time.Duration
+	flag.DurationVar(&shutdownTimeout, "shutdown-timeout", 10*time.Second, "time for in-flight requests to finish on shutdown")
+
+	var readTimeout time.Duration
+	flag.DurationVar(&readTimeout, "read-timeout", 10*time.Second, "time to read a request, 0 disables the timeout")
+
+	var writeTimeout time.Duration
+	flag.DurationVar(&writeTimeout, "write-timeout", 10*time.Second, "time to write a response, 0 disables the timeout")
+
+	var idleTimeout time.Duration
+	flag.DurationVar(&idleTimeout, "idle-timeout", 0, "time to wait for the next request of a connection, 0 disables the timeout")
+
+	var maxConnections int
+	flag.IntVar(&maxConnections, "max-connections", 0, "connections served at the same time, 0 means no limit")
+
+	var maxInFlight int
+	flag.IntVar(&maxInFlight, "max-in-flight", 0, "requests of a connection not answered yet, 0 means no limit")
+
+	var requestsPerSecond float64
+	flag.Float64Var(&requestsPerSecond, "rate-requests", 0, "requests per second by the client address, 0 means no limit")
+
+	var bytesPerSecond float64
+	flag.Float64Var(&bytesPerSecond, "rate-bytes", 0, "requests bytes per second by the client address, 0 means no limit")
+
+	var tlsCert string
+	flag.StringVar(&tlsCert, "tls-cert", "", "server certificate file, enables tls with -tls-key")
+
+	var tlsKey string
+	flag.StringVar(&tlsKey, "tls-key", "", "server key file")
+
+	var tlsClientCA string
+	flag.StringVar(&tlsClientCA, "tls-client-ca", "", "CA file to verify client certificates, enables mTLS")
+
+	var authConfig string
+	flag.StringVar(&authConfig, "auth-config", "", "yaml file of the users and their permissions, enables authentication")
+
+	var quotaConfig string
+	flag.StringVar(&quotaConfig, "quota-config", "", "yaml file of the quotas of the keys and namespaces, empty means no quotas")
+
+	var memoryBudget int64
+	flag.Int64Var(&memoryBudget, "memory-budget", 0, "bytes of the messages held in memory, the rest is read from disk, 0 means no budget")
+
+	var shards int
+	flag.IntVar(&shards, "shards", 0, "shards of the keys with their own locks and unloading workers, 0 means the number of CPUs")
+
+	var loadConcurrency int
+	flag.IntVar(&loadConcurrency, "load-concurrency", 0, "workers loading the keys on start, 0 means the number of CPUs")
+
+	var lazyLoad bool
+	flag.BoolVar(&lazyLoad, "lazy-load", false, "register the keys on start and load every key on its first access")
+
+	var dedupWindow time.Duration
+	flag.DurationVar(&dedupWindow, "dedup-window", time.Hour, "time the sequences of the idempotent producers are kept since their last messages")
+
 	flag.Parse()
 	if addr == "" {
 		return nil, errors.New("addr is required param")
 	}
 	return &Flags{
-		addr: addr,
-		path: path,
+		addr:        addr,
+		path:        path,
+		metricsAddr: metricsAddr,
+		metricsKeys: metricsKeys,
+		logLevel:    logLevel,
+		logFormat:   logFormat,
+		logOutput:   logOutput,
+
+		shutdownTimeout: shutdownTimeout,
+		readTimeout:     readTimeout,
+		writeTimeout:    writeTimeout,
+		idleTimeout:     idleTimeout,
+
+		maxConnections:    maxConnections,
+		maxInFlight:       maxInFlight,
+		requestsPerSecond: requestsPerSecond,
+		bytesPerSecond:    bytesPerSecond,
+
+		tlsCert:     tlsCert,
+		tlsKey:      tlsKey,
+		tlsClientCA: tlsClientCA,
+
+		authConfig:   authConfig,
+		quotaConfig:  quotaConfig,
+		memoryBudget: memoryBudget,
+
+		shards:          shards,
+		loadConcurrency: loadConcurrency,
+		lazyLoad:        lazyLoad,
+		dedupWindow:     dedupWindow,
 	}, nil
 }
 
@@ -66,32 +205,144 @@ func runApp(f *Flags) (err error) {
 
 	logrus.Info("init jellystore")
 	jellyConfig := &jellystore.Config{
-		Path: f.path,
+		Path:            f.path,
+		MemoryBudget:    f.memoryBudget,
+		Shards:          f.shards,
+		LoadConcurrency: f.loadConcurrency,
+		LazyLoad:        f.lazyLoad,
+		DedupWindow:     f.dedupWindow,
+	}
+	if f.quotaConfig != "" {
+		logrus.Infof("init quotas from %s", f.quotaConfig)
+		quotas, qerr := loadQuotas(f.quotaConfig)
+		if qerr != nil {
+			return errors.Wrap(qerr, "init quotas")
+		}
+		jellyConfig.Quota = quotas.Default
+		jellyConfig.NamespaceQuotas = quotas.Namespaces
 	}
 	store, err := jellystore.New(jellyConfig)
 	if err != nil {
 		return errors.Wrap(err, "init jellystore")
 	}
 
+	var m *metrics.Metrics
+	if f.metricsAddr != "" {
+		m = metrics.New(metrics.Config{MaxKeys: f.metricsKeys})
+
+		logrus.Infof("init metrics http app on port %s", f.metricsAddr)
+		metricsServer, merr := runMetrics(f.metricsAddr, m, cancel)
+		if merr != nil {
+			return errors.Wrap(merr, "init metrics http server")
+		}
+		defer multierr.AppendInvoke(&err, multierr.Close(metricsServer))
+	}
+	jelly := m.Instrument(store)
+
+	logrus.Infof("load jellystore from %s", f.path)
+	err = utils.CreateFileIfNotExists(f.path)
+	if err != nil {
+		return errors.Wrap(err, "create jellystore path")
+	}
+	err = load(ctx, jelly, store)
+	if err != nil {
+		return errors.Wrap(err, "load jellystore")
+	}
+
+	var tlsConfig *tls.Config
+	if f.tlsCert != "" || f.tlsKey != "" || f.tlsClientCA != "" {
+		logrus.Infof("init tls, client verification: %t", f.tlsClientCA != "")
+		tlsConfig, err = tlsconfig.Server(f.tlsCert, f.tlsKey, f.tlsClientCA)
+		if err != nil {
+			return errors.Wrap(err, "init tls")
+		}
+	}
+
+	var authenticator *auth.Authenticator
+	if f.authConfig != "" {
+		logrus.Infof("init auth from %s", f.authConfig)
+		authenticator, err = auth.Load(f.authConfig)
+		if err != nil {
+			return errors.Wrap(err, "init auth")
+		}
+	}
+
 	logrus.Infof("init tcp app on port %s", f.addr)
 	tcpConfig := &tcp.Config{
-		Addr: f.addr,
+		Addr:         f.addr,
+		TLS:          tlsConfig,
+		Auth:         authenticator,
+		Metrics:      m,
+		ReadTimeout:  f.readTimeout,
+		WriteTimeout: f.writeTimeout,
+		IdleTimeout:  f.idleTimeout,
+
+		MaxConnections:    f.maxConnections,
+		MaxInFlight:       f.maxInFlight,
+		RequestsPerSecond: f.requestsPerSecond,
+		BytesPerSecond:    f.bytesPerSecond,
 	}
-	server, err := tcp.New(tcpConfig, store)
+	server, err := tcp.New(tcpConfig, jelly)
 	if err != nil {
 		return errors.Wrap(err, "init tcp connection")
 	}
-	defer multierr.AppendInvoke(&err, multierr.Close(server))
 
+	berrs := make(chan error, 1)
 	go func() {
 		logrus.Info("broadcast the server")
-		berr := server.Broadcast(ctx)
-		if berr != nil {
-			multierr.AppendInto(&err, berr)
+		berrs <- server.Broadcast(ctx)
+	}()
+
+	select {
+	case <-ctx.Done():
+	case berr := <-berrs:
+		multierr.AppendInto(&err, berr)
+	}
+
+	logrus.Infof("shutdown tcp app, waiting in-flight requests up to %s", f.shutdownTimeout)
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), f.shutdownTimeout)
+	defer shutdownCancel()
+	if serr := server.Shutdown(shutdownCtx); serr != nil {
+		multierr.AppendInto(&err, errors.Wrap(serr, "shutdown tcp server"))
+	}
+
+	// the final unload is not limited by the shutdown timeout
+	// so as not to lose the data accepted before the shutdown
+	logrus.Info("unload jellystore")
+	if uerr := jelly.Unload(context.Background()); uerr != nil {
+		multierr.AppendInto(&err, errors.Wrap(uerr, "unload jellystore"))
+	}
+	if report := store.LastUnload(); report != nil {
+		logrus.WithFields(logrus.Fields{
+			"keys":        report.Keys,
+			"bytes":       report.Bytes,
+			"duration_ms": report.Duration.Milliseconds(),
+		}).Info("unload finished")
+	}
+
+	return err
+}
+
+func runMetrics(addr string, m *metrics.Metrics, cancel context.CancelFunc) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "listen connection")
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Error(errors.Wrap(err, "serve metrics"))
 			cancel()
 		}
 	}()
 
-	<-ctx.Done()
-	return err
+	return server, nil
 }
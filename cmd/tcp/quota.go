@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/baibikov/jellydb/internal/pkg/jellystore"
+)
+
+// quotaConfig quotas of the store, for example:
+//
+//	default:
+//	  key_messages: 10000
+//	  memory_bytes: 104857600
+//	namespaces:
+//	  billing:
+//	    key_disk_bytes: 1073741824
+type quotaConfig struct {
+	Default    jellystore.Quota            `yaml:"default"`
+	Namespaces map[string]jellystore.Quota `yaml:"namespaces"`
+}
+
+// loadQuotas loads the quotas from the yaml file, unknown fields are rejected.
+func loadQuotas(path string) (*quotaConfig, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open quota config by path - %s", path)
+	}
+	defer file.Close()
+
+	config := &quotaConfig{}
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	err = decoder.Decode(config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse quota config by path - %s", path)
+	}
+
+	return config, nil
+}
@@ -0,0 +1,211 @@
+// Package auth authentication of the clients and access control of the keys
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package auth
+
+import (
+	"crypto/subtle"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrForbidden       = errors.New("forbidden")
+)
+
+type Permission string
+
+// AnyNamespace the namespace of the rule matching all namespaces.
+const AnyNamespace = "*"
+
+const (
+	PermissionRead   Permission = "read"
+	PermissionWrite  Permission = "write"
+	PermissionCommit Permission = "commit"
+)
+
+// Config users of the server, for example:
+//
+//	users:
+//	  - name: producer
+//	    token: 6f1c0b3e
+//	    rules:
+//	      - prefix: orders-
+//	        permissions: [write]
+//	  - name: consumer
+//	    password: secret
+//	    rules:
+//	      - prefix: orders-
+//	        permissions: [read, commit]
+//	      - namespace: billing
+//	        permissions: [read]
+type Config struct {
+	Users []User `yaml:"users"`
+}
+
+// User authenticates by the static token or by the name and password.
+type User struct {
+	Name     string `yaml:"name"`
+	Password string `yaml:"password"`
+	Token    string `yaml:"token"`
+	Rules    []Rule `yaml:"rules"`
+}
+
+// Rule grants the permissions to the keys of the namespace starting with the prefix,
+// an empty prefix grants them to all keys. An empty namespace is the default one,
+// the AnyNamespace grants the permissions in all namespaces.
+type Rule struct {
+	Namespace   string       `yaml:"namespace"`
+	Prefix      string       `yaml:"prefix"`
+	Permissions []Permission `yaml:"permissions"`
+}
+
+func (c *Config) validate() error {
+	names := make(map[string]struct{}, len(c.Users))
+	for _, u := range c.Users {
+		if u.Name == "" {
+			return errors.New("auth: user name has not be empty")
+		}
+		if _, ok := names[u.Name]; ok {
+			return errors.Errorf("auth: user %s is duplicated", u.Name)
+		}
+		names[u.Name] = struct{}{}
+
+		if u.Password == "" && u.Token == "" {
+			return errors.Errorf("auth: user %s has neither password nor token", u.Name)
+		}
+
+		for _, r := range u.Rules {
+			for _, p := range r.Permissions {
+				switch p {
+				case PermissionRead, PermissionWrite, PermissionCommit:
+				default:
+					return errors.Errorf("auth: user %s has undefined permission %s", u.Name, p)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Load loads the config of the users from the yaml file.
+func Load(path string) (*Authenticator, error) {
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "auth: read config by path - %s", path)
+	}
+
+	config := &Config{}
+	err = yaml.Unmarshal(bb, config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "auth: parse config by path - %s", path)
+	}
+
+	return New(config)
+}
+
+type Authenticator struct {
+	users []User
+}
+
+func New(config *Config) (*Authenticator, error) {
+	if config == nil {
+		return nil, errors.New("auth: config has not be empty")
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	return &Authenticator{users: config.Users}, nil
+}
+
+// Authenticate authenticates the client by the token
+// or by the name and password if the token is empty.
+func (a *Authenticator) Authenticate(token, name, password string) (*Principal, error) {
+	for i := range a.users {
+		u := &a.users[i]
+
+		ok := false
+		if token != "" {
+			ok = u.Token != "" && equal(u.Token, token)
+		} else {
+			ok = u.Password != "" && u.Name == name && equal(u.Password, password)
+		}
+		if ok {
+			return &Principal{Name: u.Name, rules: u.Rules}, nil
+		}
+	}
+
+	return nil, errors.Wrap(ErrUnauthenticated, "invalid credentials")
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// Principal the authenticated user.
+type Principal struct {
+	Name  string
+	rules []Rule
+}
+
+// Allowed reports whether the principal has the permission to the key of the namespace.
+func (p *Principal) Allowed(perm Permission, namespace, key string) bool {
+	for _, r := range p.rules {
+		if r.Namespace != AnyNamespace && r.Namespace != namespace {
+			continue
+		}
+		if !strings.HasPrefix(key, r.Prefix) {
+			continue
+		}
+		for _, pp := range r.Permissions {
+			if pp == perm {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Granted reports whether the principal has the permission to any key of the namespace.
+func (p *Principal) Granted(perm Permission, namespace string) bool {
+	for _, r := range p.rules {
+		if r.Namespace != AnyNamespace && r.Namespace != namespace {
+			continue
+		}
+		for _, pp := range r.Permissions {
+			if pp == perm {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Authorize checks the permission to the key of the namespace.
+func (p *Principal) Authorize(perm Permission, namespace, key string) error {
+	if p.Allowed(perm, namespace, key) {
+		return nil
+	}
+	if namespace != "" {
+		return errors.Wrapf(ErrForbidden, "user %s has no %s permission to key %s of namespace %s", p.Name, perm, key, namespace)
+	}
+	return errors.Wrapf(ErrForbidden, "user %s has no %s permission to key %s", p.Name, perm, key)
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `
+users:
+  - name: producer
+    token: producer-token
+    rules:
+      - prefix: orders-
+        permissions: [write]
+  - name: consumer
+    password: secret
+    rules:
+      - prefix: orders-
+        permissions: [read, commit]
+      - prefix: ""
+        permissions: [read]
+      - namespace: billing
+        prefix: invoices-
+        permissions: [commit]
+      - namespace: "*"
+        prefix: shared-
+        permissions: [write]
+`
+
+func TestAuthenticator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testConfig), 0o600))
+
+	a, err := Load(path)
+	require.NoError(t, err)
+
+	producer, err := a.Authenticate("producer-token", "", "")
+	require.NoError(t, err)
+	require.Equal(t, "producer", producer.Name)
+
+	consumer, err := a.Authenticate("", "consumer", "secret")
+	require.NoError(t, err)
+	require.Equal(t, "consumer", consumer.Name)
+
+	_, err = a.Authenticate("", "consumer", "wrong")
+	require.ErrorIs(t, err, ErrUnauthenticated)
+	_, err = a.Authenticate("", "producer", "")
+	require.ErrorIs(t, err, ErrUnauthenticated)
+	_, err = a.Authenticate("wrong-token", "", "")
+	require.ErrorIs(t, err, ErrUnauthenticated)
+
+	tests := []struct {
+		Name      string
+		Principal *Principal
+		Perm      Permission
+		Namespace string
+		Key       string
+		Want      bool
+	}{
+		{Name: "producer-write", Principal: producer, Perm: PermissionWrite, Key: "orders-1", Want: true},
+		{Name: "producer-other-key", Principal: producer, Perm: PermissionWrite, Key: "users-1"},
+		{Name: "producer-read", Principal: producer, Perm: PermissionRead, Key: "orders-1"},
+		{Name: "consumer-commit", Principal: consumer, Perm: PermissionCommit, Key: "orders-1", Want: true},
+		{Name: "consumer-read-all", Principal: consumer, Perm: PermissionRead, Key: "users-1", Want: true},
+		{Name: "consumer-commit-other-key", Principal: consumer, Perm: PermissionCommit, Key: "users-1"},
+		{Name: "consumer-write", Principal: consumer, Perm: PermissionWrite, Key: "orders-1"},
+		{Name: "consumer-other-namespace", Principal: consumer, Perm: PermissionRead, Namespace: "billing", Key: "orders-1"},
+		{Name: "consumer-namespace-commit", Principal: consumer, Perm: PermissionCommit, Namespace: "billing", Key: "invoices-1", Want: true},
+		{Name: "consumer-namespace-default", Principal: consumer, Perm: PermissionCommit, Key: "invoices-1"},
+		{Name: "consumer-any-namespace", Principal: consumer, Perm: PermissionWrite, Namespace: "team", Key: "shared-1", Want: true},
+		{Name: "consumer-any-namespace-default", Principal: consumer, Perm: PermissionWrite, Key: "shared-1", Want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			require.Equal(t, tt.Want, tt.Principal.Allowed(tt.Perm, tt.Namespace, tt.Key))
+
+			err := tt.Principal.Authorize(tt.Perm, tt.Namespace, tt.Key)
+			if tt.Want {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrForbidden)
+		})
+	}
+
+	// the permission to any key of the namespace
+	require.True(t, producer.Granted(PermissionWrite, ""))
+	require.False(t, producer.Granted(PermissionWrite, "billing"))
+	require.True(t, consumer.Granted(PermissionCommit, "billing"))
+	require.True(t, consumer.Granted(PermissionWrite, "team"))
+	require.False(t, consumer.Granted(PermissionRead, "team"))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Config *Config
+	}{
+		{Name: "no-name", Config: &Config{Users: []User{{Token: "t"}}}},
+		{Name: "no-credentials", Config: &Config{Users: []User{{Name: "u"}}}},
+		{Name: "duplicated", Config: &Config{Users: []User{{Name: "u", Token: "t"}, {Name: "u", Token: "t2"}}}},
+		{Name: "undefined-permission", Config: &Config{Users: []User{{
+			Name:  "u",
+			Token: "t",
+			Rules: []Rule{{Permissions: []Permission{"admin"}}},
+		}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			_, err := New(tt.Config)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+type authcommand struct {
+	conn net.Conn
+
+	token    string
+	username string
+	password string
+}
+
+const (
+	tokenIndex    = 0
+	usernameIndex = 0
+	passwordIndex = 1
+)
+
+// validate AUTH TOKEN or AUTH USERNAME PASSWORD
+func (a *authcommand) validate(params []string) error {
+	switch len(params) {
+	case 0:
+		return ErrNoParams
+	case 1:
+		a.token = params[tokenIndex]
+	case 2:
+		a.username = params[usernameIndex]
+		a.password = params[passwordIndex]
+	default:
+		return ErrNoAllowedParams
+	}
+
+	return nil
+}
+
+func (a *authcommand) exec() error {
+	err := protomarshal.NewDecoder(a.conn).Decode(&messages.AuthRequest{
+		Token:    a.token,
+		Username: a.username,
+		Password: a.password,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "%s command exec", authCommand)
+	}
+
+	return readResponse(a.conn)
+}
+
+func (a *authcommand) payload() []string {
+	return []string{"👌"}
+}
+
+func (a *authcommand) ping() error {
+	return errors.Wrapf(ping(a.conn, protomarshal.AuthMessageType), "%s ping the tcp server", authCommand)
+}
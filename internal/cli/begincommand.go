@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+type begincommand struct {
+	conn net.Conn
+
+	transaction string
+}
+
+// validate BEGIN, the transaction is of the namespace of the connection
+func (b *begincommand) validate(params []string) error {
+	if len(params) > 0 {
+		return ErrNoAllowedParams
+	}
+	return nil
+}
+
+func (b *begincommand) exec() error {
+	err := protomarshal.NewDecoder(b.conn).Decode(&messages.BeginRequest{})
+	if err != nil {
+		return errors.Wrapf(err, "%s command exec", beginCommand)
+	}
+
+	err = readResponse(b.conn)
+	if err != nil {
+		return err
+	}
+
+	resp := &messages.BeginResponse{}
+	err = protomarshal.NewEncoder(b.conn, messageSize).Encode(resp)
+	if err != nil {
+		return errors.Wrapf(err, "%s read data from tcp server", beginCommand)
+	}
+	b.transaction = resp.GetTransaction()
+	return nil
+}
+
+func (b *begincommand) payload() []string {
+	return []string{fmt.Sprintf("transaction: %s", b.transaction)}
+}
+
+func (b *begincommand) ping() error {
+	return errors.Wrapf(ping(b.conn, protomarshal.BeginMessageType), "%s ping the tcp server", beginCommand)
+}
+
+// endcommand ends the transaction or aborts it
+type endcommand struct {
+	conn net.Conn
+
+	abort bool
+}
+
+// validate END | ABORT
+func (e *endcommand) validate(params []string) error {
+	if len(params) > 0 {
+		return ErrNoAllowedParams
+	}
+	return nil
+}
+
+func (e *endcommand) exec() error {
+	err := protomarshal.NewDecoder(e.conn).Decode(&messages.EndRequest{
+		Abort: e.abort,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "%s command exec", e.command())
+	}
+
+	return readResponse(e.conn)
+}
+
+func (e *endcommand) command() string {
+	if e.abort {
+		return abortCommand
+	}
+	return endCommand
+}
+
+func (e *endcommand) payload() []string {
+	return []string{"👌"}
+}
+
+func (e *endcommand) ping() error {
+	return errors.Wrapf(ping(e.conn, protomarshal.EndMessageType), "%s ping the tcp server", e.command())
+}
@@ -3,6 +3,7 @@ package cli
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"os"
@@ -21,6 +22,8 @@ func (c Cli) Close() error {
 
 type Config struct {
 	Addr string
+	// TLS config of the connection, nil connects by the plain tcp
+	TLS *tls.Config
 }
 
 func New(config *Config) (*Cli, error) {
@@ -32,11 +35,25 @@ func New(config *Config) (*Cli, error) {
 		return nil, errors.New("config addr has not be empty")
 	}
 
-	conn, err := net.Dial("tcp", config.Addr)
+	var (
+		conn net.Conn
+		err  error
+	)
+	if config.TLS != nil {
+		conn, err = tls.Dial("tcp", config.Addr, config.TLS)
+	} else {
+		conn, err = net.Dial("tcp", config.Addr)
+	}
 	if err != nil {
 		return nil, errors.Wrapf(err, "connect by tcp protocol to address %s", config.Addr)
 	}
 
+	err = hello(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrapf(err, "switch the protocol of the server %s, the server may be older than the cli", config.Addr)
+	}
+
 	return &Cli{conn: conn}, nil
 }
 
@@ -55,19 +72,90 @@ exit:  Exit from CLI
 clear: Carriage cleaning
 
 (store)
-SET: Adding an entry to the read queue, as soon as the entry
+SET [PARTITION_KEY]: Adding an entry to the read queue, as soon as the entry
+the entry of the partitioned key goes to the partition chosen by the partition key
 example:
 > SET my_super_important SOME_VALUE_1
+> SET orders SOME_ORDER customer-42
+
+PSET KEY PRIORITY VALUE: Adding an entry of the priority level from 0 to 9,
+the entries of the higher levels are got and committed first, SET adds the entries of the zero level
+example:
+> PSET my_super_important 5 SOME_URGENT_VALUE
+
+MSET KEY VALUE [KEY VALUE ...]: Adding the entries of one or many keys by one request,
+the entries of a key are added together in their order
+example:
+> MSET my_super_important SOME_VALUE_1 my_super_important SOME_VALUE_2 other SOME_VALUE
+> 👌 3
 
-GET [N]: Getting uncommitted messages from the batch queue and n is batch elements
+GET [N] [PARTITION] [prefix:P] [contains:S] [field:F=V]: Getting uncommitted messages from the batch queue and n is batch elements,
+the partition is required for the partitioned key. The filter gets only the messages starting with the prefix,
+containing the bytes and having the field of the JSON message equal to the value, the scanned messages
+are committed to skip the filtered ones
 example:
 > GET my_super_important 2
 > SOME_VALUE_1
 > SOME_VALUE_2
+> GET orders 10 3
+> GET events 10 field:customer.id=42
+> {"type": "order", "customer": {"id": 42}}
+> scanned: 5
 
-COM [N]: Commenting on a batch of messages
+COM [N] [PARTITION]: Commenting on a batch of messages, the partition is required for the partitioned key
 example:
 > COMMIT my_super_important 2
+> COMMIT orders 10 3
+
+KEYS [PREFIX]: Listing of the existing keys, optionally starting with the prefix
+example:
+> KEYS my_
+> my_super_important
+
+DEL: Removing the key with all its messages
+example:
+> DEL my_super_important
+
+PURGE: Removing all messages of the key, the key itself stays
+example:
+> PURGE my_super_important
+
+STAT: Statistics of the key: depth, lag and occupied bytes
+example:
+> STAT my_super_important
+> total: 2
+> uncommitted: 1
+> ...
+
+AUTH TOKEN | AUTH USERNAME PASSWORD: Authenticating the connection on the server with auth
+example:
+> AUTH 6f1c0b3e
+> AUTH consumer secret
+
+USE [NAMESPACE]: Selecting the namespace of the next commands, without the namespace the default one
+example:
+> USE orders-team
+
+PARTITION KEY [N]: Splitting the key into n partitions, without n getting the partitions of the key
+example:
+> PARTITION orders 4
+> partitions: 4
+
+DEDUP KEY [WINDOW]: Dropping the entries of the key equal to the entries set within the window,
+0 switches it off, without the window getting the dedup window of the key
+example:
+> DEDUP my_super_important 10m
+> dedup window: 10m0s
+
+BEGIN: Beginning the transaction of the connection, the next SET and COM join it until END,
+their entries and commits are not seen by the others until END
+example:
+> BEGIN
+> transaction: 5f0c2a9d1e7b4c38
+
+END: Ending the transaction, its entries and commits are applied together
+
+ABORT: Aborting the transaction, its entries and commits are dropped
 
 S_ERR: syntax error, displayed if you made a mistake while writing the request
 E_ERR: system error, the error indicates that you encountered a problem while executing the request
@@ -80,9 +168,22 @@ const (
 )
 
 const (
-	setCommand    = "SET"
-	getCommand    = "GET"
-	commitCommand = "COM"
+	setCommand       = "SET"
+	getCommand       = "GET"
+	commitCommand    = "COM"
+	keysCommand      = "KEYS"
+	deleteCommand    = "DEL"
+	purgeCommand     = "PURGE"
+	statCommand      = "STAT"
+	authCommand      = "AUTH"
+	useCommand       = "USE"
+	partitionCommand = "PARTITION"
+	msetCommand      = "MSET"
+	beginCommand     = "BEGIN"
+	endCommand       = "END"
+	abortCommand     = "ABORT"
+	dedupCommand     = "DEDUP"
+	psetCommand      = "PSET"
 )
 
 const (
@@ -161,5 +262,11 @@ func (c *Cli) execCommand(tree []string) ([]string, error) {
 }
 
 func isStoreCommand(s string) bool {
-	return s == setCommand || s == getCommand || s == commitCommand
+	switch s {
+	case setCommand, getCommand, commitCommand,
+		keysCommand, deleteCommand, purgeCommand, statCommand, authCommand, useCommand, partitionCommand, msetCommand,
+		beginCommand, endCommand, abortCommand, dedupCommand, psetCommand:
+		return true
+	}
+	return false
 }
@@ -2,8 +2,11 @@ package cli
 
 import (
 	"net"
+	"time"
 
 	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/pkg/protomarshal"
 )
 
 var (
@@ -11,6 +14,34 @@ var (
 	ErrNoAllowedParams = errors.New("the number of parameters exceeds the allowable")
 )
 
+// ping writes the message type of the request to the connection.
+func ping(conn net.Conn, typ int) error {
+	_, err := conn.Write(protomarshal.FormatType(typ))
+	return err
+}
+
+// helloTimeout the server of the legacy protocol may not answer the hello
+const helloTimeout = 5 * time.Second
+
+// hello switches the connection to the framed protocol.
+func hello(conn net.Conn) error {
+	err := conn.SetDeadline(time.Now().Add(helloTimeout))
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Write(append(protomarshal.FormatType(protomarshal.HelloMessageType), protomarshal.VersionFramed))
+	if err != nil {
+		return err
+	}
+	err = readResponse(conn)
+	if err != nil {
+		return err
+	}
+
+	return conn.SetDeadline(time.Time{})
+}
+
 type commander interface {
 	validate(params []string) error
 	ping() error
@@ -33,6 +64,55 @@ func newCommand(typ string, conn net.Conn, params []string) (p []string, err err
 		cc = &commitcommand{
 			conn: conn,
 		}
+	case keysCommand:
+		cc = &keyscommand{
+			conn: conn,
+		}
+	case deleteCommand:
+		cc = &delcommand{
+			conn: conn,
+		}
+	case purgeCommand:
+		cc = &purgecommand{
+			conn: conn,
+		}
+	case statCommand:
+		cc = &statcommand{
+			conn: conn,
+		}
+	case authCommand:
+		cc = &authcommand{
+			conn: conn,
+		}
+	case useCommand:
+		cc = &usecommand{
+			conn: conn,
+		}
+	case partitionCommand:
+		cc = &partitioncommand{
+			conn: conn,
+		}
+	case msetCommand:
+		cc = &msetcommand{
+			conn: conn,
+		}
+	case beginCommand:
+		cc = &begincommand{
+			conn: conn,
+		}
+	case endCommand, abortCommand:
+		cc = &endcommand{
+			conn:  conn,
+			abort: typ == abortCommand,
+		}
+	case psetCommand:
+		cc = &psetcommand{
+			conn: conn,
+		}
+	case dedupCommand:
+		cc = &dedupcommand{
+			conn: conn,
+		}
 	default:
 		return nil, errors.New("S_ERR: undefined command")
 	}
@@ -13,31 +13,34 @@ import (
 type commitcommand struct {
 	conn net.Conn
 
-	key string
-	n   int64
+	key       string
+	n         int64
+	partition *int32
 }
 
 func (c *commitcommand) validate(params []string) (err error) {
 	if len(params) == 0 {
 		return ErrNoParams
 	}
-	if len(params) != 2 {
+	if len(params) != 2 && len(params) != 3 {
 		return ErrNoAllowedParams
 	}
 
-	c.key = params[nIndex]
+	c.key = params[keyIndex]
 	c.n, err = strconv.ParseInt(params[nIndex], 10, 64)
 	if err != nil {
 		return errors.Errorf("%s is not int64", params[nIndex])
 	}
 
-	return nil
+	c.partition, err = parsePartition(params)
+	return err
 }
 
 func (c *commitcommand) exec() error {
 	mm := &messages.CommitRequest{
-		Key: c.key,
-		N:   c.n,
+		Key:       c.key,
+		N:         c.n,
+		Partition: c.partition,
 	}
 	err := protomarshal.NewDecoder(c.conn).Decode(mm)
 	if err != nil {
@@ -57,6 +60,5 @@ func (c *commitcommand) payload() []string {
 }
 
 func (c *commitcommand) ping() error {
-	_, err := c.conn.Write([]byte("3"))
-	return errors.Wrapf(err, "%s ping the tcp server", commitCommand)
+	return errors.Wrapf(ping(c.conn, protomarshal.CommitMessageType), "%s ping the tcp server", commitCommand)
 }
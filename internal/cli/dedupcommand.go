@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+type dedupcommand struct {
+	conn net.Conn
+
+	key    string
+	window *int64
+
+	pp []string
+}
+
+const (
+	windowIndex = 1
+)
+
+// validate DEDUP KEY [WINDOW], no window only gets the dedup window of the key
+func (d *dedupcommand) validate(params []string) error {
+	if len(params) == 0 {
+		return ErrNoParams
+	}
+
+	if len(params) > 2 {
+		return ErrNoAllowedParams
+	}
+
+	d.key = params[keyIndex]
+	if len(params) == 2 {
+		window, err := time.ParseDuration(params[windowIndex])
+		if err != nil {
+			return errors.Errorf("%s is not duration", params[windowIndex])
+		}
+		d.window = proto.Int64(window.Milliseconds())
+	}
+	return nil
+}
+
+func (d *dedupcommand) exec() error {
+	err := protomarshal.NewDecoder(d.conn).Decode(&messages.DedupRequest{
+		Key:      d.key,
+		WindowMs: d.window,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "%s write message to tcp server", dedupCommand)
+	}
+
+	err = readResponse(d.conn)
+	if err != nil {
+		return err
+	}
+
+	resp := &messages.DedupResponse{}
+	err = protomarshal.NewEncoder(d.conn, messageSize).Encode(resp)
+	if err != nil {
+		return errors.Wrapf(err, "%s read data from tcp server", dedupCommand)
+	}
+
+	window := "off"
+	if resp.GetWindowMs() > 0 {
+		window = (time.Duration(resp.GetWindowMs()) * time.Millisecond).String()
+	}
+	d.pp = []string{fmt.Sprintf("dedup window: %s", window)}
+	return nil
+}
+
+func (d *dedupcommand) payload() []string {
+	return d.pp
+}
+
+func (d *dedupcommand) ping() error {
+	return errors.Wrapf(ping(d.conn, protomarshal.DedupMessageType), "%s ping the tcp server", dedupCommand)
+}
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+type delcommand struct {
+	conn net.Conn
+
+	key string
+}
+
+func (d *delcommand) validate(params []string) error {
+	if len(params) == 0 {
+		return ErrNoParams
+	}
+
+	if len(params) != 1 {
+		return ErrNoAllowedParams
+	}
+
+	d.key = params[keyIndex]
+	return nil
+}
+
+func (d *delcommand) exec() error {
+	err := protomarshal.NewDecoder(d.conn).Decode(&messages.DeleteRequest{
+		Key: d.key,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "%s command exec", deleteCommand)
+	}
+
+	return readResponse(d.conn)
+}
+
+func (d *delcommand) payload() []string {
+	return []string{"👌"}
+}
+
+func (d *delcommand) ping() error {
+	return errors.Wrapf(ping(d.conn, protomarshal.DeleteMessageType), "%s ping the tcp server", deleteCommand)
+}
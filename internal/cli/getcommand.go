@@ -1,8 +1,10 @@
 package cli
 
 import (
+	"fmt"
 	"net"
 	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 
@@ -11,6 +13,7 @@ import (
 )
 
 const (
+	statusCodeOK  = 20
 	StatusCodeBad = 50
 )
 
@@ -19,14 +22,17 @@ const (
 	messageIndex = 1
 	nIndex       = 1
 
-	messageSize = 256
+	// max size of the responses frames of the server
+	messageSize = protomarshal.MaxResponseSize
 )
 
 type getcommand struct {
 	conn net.Conn
 
-	key string
-	n   int64
+	key       string
+	n         int64
+	partition *int32
+	filter    *messages.Filter
 
 	pp []string
 }
@@ -36,7 +42,11 @@ func (g *getcommand) validate(params []string) (err error) {
 		return ErrNoParams
 	}
 
-	if len(params) != 2 {
+	params, g.filter, err = parseFilter(params)
+	if err != nil {
+		return err
+	}
+	if len(params) != 2 && len(params) != 3 {
 		return ErrNoAllowedParams
 	}
 
@@ -46,13 +56,16 @@ func (g *getcommand) validate(params []string) (err error) {
 		return errors.Errorf("%s is not int64", params[nIndex])
 	}
 
-	return nil
+	g.partition, err = parsePartition(params)
+	return err
 }
 
 func (g *getcommand) exec() error {
 	err := protomarshal.NewDecoder(g.conn).Decode(&messages.GetRequest{
-		Key: g.key,
-		N:   g.n,
+		Key:       g.key,
+		N:         g.n,
+		Partition: g.partition,
+		Filter:    g.filter,
 	})
 	if err != nil {
 		return errors.Wrapf(err, "%s write message to tcp server", getCommand)
@@ -73,27 +86,68 @@ func (g *getcommand) exec() error {
 	for i := 0; i < len(resp.Messages); i++ {
 		g.pp[i] = string(resp.Messages[i])
 	}
+	// the scanned messages are committed to skip the filtered ones
+	if g.filter != nil {
+		g.pp = append(g.pp, fmt.Sprintf("scanned: %d", resp.GetScanned()))
+	}
 
 	return nil
 }
 
+const (
+	prefixFilter   = "prefix:"
+	containsFilter = "contains:"
+	fieldFilter    = "field:"
+)
+
+// parseFilter the filter of the GET options after the batch: prefix:P, contains:S and field:F=V,
+// returns the rest of the params.
+func parseFilter(params []string) ([]string, *messages.Filter, error) {
+	if len(params) <= nIndex+1 {
+		return params, nil, nil
+	}
+
+	filter := &messages.Filter{}
+	rest := append([]string(nil), params[:nIndex+1]...)
+	for _, param := range params[nIndex+1:] {
+		switch {
+		case strings.HasPrefix(param, prefixFilter):
+			filter.Prefix = []byte(strings.TrimPrefix(param, prefixFilter))
+		case strings.HasPrefix(param, containsFilter):
+			filter.Contains = []byte(strings.TrimPrefix(param, containsFilter))
+		case strings.HasPrefix(param, fieldFilter):
+			field, value, ok := strings.Cut(strings.TrimPrefix(param, fieldFilter), "=")
+			if !ok {
+				return nil, nil, errors.Errorf("%s is not field=value", param)
+			}
+			filter.Field, filter.Value = field, value
+		default:
+			rest = append(rest, param)
+		}
+	}
+	if len(rest) == len(params) {
+		return params, nil, nil
+	}
+
+	return rest, filter, nil
+}
+
 func (g getcommand) payload() []string {
 	return g.pp
 }
 
 func (g *getcommand) ping() error {
-	_, err := g.conn.Write([]byte("2"))
-	return errors.Wrapf(err, "%s ping the tcp server", getCommand)
+	return errors.Wrapf(ping(g.conn, protomarshal.GetMessageType), "%s ping the tcp server", getCommand)
 }
 
 func readResponse(conn net.Conn) error {
 	mm := &messages.Response{}
 	err := protomarshal.NewEncoder(conn, messageSize).Encode(mm)
 	if err != nil {
-		return errors.Wrapf(err, "%s read message from tcp server", getCommand)
+		return errors.Wrap(err, "read response message from tcp server")
 	}
-	if mm.Code == StatusCodeBad {
-		return errors.Errorf("%s bad request: message %s", getCommand, mm.GetError())
+	if mm.Code != statusCodeOK {
+		return errors.Errorf("bad request (code %d): message %s", mm.GetCode(), mm.GetError())
 	}
 
 	return nil
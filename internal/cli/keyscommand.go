@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+const (
+	prefixIndex = 0
+)
+
+type keyscommand struct {
+	conn net.Conn
+
+	prefix string
+
+	pp []string
+}
+
+func (k *keyscommand) validate(params []string) error {
+	if len(params) > 1 {
+		return ErrNoAllowedParams
+	}
+
+	if len(params) == 1 {
+		k.prefix = params[prefixIndex]
+	}
+
+	return nil
+}
+
+func (k *keyscommand) exec() error {
+	err := protomarshal.NewDecoder(k.conn).Decode(&messages.ListRequest{
+		Prefix: k.prefix,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "%s write message to tcp server", keysCommand)
+	}
+
+	err = readResponse(k.conn)
+	if err != nil {
+		return err
+	}
+
+	resp := &messages.ListResponse{}
+	err = protomarshal.NewEncoder(k.conn, messageSize).Encode(resp)
+	if err != nil {
+		return errors.Wrapf(err, "%s read data from tcp server", keysCommand)
+	}
+
+	k.pp = resp.GetKeys()
+	return nil
+}
+
+func (k *keyscommand) payload() []string {
+	return k.pp
+}
+
+func (k *keyscommand) ping() error {
+	return errors.Wrapf(ping(k.conn, protomarshal.ListMessageType), "%s ping the tcp server", keysCommand)
+}
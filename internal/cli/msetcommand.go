@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+type msetcommand struct {
+	conn net.Conn
+
+	entries []*messages.SetBatchEntry
+}
+
+// validate MSET KEY VALUE [KEY VALUE ...]
+func (m *msetcommand) validate(params []string) error {
+	if len(params) == 0 {
+		return ErrNoParams
+	}
+
+	if len(params)%2 != 0 {
+		return errors.New("every key requires the value")
+	}
+
+	m.entries = make([]*messages.SetBatchEntry, 0, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		m.entries = append(m.entries, &messages.SetBatchEntry{
+			Key:     params[i+keyIndex],
+			Message: []byte(params[i+messageIndex]),
+		})
+	}
+
+	return nil
+}
+
+func (m *msetcommand) exec() error {
+	err := protomarshal.NewDecoder(m.conn).Decode(&messages.SetBatchRequest{
+		Entries: m.entries,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "%s command exec", msetCommand)
+	}
+
+	return readResponse(m.conn)
+}
+
+func (m *msetcommand) payload() []string {
+	return []string{fmt.Sprintf("👌 %d", len(m.entries))}
+}
+
+func (m *msetcommand) ping() error {
+	return errors.Wrapf(ping(m.conn, protomarshal.SetBatchMessageType), "%s ping the tcp server", msetCommand)
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+type partitioncommand struct {
+	conn net.Conn
+
+	key        string
+	partitions int64
+
+	pp []string
+}
+
+const (
+	partitionsIndex = 1
+	partitionIndex  = 2
+)
+
+// validate PARTITION KEY [N], no partitions only gets the partitions of the key
+func (p *partitioncommand) validate(params []string) (err error) {
+	if len(params) == 0 {
+		return ErrNoParams
+	}
+
+	if len(params) > 2 {
+		return ErrNoAllowedParams
+	}
+
+	p.key = params[keyIndex]
+	if len(params) == 2 {
+		p.partitions, err = strconv.ParseInt(params[partitionsIndex], 10, 32)
+		if err != nil {
+			return errors.Errorf("%s is not int32", params[partitionsIndex])
+		}
+	}
+	return nil
+}
+
+func (p *partitioncommand) exec() error {
+	err := protomarshal.NewDecoder(p.conn).Decode(&messages.PartitionRequest{
+		Key:        p.key,
+		Partitions: int32(p.partitions),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "%s write message to tcp server", partitionCommand)
+	}
+
+	err = readResponse(p.conn)
+	if err != nil {
+		return err
+	}
+
+	resp := &messages.PartitionResponse{}
+	err = protomarshal.NewEncoder(p.conn, messageSize).Encode(resp)
+	if err != nil {
+		return errors.Wrapf(err, "%s read data from tcp server", partitionCommand)
+	}
+
+	p.pp = []string{fmt.Sprintf("partitions: %d", resp.GetPartitions())}
+	return nil
+}
+
+func (p *partitioncommand) payload() []string {
+	return p.pp
+}
+
+func (p *partitioncommand) ping() error {
+	return errors.Wrapf(ping(p.conn, protomarshal.PartitionMessageType), "%s ping the tcp server", partitionCommand)
+}
+
+// parsePartition the optional partition of the GET and COM commands
+func parsePartition(params []string) (*int32, error) {
+	if len(params) <= partitionIndex {
+		return nil, nil
+	}
+
+	partition, err := strconv.ParseInt(params[partitionIndex], 10, 32)
+	if err != nil {
+		return nil, errors.Errorf("%s is not int32", params[partitionIndex])
+	}
+
+	p := int32(partition)
+	return &p, nil
+}
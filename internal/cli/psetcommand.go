@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+type psetcommand struct {
+	conn net.Conn
+
+	key      string
+	priority int64
+	message  []byte
+}
+
+const (
+	priorityIndex        = 1
+	priorityMessageIndex = 2
+)
+
+// validate PSET KEY PRIORITY VALUE
+func (p *psetcommand) validate(params []string) (err error) {
+	if len(params) == 0 {
+		return ErrNoParams
+	}
+
+	if len(params) != 3 {
+		return ErrNoAllowedParams
+	}
+
+	p.key = params[keyIndex]
+	p.priority, err = strconv.ParseInt(params[priorityIndex], 10, 32)
+	if err != nil {
+		return errors.Errorf("%s is not number", params[priorityIndex])
+	}
+	p.message = []byte(params[priorityMessageIndex])
+
+	return nil
+}
+
+func (p *psetcommand) exec() error {
+	err := protomarshal.NewDecoder(p.conn).Decode(&messages.SetRequest{
+		Key:      p.key,
+		Message:  p.message,
+		Priority: int32(p.priority),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "%s command exec", psetCommand)
+	}
+
+	return readResponse(p.conn)
+}
+
+func (p *psetcommand) payload() []string {
+	return []string{"👌"}
+}
+
+func (p *psetcommand) ping() error {
+	return errors.Wrapf(ping(p.conn, protomarshal.SetMessageType), "%s ping the tcp server", psetCommand)
+}
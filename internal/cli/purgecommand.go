@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+type purgecommand struct {
+	conn net.Conn
+
+	key string
+}
+
+func (p *purgecommand) validate(params []string) error {
+	if len(params) == 0 {
+		return ErrNoParams
+	}
+
+	if len(params) != 1 {
+		return ErrNoAllowedParams
+	}
+
+	p.key = params[keyIndex]
+	return nil
+}
+
+func (p *purgecommand) exec() error {
+	err := protomarshal.NewDecoder(p.conn).Decode(&messages.PurgeRequest{
+		Key: p.key,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "%s command exec", purgeCommand)
+	}
+
+	return readResponse(p.conn)
+}
+
+func (p *purgecommand) payload() []string {
+	return []string{"👌"}
+}
+
+func (p *purgecommand) ping() error {
+	return errors.Wrapf(ping(p.conn, protomarshal.PurgeMessageType), "%s ping the tcp server", purgeCommand)
+}
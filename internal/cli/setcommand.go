@@ -9,11 +9,16 @@ import (
 	"github.com/baibikov/jellydb/protogenerated/messages"
 )
 
+const (
+	partitionKeyIndex = 2
+)
+
 type settcommand struct {
 	conn net.Conn
 
-	key     string
-	message []byte
+	key          string
+	message      []byte
+	partitionKey string
 }
 
 func (s *settcommand) validate(params []string) error {
@@ -21,20 +26,24 @@ func (s *settcommand) validate(params []string) error {
 		return ErrNoParams
 	}
 
-	if len(params) != 2 {
+	if len(params) != 2 && len(params) != 3 {
 		return ErrNoAllowedParams
 	}
 
 	s.key = params[keyIndex]
 	s.message = []byte(params[messageIndex])
+	if len(params) == 3 {
+		s.partitionKey = params[partitionKeyIndex]
+	}
 
 	return nil
 }
 
 func (s *settcommand) exec() error {
 	mm := &messages.SetRequest{
-		Key:     s.key,
-		Message: s.message,
+		Key:          s.key,
+		Message:      s.message,
+		PartitionKey: s.partitionKey,
 	}
 	err := protomarshal.NewDecoder(s.conn).Decode(mm)
 	if err != nil {
@@ -54,6 +63,5 @@ func (s *settcommand) payload() []string {
 }
 
 func (s *settcommand) ping() error {
-	_, err := s.conn.Write([]byte("1"))
-	return errors.Wrapf(err, "%s ping the tcp server", setCommand)
+	return errors.Wrapf(ping(s.conn, protomarshal.SetMessageType), "%s ping the tcp server", setCommand)
 }
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+type statcommand struct {
+	conn net.Conn
+
+	key string
+
+	pp []string
+}
+
+func (s *statcommand) validate(params []string) error {
+	if len(params) == 0 {
+		return ErrNoParams
+	}
+
+	if len(params) != 1 {
+		return ErrNoAllowedParams
+	}
+
+	s.key = params[keyIndex]
+	return nil
+}
+
+func (s *statcommand) exec() error {
+	err := protomarshal.NewDecoder(s.conn).Decode(&messages.StatRequest{
+		Key: s.key,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "%s write message to tcp server", statCommand)
+	}
+
+	err = readResponse(s.conn)
+	if err != nil {
+		return err
+	}
+
+	resp := &messages.StatResponse{}
+	err = protomarshal.NewEncoder(s.conn, messageSize).Encode(resp)
+	if err != nil {
+		return errors.Wrapf(err, "%s read data from tcp server", statCommand)
+	}
+
+	s.pp = []string{
+		fmt.Sprintf("total: %d", resp.GetTotal()),
+		fmt.Sprintf("uncommitted: %d", resp.GetUncommitted()),
+		fmt.Sprintf("committed offset: %d", resp.GetCommittedOffset()),
+		fmt.Sprintf("written offset: %d", resp.GetWrittenOffset()),
+		fmt.Sprintf("memory bytes: %d", resp.GetMemoryBytes()),
+		fmt.Sprintf("disk bytes: %d", resp.GetDiskBytes()),
+		fmt.Sprintf("oldest age: %s", time.Duration(resp.GetOldestAgeMs())*time.Millisecond),
+	}
+	if resp.GetPartitions() > 0 {
+		s.pp = append(s.pp, fmt.Sprintf("partitions: %d", resp.GetPartitions()))
+	}
+	if resp.GetPriorities() > 0 {
+		s.pp = append(s.pp, fmt.Sprintf("priorities: %d", resp.GetPriorities()))
+	}
+	return nil
+}
+
+func (s *statcommand) payload() []string {
+	return s.pp
+}
+
+func (s *statcommand) ping() error {
+	return errors.Wrapf(ping(s.conn, protomarshal.StatMessageType), "%s ping the tcp server", statCommand)
+}
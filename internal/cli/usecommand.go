@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+type usecommand struct {
+	conn net.Conn
+
+	namespace string
+}
+
+const (
+	namespaceIndex = 0
+)
+
+// validate USE [NAMESPACE], no namespace selects the default one
+func (u *usecommand) validate(params []string) error {
+	if len(params) > 1 {
+		return ErrNoAllowedParams
+	}
+
+	if len(params) == 1 {
+		u.namespace = params[namespaceIndex]
+	}
+	return nil
+}
+
+func (u *usecommand) exec() error {
+	err := protomarshal.NewDecoder(u.conn).Decode(&messages.UseRequest{
+		Namespace: u.namespace,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "%s command exec", useCommand)
+	}
+
+	return readResponse(u.conn)
+}
+
+func (u *usecommand) payload() []string {
+	return []string{"👌"}
+}
+
+func (u *usecommand) ping() error {
+	return errors.Wrapf(ping(u.conn, protomarshal.UseMessageType), "%s ping the tcp server", useCommand)
+}
@@ -0,0 +1,231 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+
+	"github.com/baibikov/jellydb/internal/pkg/jell"
+)
+
+// bytesCounter is implemented by the stores counting bytes
+// read and written by loading and unloading.
+type bytesCounter interface {
+	LoadedBytes() int64
+	UnloadedBytes() int64
+}
+
+// memoryCounter is implemented by the stores counting
+// bytes of the messages held in memory.
+type memoryCounter interface {
+	MemoryBytes() int64
+}
+
+// Instrument wraps the jelly to collect durations of loading and unloading
+// and registers collectors of the namespaces and keys state and the file storage bytes.
+// The nil metrics returns the jelly as is.
+func (m *Metrics) Instrument(jelly jell.Jelly) jell.Jelly {
+	if m == nil {
+		return jelly
+	}
+
+	m.registry.MustRegister(newKeysCollector(jelly, m.config.MaxKeys))
+	if bc, ok := jelly.(bytesCounter); ok {
+		m.registry.MustRegister(
+			prometheus.NewCounterFunc(prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "load_bytes_total",
+				Help:      "Bytes read from the file storage by loading.",
+			}, func() float64 { return float64(bc.LoadedBytes()) }),
+			prometheus.NewCounterFunc(prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "unload_bytes_total",
+				Help:      "Bytes written to the file storage by unloading.",
+			}, func() float64 { return float64(bc.UnloadedBytes()) }),
+		)
+	}
+
+	if mc, ok := jelly.(memoryCounter); ok {
+		m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "memory_bytes",
+			Help:      "Bytes of the messages held in memory.",
+		}, func() float64 { return float64(mc.MemoryBytes()) }))
+	}
+
+	return &instrumented{Jelly: jelly, metrics: m}
+}
+
+type instrumented struct {
+	jell.Jelly
+	metrics *Metrics
+}
+
+func (i *instrumented) Load(ctx context.Context) error {
+	defer observeSince(i.metrics.loadDuration, time.Now())
+	return i.Jelly.Load(ctx)
+}
+
+func (i *instrumented) Unload(ctx context.Context) error {
+	defer observeSince(i.metrics.unloadDuration, time.Now())
+	return i.Jelly.Unload(ctx)
+}
+
+func observeSince(o prometheus.Observer, start time.Time) {
+	o.Observe(time.Since(start).Seconds())
+}
+
+// summarizer is implemented by the stores summing the statistics
+// of their keys without reading the file storage.
+type summarizer interface {
+	Summary() (int64, *jell.Stats)
+}
+
+var (
+	namespaceKeysDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "namespace", "keys"),
+		"Keys of the namespace.",
+		[]string{"namespace"}, nil,
+	)
+	namespaceMessagesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "namespace", "messages"),
+		"Messages of the namespace held in memory, including committed ones.",
+		[]string{"namespace"}, nil,
+	)
+	namespaceDepthDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "namespace", "depth"),
+		"Uncommitted messages of the namespace held in memory.",
+		[]string{"namespace"}, nil,
+	)
+	namespaceLagDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "namespace", "lag_seconds"),
+		"Age of the oldest uncommitted message of the namespace.",
+		[]string{"namespace"}, nil,
+	)
+	namespaceMemoryBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "namespace", "memory_bytes"),
+		"Bytes of the namespace messages held in memory.",
+		[]string{"namespace"}, nil,
+	)
+	namespaceDiskBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "namespace", "disk_bytes"),
+		"Bytes of the logs of the namespace keys held in memory.",
+		[]string{"namespace"}, nil,
+	)
+
+	keyMessagesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "key", "messages"),
+		"Messages of the key, including committed ones.",
+		[]string{"namespace", "key"}, nil,
+	)
+	keyDepthDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "key", "depth"),
+		"Uncommitted messages of the key.",
+		[]string{"namespace", "key"}, nil,
+	)
+	keyLagDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "key", "lag_seconds"),
+		"Age of the oldest uncommitted message of the key.",
+		[]string{"namespace", "key"}, nil,
+	)
+	keyMemoryBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "key", "memory_bytes"),
+		"Bytes of the key messages held in memory.",
+		[]string{"namespace", "key"}, nil,
+	)
+	keyDiskBytesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "key", "disk_bytes"),
+		"Bytes of the key files in the file storage.",
+		[]string{"namespace", "key"}, nil,
+	)
+)
+
+// keysCollector collects the state of every namespace on scrape and the state
+// of up to maxKeys keys of every namespace, the keys are not collected by default.
+type keysCollector struct {
+	jelly   jell.Jelly
+	maxKeys int
+}
+
+func newKeysCollector(jelly jell.Jelly, maxKeys int) *keysCollector {
+	return &keysCollector{jelly: jelly, maxKeys: maxKeys}
+}
+
+func (k *keysCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- namespaceKeysDesc
+	ch <- namespaceMessagesDesc
+	ch <- namespaceDepthDesc
+	ch <- namespaceLagDesc
+	ch <- namespaceMemoryBytesDesc
+	ch <- namespaceDiskBytesDesc
+	ch <- keyMessagesDesc
+	ch <- keyDepthDesc
+	ch <- keyLagDesc
+	ch <- keyMemoryBytesDesc
+	ch <- keyDiskBytesDesc
+}
+
+func (k *keysCollector) Collect(ch chan<- prometheus.Metric) {
+	names, err := k.jelly.Namespaces()
+	if err != nil {
+		logrus.Errorf("metrics: list namespaces: %v", err)
+		return
+	}
+
+	// the default namespace goes first, the scrape doesn't create the namespaces
+	for _, name := range append([]string{""}, names...) {
+		jelly, err := k.jelly.LookupNamespace(name)
+		if err != nil {
+			logrus.Errorf("metrics: namespace %s: %v", name, err)
+			continue
+		}
+
+		collectNamespace(ch, name, jelly)
+		if k.maxKeys > 0 {
+			collectKeys(ch, name, jelly, k.maxKeys)
+		}
+	}
+}
+
+func collectNamespace(ch chan<- prometheus.Metric, ns string, jelly jell.Jelly) {
+	s, ok := jelly.(summarizer)
+	if !ok {
+		return
+	}
+
+	keys, stats := s.Summary()
+	ch <- prometheus.MustNewConstMetric(namespaceKeysDesc, prometheus.GaugeValue, float64(keys), ns)
+	ch <- prometheus.MustNewConstMetric(namespaceMessagesDesc, prometheus.GaugeValue, float64(stats.Total), ns)
+	ch <- prometheus.MustNewConstMetric(namespaceDepthDesc, prometheus.GaugeValue, float64(stats.Uncommitted), ns)
+	ch <- prometheus.MustNewConstMetric(namespaceLagDesc, prometheus.GaugeValue, stats.OldestAge.Seconds(), ns)
+	ch <- prometheus.MustNewConstMetric(namespaceMemoryBytesDesc, prometheus.GaugeValue, float64(stats.MemoryBytes), ns)
+	ch <- prometheus.MustNewConstMetric(namespaceDiskBytesDesc, prometheus.GaugeValue, float64(stats.DiskBytes), ns)
+}
+
+// collectKeys collects the state of the first maxKeys keys of the namespace in order.
+func collectKeys(ch chan<- prometheus.Metric, ns string, jelly jell.Jelly, maxKeys int) {
+	keys, err := jelly.List("")
+	if err != nil {
+		logrus.Errorf("metrics: list keys: %v", err)
+		return
+	}
+	if len(keys) > maxKeys {
+		keys = keys[:maxKeys]
+	}
+
+	for _, key := range keys {
+		stats, err := jelly.Stats(key)
+		if err != nil {
+			// the key may be deleted between listing and getting the statistics
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(keyMessagesDesc, prometheus.GaugeValue, float64(stats.Total), ns, key)
+		ch <- prometheus.MustNewConstMetric(keyDepthDesc, prometheus.GaugeValue, float64(stats.Uncommitted), ns, key)
+		ch <- prometheus.MustNewConstMetric(keyLagDesc, prometheus.GaugeValue, stats.OldestAge.Seconds(), ns, key)
+		ch <- prometheus.MustNewConstMetric(keyMemoryBytesDesc, prometheus.GaugeValue, float64(stats.MemoryBytes), ns, key)
+		ch <- prometheus.MustNewConstMetric(keyDiskBytesDesc, prometheus.GaugeValue, float64(stats.DiskBytes), ns, key)
+	}
+}
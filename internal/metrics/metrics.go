@@ -0,0 +1,137 @@
+// Package metrics exposes prometheus metrics of the jellydb server
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "jellydb"
+
+// Config of the metrics.
+type Config struct {
+	// MaxKeys keys of every namespace collected as the series of their own on scrape,
+	// their statistics are read from the file storage. The namespaces are collected
+	// without the keys by default, so the series don't grow with the keys.
+	MaxKeys int
+}
+
+// Metrics collectors of the server.
+// A nil *Metrics is valid and collects nothing,
+// so the metrics can be switched off without checks on the caller side.
+type Metrics struct {
+	config   Config
+	registry *prometheus.Registry
+
+	requests    *prometheus.CounterVec
+	latency     *prometheus.HistogramVec
+	errors      *prometheus.CounterVec
+	connections prometheus.Gauge
+
+	loadDuration   prometheus.Histogram
+	unloadDuration prometheus.Histogram
+}
+
+func New(config Config) *Metrics {
+	m := &Metrics{
+		config:   config,
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Requests processed by the server by command and response code.",
+		}, []string{"command", "code"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Latency of the requests by command.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"command"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "errors_total",
+			Help:      "Requests ended with an error by response code.",
+		}, []string{"code"}),
+		connections: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "active_connections",
+			Help:      "Client connections currently served.",
+		}),
+		loadDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "load_duration_seconds",
+			Help:      "Duration of loading the store from the file storage.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+		}),
+		unloadDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "unload_duration_seconds",
+			Help:      "Duration of unloading the store to the file storage.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.latency,
+		m.errors,
+		m.connections,
+		m.loadDuration,
+		m.unloadDuration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// Handler http handler of the metrics in the prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest collects the processed request, code is the code of
+// the response, zero code means that the response has not been sent.
+func (m *Metrics) ObserveRequest(command string, code int32, d time.Duration, failed bool) {
+	if m == nil {
+		return
+	}
+
+	c := strconv.Itoa(int(code))
+	m.requests.WithLabelValues(command, c).Inc()
+	m.latency.WithLabelValues(command).Observe(d.Seconds())
+	if failed {
+		m.errors.WithLabelValues(c).Inc()
+	}
+}
+
+func (m *Metrics) ConnOpened() {
+	if m == nil {
+		return
+	}
+	m.connections.Inc()
+}
+
+func (m *Metrics) ConnClosed() {
+	if m == nil {
+		return
+	}
+	m.connections.Dec()
+}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/baibikov/jellydb/internal/pkg/jellystore"
+)
+
+// scrape the metrics in the exposition format
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Result().Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestMetrics_Requests(t *testing.T) {
+	m := New(Config{})
+
+	m.ObserveRequest("set", 20, time.Millisecond, false)
+	m.ObserveRequest("set", 20, time.Millisecond, false)
+	m.ObserveRequest("get", 50, time.Millisecond, true)
+	m.ObserveRequest("auth", 41, time.Millisecond, true)
+	m.ConnOpened()
+	m.ConnOpened()
+	m.ConnClosed()
+
+	body := scrape(t, m)
+	require.Contains(t, body, `jellydb_requests_total{code="20",command="set"} 2`)
+	require.Contains(t, body, `jellydb_requests_total{code="50",command="get"} 1`)
+	require.Contains(t, body, `jellydb_request_duration_seconds_count{command="set"} 2`)
+	require.Contains(t, body, `jellydb_errors_total{code="50"} 1`)
+	require.Contains(t, body, `jellydb_errors_total{code="41"} 1`)
+	require.NotContains(t, body, `jellydb_errors_total{code="20"}`)
+	require.Contains(t, body, "jellydb_active_connections 1")
+}
+
+func TestMetrics_Instrument(t *testing.T) {
+	store, err := jellystore.New(&jellystore.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	m := New(Config{})
+	jelly := m.Instrument(store)
+	require.NoError(t, jelly.Load(context.Background()))
+
+	require.NoError(t, jelly.Set("key", []byte("message-1")))
+	require.NoError(t, jelly.Set("key", []byte("message-2")))
+	require.NoError(t, jelly.Commit("key", 1))
+	orders, err := jelly.Namespace("orders")
+	require.NoError(t, err)
+	require.NoError(t, orders.Set("order", []byte("order-1")))
+	require.NoError(t, jelly.Unload(context.Background()))
+
+	// the namespaces are collected without the keys by default
+	body := scrape(t, m)
+	require.Contains(t, body, `jellydb_namespace_keys{namespace=""} 1`)
+	require.Contains(t, body, `jellydb_namespace_messages{namespace=""} 2`)
+	require.Contains(t, body, `jellydb_namespace_depth{namespace=""} 1`)
+	require.Contains(t, body, `jellydb_namespace_depth{namespace="orders"} 1`)
+	require.Contains(t, body, `jellydb_namespace_lag_seconds{namespace=""}`)
+	require.Contains(t, body, `jellydb_namespace_disk_bytes{namespace="orders"}`)
+	require.NotContains(t, body, "jellydb_key_")
+	require.Contains(t, body, "jellydb_load_duration_seconds_count 1")
+	require.Contains(t, body, "jellydb_unload_duration_seconds_count 1")
+	require.Contains(t, body, "jellydb_unload_bytes_total")
+	require.Contains(t, body, "jellydb_memory_bytes")
+}
+
+func TestMetrics_Keys(t *testing.T) {
+	store, err := jellystore.New(&jellystore.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	m := New(Config{MaxKeys: 1})
+	jelly := m.Instrument(store)
+	require.NoError(t, jelly.Set("a", []byte("message-1")))
+	require.NoError(t, jelly.Set("a", []byte("message-2")))
+	require.NoError(t, jelly.Commit("a", 1))
+	require.NoError(t, jelly.Set("b", []byte("message-1")))
+
+	// up to the max keys of a namespace are collected in order
+	body := scrape(t, m)
+	require.Contains(t, body, `jellydb_key_messages{key="a",namespace=""} 2`)
+	require.Contains(t, body, `jellydb_key_depth{key="a",namespace=""} 1`)
+	require.Contains(t, body, `jellydb_key_lag_seconds{key="a",namespace=""}`)
+	require.NotContains(t, body, `key="b"`)
+	require.Contains(t, body, `jellydb_namespace_keys{namespace=""} 2`)
+
+	// the scrape doesn't create the namespaces
+	names, err := store.Namespaces()
+	require.NoError(t, err)
+	require.Empty(t, names)
+}
+
+func TestMetrics_Nil(t *testing.T) {
+	store, err := jellystore.New(&jellystore.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	// the nil metrics collect nothing
+	var m *Metrics
+	m.ObserveRequest("set", 20, time.Millisecond, false)
+	m.ConnOpened()
+	m.ConnClosed()
+	require.Equal(t, store, m.Instrument(store))
+}
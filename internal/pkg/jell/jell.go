@@ -13,7 +13,22 @@
 */
 package jell
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrQuotaExceeded the message is not set because
+// the quota of the key or its namespace is exceeded.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// ErrMemoryBudget the message is not set because the messages
+// held in memory can't be spilled to fit it into the memory budget.
+var ErrMemoryBudget = errors.New("memory budget exceeded")
+
+// ErrNamespaceNotFound the namespace has not been created yet.
+var ErrNamespaceNotFound = errors.New("namespace not found")
 
 // Jelly is a generic connection for working with stretch storage.
 //
@@ -41,9 +56,24 @@ type Jelly interface {
 	//      log.Fatal(err)
 	//  }
 	Commit(key string, batch int64) error //  key to set committed, batch pull messages
+	// GetFiltered getting uncommitted messages matching the filter, see Get. The messages are
+	// scanned from the first uncommitted one until the batch of the matching messages is got,
+	// up to 16384 messages at once. Returns the scanned messages as well: the commit
+	// of them advances past the skipped messages, so they are not scanned again.
+	// The key having the priorities is scanned by the levels from the highest one.
+	// For example:
+	//
+	//  bb, scanned, err := store.GetFiltered("some-key", jell.Filter{Prefix: []byte("order:")}, 10)
+	//  if err != nil {
+	//      log.Fatal(err)
+	//  }
+	//  // ... handle bb
+	//  err = store.Commit("some-key", scanned)
+	GetFiltered(key string, filter Filter, batch int64) ([][]byte, int64, error) // key, filter and batch to get
 	// Set adding an entry to the read queue, as soon as the entry
 	// occurs, it will be possible to receive this data
 	// value has not been nil or len(value) == 0
+	// the message exceeding the quota is rejected with the ErrQuotaExceeded.
 	// For example:
 	//
 	//  err := store.Set("some-key", []byte("some-value"))
@@ -51,12 +81,248 @@ type Jelly interface {
 	//      log.Fatal(err)
 	//  }
 	Set(key string, value []byte) error // key to setting current key and value setting information
+	// SetPriority adding an entry of the priority level from 0 to 9, the entries set
+	// without the priority have the zero level. Get of the key having the priorities returns
+	// the uncommitted entries of the higher levels first, the entries of a level keep their order.
+	// Commit of the key commits the entries returned by the last Get first, the rest of the batch
+	// is committed from the highest level. The levels and their commits survive unloading and loading.
+	// The partitioned key has no priorities, the key having the priorities is not committed by transactions.
+	// For example:
+	//
+	//  err := store.SetPriority("some-key", 5, []byte("some-urgent-value"))
+	//  if err != nil {
+	//      log.Fatal(err)
+	//  }
+	SetPriority(key string, priority int, value []byte) error // key, priority level and value
+	// SetIdempotent adding an entry by the idempotent producer, so the retried entry is set once.
+	// The entry of the sequence not greater than the last one of the producer for the key
+	// is ignored as the duplicate, reports whether it is ignored. The sequences of the producer
+	// are kept within the dedup window since its last entry and survive unloading and loading.
+	// The sequences may have gaps, the partitioned key is not set by the idempotent producer.
+	// For example:
+	//
+	//  duplicate, err := store.SetIdempotent("some-key", "producer-1", 42, []byte("some-value"))
+	//  if err != nil {
+	//      log.Fatal(err)
+	//  }
+	SetIdempotent(key, producer string, sequence int64, value []byte) (bool, error) // key, producer, sequence and value
+	// Dedup setting the dedup window of the key: the entry of the key equal to an entry
+	// set within the window is dropped by setting, the partitions and the priority levels
+	// of the key are deduplicated together by the window of the key. The zero window
+	// switches the deduplication off.
+	// The digests of the entries survive unloading and loading.
+	// For example:
+	//
+	//  err := store.Dedup("some-key", 10*time.Minute)
+	//  if err != nil {
+	//      log.Fatal(err)
+	//  }
+	Dedup(key string, window time.Duration) error // key and dedup window
+	// DedupWindow getting the dedup window of the key, zero if the key is not deduplicated.
+	DedupWindow(key string) (time.Duration, error) // key to get dedup window
+	// SetDedupID adding an entry to the deduplicated key, the entry is deduplicated
+	// by the id instead of its content. Reports whether the entry is dropped as the duplicate.
+	SetDedupID(key, id string, value []byte) (bool, error) // key, dedup id and value
+	// SetBatch adding the entries of one or many keys to the read queues, the entries
+	// of a key are appended together in their order or none of them.
+	// The batch is checked before setting: the batch having an invalid key or
+	// a message larger than allowed sets nothing. The keys are set in the order
+	// of their first entries, the keys set before the failed one (by the quota, for example) stay set.
+	// For example:
+	//
+	//  err := store.SetBatch([]jell.Entry{
+	//      {Key: "some-key", Value: []byte("some-value")},
+	//      {Key: "orders", PartitionKey: "customer-42", Value: []byte("some-order")},
+	//  })
+	//  if err != nil {
+	//      log.Fatal(err)
+	//  }
+	SetBatch(entries []Entry) error // entries to set
+	// List listing of the existing keys starting with the prefix,
+	// an empty prefix lists all keys. Keys are sorted.
+	// For example:
+	//
+	//  keys, err := store.List("some-")
+	//  if err != nil {
+	//      log.Fatal(err)
+	//  }
+	//  fmt.Println(keys) // [some-key some-other-key]
+	List(prefix string) ([]string, error) // prefix to filter keys
+	// Delete removing the key with all its messages,
+	// the directory of the key is removed from the storage as well.
+	// For example:
+	//
+	//  err := store.Delete("some-key")
+	//  if err != nil {
+	//      log.Fatal(err)
+	//  }
+	Delete(key string) error // key to remove
+	// Purge removing all messages of the key (committed and uncommitted),
+	// the key itself stays and accepts new messages.
+	// The directory of the key is removed from the storage.
+	// For example:
+	//
+	//  err := store.Purge("some-key")
+	//  if err != nil {
+	//      log.Fatal(err)
+	//  }
+	Purge(key string) error // key to clean up messages
+	// Stats getting the state of the key queue: depth,
+	// lag of the consumers and the occupied bytes.
+	// For example:
+	//
+	//  stats, err := store.Stats("some-key")
+	//  if err != nil {
+	//      log.Fatal(err)
+	//  }
+	//  fmt.Println(stats.Uncommitted) // messages waiting for the commit
+	Stats(key string) (*Stats, error) // key to get statistics
+	// Namespace getting the storage of the namespace, keys of different
+	// namespaces don't collide. The namespace is created on the first use,
+	// an empty name is the default namespace.
+	// For example:
+	//
+	//  orders, err := store.Namespace("orders-team")
+	//  if err != nil {
+	//      log.Fatal(err)
+	//  }
+	//  err = orders.Set("some-key", []byte("some-value"))
+	Namespace(name string) (Jelly, error) // name of the namespace
+	// LookupNamespace getting the storage of the existing namespace, unlike Namespace
+	// the absent namespace is not created, ErrNamespaceNotFound is returned for it.
+	// For example:
+	//
+	//  orders, err := store.LookupNamespace("orders-team")
+	//  if errors.Is(err, jell.ErrNamespaceNotFound) {
+	//      return nil // nothing is set to the namespace yet
+	//  }
+	LookupNamespace(name string) (Jelly, error) // name of the namespace
+	// Namespaces listing of the existing namespaces except the default one.
+	// Namespaces are sorted.
+	Namespaces() ([]string, error)
+	// Partition splitting the key into n partitions, every partition has its own
+	// queue and directory, so the messages keep their order only within a partition.
+	// The number of partitions can't be changed, the key having messages
+	// without partitions can't be partitioned. Get, Commit and Set of the partitioned
+	// key fail, its partitions are used instead; List, Stats, Delete and Purge
+	// work with all partitions of the key.
+	// For example:
+	//
+	//  err := store.Partition("orders", 4)
+	//  if err != nil {
+	//      log.Fatal(err)
+	//  }
+	Partition(key string, n int) error // key to split, n partitions
+	// Partitions getting the number of partitions of the key, zero for the key not partitioned.
+	Partitions(key string) (int, error) // key to get partitions
+	// SetPartitioned adding an entry to the partition of the key chosen
+	// by the hash of the partition key, the entries of the same partition key
+	// go to the same partition in order. Returns the partition.
+	// For example:
+	//
+	//  partition, err := store.SetPartitioned("orders", "customer-42", []byte("some-value"))
+	//  if err != nil {
+	//      log.Fatal(err)
+	//  }
+	SetPartitioned(key, partitionKey string, value []byte) (int, error) // key, partition key to hash and value
+	// GetPartition getting uncommitted messages from the partition of the key, see Get.
+	GetPartition(key string, partition int, batch int64) ([][]byte, error) // key, partition and batch to get
+	// CommitPartition commenting on a batch of messages of the partition of the key, see Commit.
+	CommitPartition(key string, partition int, batch int64) error // key, partition and batch to commit
+	// GetPartitionFiltered getting uncommitted messages of the partition of the key matching the filter, see GetFiltered.
+	GetPartitionFiltered(key string, partition int, filter Filter, batch int64) ([][]byte, int64, error) // key, partition, filter and batch to get
+	// Transactor the concept of the transactions setting and committing many keys at once
+	Transactor
 	// Unloader the concept of unloading values on a stretchable storage
 	Unloader
 	// Loader the concept of loading values on a stretchable storage
 	Loader
 }
 
+// Entry the message of the batch setting.
+type Entry struct {
+	// Key of the message.
+	Key string
+	// PartitionKey of the message of the partitioned key, see SetPartitioned.
+	PartitionKey string
+	// Value of the message.
+	Value []byte
+}
+
+// Filter the condition of the messages got by GetFiltered, the message matches all conditions set.
+// The empty filter matches all messages.
+type Filter struct {
+	// Prefix the message starts with.
+	Prefix []byte
+	// Contains the bytes the message contains.
+	Contains []byte
+	// Field the dotted path of the field of the JSON object message equal to the Value,
+	// the string field is compared unquoted and the other ones by their compact JSON.
+	// The message not being the JSON object doesn't match.
+	// For example the field "customer.id" of {"customer": {"id": 42}} is equal to the value "42".
+	Field string
+	// Value of the Field.
+	Value string
+}
+
+// Stats the state of the key queue.
+type Stats struct {
+	// Total messages of the key, including committed ones.
+	Total int64
+	// Uncommitted messages of the key, the lag of the consumers.
+	Uncommitted int64
+	// CommittedOffset offset of committed messages in the log.
+	CommittedOffset int64
+	// WrittenOffset offset of recorded messages in the log.
+	WrittenOffset int64
+	// MemoryBytes bytes of messages held in memory.
+	MemoryBytes int64
+	// DiskBytes bytes of the log and meta files of the key.
+	DiskBytes int64
+	// OldestAge age of the oldest uncommitted message,
+	// zero if there are no uncommitted messages.
+	OldestAge time.Duration
+	// Partitions of the partitioned key, the statistics are summed
+	// by the partitions and the offsets are zero. Zero for the key not partitioned.
+	Partitions int
+	// Priorities the highest priority level of the key having the priorities, the statistics
+	// are summed by the levels and the offsets are zero. Zero for the key without the priorities.
+	Priorities int
+}
+
+// Transactor sets and commits the messages of many keys together, for example
+// to consume the messages of one key and set the results to others at once.
+// The values set by the transaction are not got until the transaction ends,
+// the transaction ended is recovered by loading even if it has not been unloaded.
+// Transactions work with the keys not partitioned of one namespace.
+// For example:
+//
+//	tx, err := store.Begin()
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	bb, err := store.Get("input", 10)
+//	// ... transform bb
+//	err = store.TxSet(tx, "output", []byte("some-result"))
+//	err = store.TxCommit(tx, "input", int64(len(bb)))
+//	err = store.End(tx)
+type Transactor interface {
+	// Begin beginning the transaction, returns the id of the transaction.
+	Begin() (string, error)
+	// TxSet adding an entry to the read queue by the transaction, see Set. The entry
+	// of the deduplicated key seen within the window is dropped when the transaction ends.
+	TxSet(tx, key string, value []byte) error // transaction, key and value
+	// TxCommit commenting on a batch of messages by the transaction, see Commit.
+	// The batch is limited by the uncommitted messages when the transaction ends.
+	TxCommit(tx, key string, batch int64) error // transaction, key and batch to commit
+	// End ending the transaction: the entries and the commits of the transaction
+	// are applied together or none of them. The transaction can't be used after ending,
+	// even if the ending failed.
+	End(tx string) error // transaction to end
+	// Abort aborting the transaction, the entries and the commits of the transaction are dropped.
+	Abort(tx string) error // transaction to abort
+}
+
 type Loader interface {
 	// Load - loading all parameters/data from storage.
 	// Loading data is necessary for fault-tolerant operation of in-memory storage.
@@ -0,0 +1,78 @@
+// Package jellystore
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/internal/pkg/jell"
+)
+
+// SetBatch setting the entries grouped by the keys, the entries of a key
+// (or a partition of the partitioned key) are appended under one lock of the key.
+func (s *Store) SetBatch(entries []jell.Entry) error {
+	keys, values, err := s.groupBatch(entries)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		err = s.set(key, values[key]...)
+		if err != nil {
+			return errors.Wrapf(err, "set batch by %s", key)
+		}
+	}
+
+	return nil
+}
+
+// groupBatch checks the entries and groups their values by the keys
+// of the messages, the keys are in the order of their first entries.
+func (s *Store) groupBatch(entries []jell.Entry) ([]string, map[string][][]byte, error) {
+	keys := make([]string, 0)
+	values := make(map[string][][]byte)
+	for _, e := range entries {
+		key, err := s.batchKey(e)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(e.Value) > maxMessageSize {
+			return nil, nil, errors.Errorf("transmitted message of key %s is larger than allowed", e.Key)
+		}
+
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+		values[key] = append(values[key], e.Value)
+	}
+
+	return keys, values, nil
+}
+
+// batchKey the key of the message of the entry, the partition
+// chosen by the partition key for the partitioned key.
+func (s *Store) batchKey(e jell.Entry) (string, error) {
+	if err := validateKey(e.Key); err != nil {
+		return "", err
+	}
+	if e.PartitionKey == "" {
+		return e.Key, s.checkPartitioned(e.Key)
+	}
+
+	n := s.partitionsOf(e.Key)
+	if n == 0 {
+		return "", errors.Errorf("key %s is not partitioned", e.Key)
+	}
+	return partitionName(e.Key, partitionOf(e.PartitionKey, n)), nil
+}
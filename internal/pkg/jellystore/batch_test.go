@@ -0,0 +1,91 @@
+// Package store
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/baibikov/jellydb/internal/pkg/jell"
+)
+
+func TestStore_SetBatch(t *testing.T) {
+	path := t.TempDir()
+	store, err := New(&Config{Path: path, Quota: Quota{KeyMessages: 3}})
+	require.NoError(t, err)
+	require.NoError(t, store.Partition("orders", 2))
+
+	// the invalid batch sets nothing
+	require.Error(t, store.SetBatch([]jell.Entry{
+		{Key: "a", Value: []byte("a-1")},
+		{Key: "b", Value: []byte(strings.Repeat("b", maxMessageSize+1))},
+	}))
+	require.Error(t, store.SetBatch([]jell.Entry{{Key: "a", Value: []byte("a-1")}, {Key: "a/b", Value: []byte("a-2")}}))
+	require.Error(t, store.SetBatch([]jell.Entry{{Key: "a", Value: []byte("a-1")}, {Key: "orders", Value: []byte("order")}}))
+	require.Error(t, store.SetBatch([]jell.Entry{{Key: "a", Value: []byte("a-1")}, {Key: "b", PartitionKey: "b", Value: []byte("b-1")}}))
+	keys, err := store.List("")
+	require.NoError(t, err)
+	require.Equal(t, []string{"orders"}, keys)
+
+	require.NoError(t, store.SetBatch([]jell.Entry{
+		{Key: "a", Value: []byte("a-1")},
+		{Key: "b", Value: []byte("b-1")},
+		{Key: "a", Value: []byte("a-2")},
+		{Key: "a", Value: nil},
+		{Key: "orders", PartitionKey: "customer", Value: []byte("order-1")},
+		{Key: "orders", PartitionKey: "customer", Value: []byte("order-2")},
+	}))
+
+	check := func(t *testing.T, store *Store) {
+		bb, err := store.Get("a", 10)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("a-1"), []byte("a-2")}, bb)
+
+		bb, err = store.Get("b", 10)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("b-1")}, bb)
+
+		bb, err = store.GetPartition("orders", partitionOf("customer", 2), 10)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("order-1"), []byte("order-2")}, bb)
+	}
+	check(t, store)
+
+	// the messages of the key exceeding the quota are not set, the keys set before stay
+	err = store.SetBatch([]jell.Entry{
+		{Key: "b", Value: []byte("b-2")},
+		{Key: "a", Value: []byte("a-3")},
+		{Key: "a", Value: []byte("a-4")},
+	})
+	require.True(t, errors.Is(err, jell.ErrQuotaExceeded))
+	stats, err := store.Stats("a")
+	require.NoError(t, err)
+	require.EqualValues(t, 2, stats.Total)
+	stats, err = store.Stats("b")
+	require.NoError(t, err)
+	require.EqualValues(t, 2, stats.Total)
+
+	require.NoError(t, store.Purge("b"))
+	require.NoError(t, store.SetBatch([]jell.Entry{{Key: "b", Value: []byte("b-1")}}))
+	require.NoError(t, store.Unload(context.Background()))
+
+	loadStore, err := New(&Config{Path: path})
+	require.NoError(t, err)
+	require.NoError(t, loadStore.Load(context.Background()))
+	check(t, loadStore)
+}
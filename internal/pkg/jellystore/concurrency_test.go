@@ -0,0 +1,184 @@
+// Package store
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+// TestStore_Concurrency the producers and the consumers of the keys work
+// concurrently with the unloading, the spilling and the removing of the other keys,
+// run it with -race.
+func TestStore_Concurrency(t *testing.T) {
+	const (
+		keys     = 4
+		messages = 500
+	)
+
+	path := t.TempDir()
+	store, err := New(&Config{Path: path, MemoryBudget: 4096})
+	require.NoError(t, err)
+	team, err := store.Namespace("team")
+	require.NoError(t, err)
+
+	jellies := []*Store{store, team.(*Store)}
+	for _, jelly := range jellies {
+		for k := 0; k < keys; k++ {
+			require.NoError(t, jelly.Set(fmt.Sprint("key-", k), []byte("message-0")))
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	background := errgroup.Group{}
+	background.Go(func() error {
+		for ctx.Err() == nil {
+			if err := store.Unload(ctx); err != nil && ctx.Err() == nil {
+				return err
+			}
+		}
+		return nil
+	})
+	for _, jelly := range jellies {
+		jelly := jelly
+		background.Go(func() error {
+			for ctx.Err() == nil {
+				if _, err := jelly.List(""); err != nil {
+					return err
+				}
+				for k := 0; k < keys; k++ {
+					if _, err := jelly.Stats(fmt.Sprint("key-", k)); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		background.Go(func() error {
+			// the key is set, deleted and purged meanwhile
+			for i := 0; ctx.Err() == nil; i++ {
+				if err := jelly.Set("churn", []byte(fmt.Sprint("message-", i))); err != nil {
+					return err
+				}
+				switch {
+				case i%10 == 0:
+					if err := jelly.Delete("churn"); err != nil {
+						return err
+					}
+				case i%7 == 0:
+					if err := jelly.Purge("churn"); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+	}
+
+	eg := errgroup.Group{}
+	for _, jelly := range jellies {
+		for k := 0; k < keys; k++ {
+			jelly, key := jelly, fmt.Sprint("key-", k)
+			eg.Go(func() error {
+				for i := 1; i < messages; i++ {
+					if err := jelly.Set(key, []byte(fmt.Sprint("message-", i))); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			eg.Go(func() error {
+				// the messages are received in order exactly once
+				for received := 0; received < messages; {
+					bb, err := jelly.Get(key, 10)
+					if err != nil {
+						return err
+					}
+					if len(bb) == 0 {
+						// wait for the producer
+						runtime.Gosched()
+						continue
+					}
+					for _, b := range bb {
+						if want := fmt.Sprint("message-", received); string(b) != want {
+							return errors.Errorf("key %s got %s, want %s", key, b, want)
+						}
+						received++
+					}
+					if err := jelly.Commit(key, int64(len(bb))); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		}
+	}
+
+	require.NoError(t, eg.Wait())
+	cancel()
+	require.NoError(t, background.Wait())
+	require.LessOrEqual(t, store.MemoryBytes(), int64(4096))
+
+	require.NoError(t, store.Unload(context.Background()))
+	loadStore, err := New(&Config{Path: path})
+	require.NoError(t, err)
+	require.NoError(t, loadStore.Load(context.Background()))
+	loadTeam, err := loadStore.Namespace("team")
+	require.NoError(t, err)
+	for _, jelly := range []*Store{loadStore, loadTeam.(*Store)} {
+		for k := 0; k < keys; k++ {
+			stats, err := jelly.Stats(fmt.Sprint("key-", k))
+			require.NoError(t, err)
+			require.EqualValues(t, messages, stats.Total)
+			require.Zero(t, stats.Uncommitted)
+		}
+	}
+}
+
+func TestStore_ConcurrencyLazyLoad(t *testing.T) {
+	const n = 100
+
+	path := t.TempDir()
+	writeTestLog(t, path, n)
+
+	store, err := New(&Config{Path: path, LazyLoad: true})
+	require.NoError(t, err)
+	require.NoError(t, store.Load(context.Background()))
+
+	// the concurrent accesses load the key once
+	eg := errgroup.Group{}
+	for i := 0; i < 8; i++ {
+		eg.Go(func() error {
+			bb, err := store.Get("key", n)
+			if err != nil {
+				return err
+			}
+			if len(bb) != n {
+				return errors.Errorf("got %d messages, want %d", len(bb), n)
+			}
+			return store.Set("key", []byte("message"))
+		})
+	}
+	require.NoError(t, eg.Wait())
+
+	stats, err := store.Stats("key")
+	require.NoError(t, err)
+	require.EqualValues(t, n+8, stats.Total)
+}
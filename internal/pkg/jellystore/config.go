@@ -13,10 +13,86 @@
 */
 package jellystore
 
-import "github.com/pkg/errors"
+import (
+	"runtime"
+	"time"
+
+	"github.com/pkg/errors"
+)
 
 type Config struct {
 	Path string
+
+	// MemoryBudget bytes of the messages held in memory by all namespaces,
+	// the older messages of the least recently used keys are evicted
+	// to the log over it and read from the log by getting. Zero means no budget.
+	MemoryBudget int64
+
+	// Shards of the keys of every namespace, the shards have their own locks
+	// and their keys are unloaded by their own workers. Zero means the number of CPUs.
+	Shards int
+
+	// LoadConcurrency workers loading the keys, a huge key is loaded
+	// by all workers. Zero means the number of CPUs.
+	LoadConcurrency int
+
+	// LazyLoad registers the keys by the directories on loading,
+	// the key is loaded on the first access by getting, setting or committing.
+	// The statistics of the key don't load it, the namespace quotas
+	// don't count the keys not loaded yet.
+	LazyLoad bool
+
+	// DedupWindow time the sequence of an idempotent producer of a key is kept
+	// since its last message, the retried messages of the producer are ignored within it.
+	// Zero means an hour.
+	DedupWindow time.Duration
+
+	// Quota limits of every namespace and its keys, including the default namespace.
+	Quota Quota
+	// NamespaceQuotas limits of the namespaces by name, replacing the Quota.
+	NamespaceQuotas map[string]Quota
+}
+
+// Quota limits of the keys and the namespace checked by setting, zero means no limit.
+// Disk bytes are the bytes the log and meta files of the keys take after unloading.
+type Quota struct {
+	// KeyMessages uncommitted messages of a key.
+	KeyMessages int64 `yaml:"key_messages"`
+	// KeyMemoryBytes bytes of a key messages held in memory.
+	KeyMemoryBytes int64 `yaml:"key_memory_bytes"`
+	// KeyDiskBytes bytes of a key files.
+	KeyDiskBytes int64 `yaml:"key_disk_bytes"`
+
+	// Messages uncommitted messages of all keys of the namespace.
+	Messages int64 `yaml:"messages"`
+	// MemoryBytes bytes of all keys messages of the namespace held in memory.
+	MemoryBytes int64 `yaml:"memory_bytes"`
+	// DiskBytes bytes of all keys files of the namespace.
+	DiskBytes int64 `yaml:"disk_bytes"`
+}
+
+// quota limits of the namespace, an empty name is the default namespace.
+func (c Config) quota(namespace string) Quota {
+	if q, ok := c.NamespaceQuotas[namespace]; ok && namespace != "" {
+		return q
+	}
+	return c.Quota
+}
+
+// shards of the keys
+func (c Config) shards() int {
+	if c.Shards > 0 {
+		return c.Shards
+	}
+	return runtime.NumCPU()
+}
+
+// dedupWindow time the sequences of the idempotent producers are kept
+func (c Config) dedupWindow() time.Duration {
+	if c.DedupWindow > 0 {
+		return c.DedupWindow
+	}
+	return time.Hour
 }
 
 func (c Config) validate() error {
@@ -0,0 +1,321 @@
+// Package jellystore
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+const (
+	// dedupFileName file of the key with the dedup window of the key
+	dedupFileName = "dedup.jelly.format"
+	// digestsFileName file of the key with the digests of the messages seen within the dedup window
+	digestsFileName = "digests.jelly.format"
+)
+
+// sumLen bytes of the digest of the message content, the truncated SHA-256 sum
+const sumLen = 16
+
+type digestSum [sumLen]byte
+
+// digest the digest of the message content seen by the key deduplicating by content
+type digest struct {
+	sum  digestSum
+	seen time.Time
+}
+
+// digests the digests seen within the dedup window by the key deduplicating by content,
+// shared by the partitions and the priority levels of the key and guarded by the mutex
+type digests struct {
+	mutex sync.Mutex
+	// sums times of seeing the digests, order the digests in the order of seeing
+	sums  map[digestSum]time.Time
+	order []digest
+	// dirty the digests changed since the last unloading
+	dirty bool
+	// removed the key has been deleted or purged
+	removed bool
+}
+
+// Dedup setting the dedup window of the key, the zero window switches the deduplication off.
+// The window is written at once, the partitions of the key share the window of the key.
+func (s *Store) Dedup(key string, window time.Duration) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	if window < 0 {
+		return errors.Errorf("dedup window %s of key %s is negative", window, key)
+	}
+
+	path := fmt.Sprintf("%s/%s", s.keyPath(key), dedupFileName)
+	if window == 0 {
+		s.dedups.Delete(key)
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "remove dedup by path - %s", path)
+		}
+		return nil
+	}
+
+	s.dedups.Store(key, window)
+	return s.writeDedup(key, window)
+}
+
+// DedupWindow getting the dedup window of the key, zero if the key is not deduplicated.
+func (s *Store) DedupWindow(key string) (time.Duration, error) {
+	val, _ := s.dedups.Load(key)
+	window, _ := val.(time.Duration)
+	return window, nil
+}
+
+// SetDedupID setting the value deduplicated by the id instead of the content,
+// reports whether the value is dropped as the duplicate.
+func (s *Store) SetDedupID(key, id string, value []byte) (bool, error) {
+	if err := validateKey(key); err != nil {
+		return false, err
+	}
+	if err := s.checkPartitioned(key); err != nil {
+		return false, err
+	}
+	if window, _ := s.DedupWindow(key); window == 0 {
+		return false, errors.Errorf("key %s is not deduplicated", key)
+	}
+	if id == "" {
+		return false, errors.New("dedup id has not be empty")
+	}
+
+	return s.produce(key, &produced{dedupID: id}, [][]byte{value})
+}
+
+// dedupKey the key sharing the dedup window and the digests with the key,
+// the partitioned key of the partition or the key of the priority level
+func dedupKey(key string) string {
+	if partitioned, _, ok := splitPartitionKey(key); ok {
+		return partitioned
+	}
+	if prioritized, _, ok := splitPriorityKey(key); ok {
+		return prioritized
+	}
+	return key
+}
+
+// dedupWindowOf the dedup window of the key, the partition of the partitioned key
+// or the priority level of the key
+func (s *Store) dedupWindowOf(key string) time.Duration {
+	window, _ := s.DedupWindow(dedupKey(key))
+	return window
+}
+
+// dedupSum the truncated SHA-256 digest of the content or of the dedup id if any
+func dedupSum(p *produced, value []byte) digestSum {
+	if p != nil && p.dedupID != "" {
+		value = []byte(p.dedupID)
+	}
+
+	var d digestSum
+	full := sha256.Sum256(value)
+	copy(d[:], full[:])
+	return d
+}
+
+// digestsOf the digests seen by the key, its partitions and its priority levels.
+func (s *Store) digestsOf(key string) *digests {
+	key = dedupKey(key)
+	val, ok := s.digests.Load(key)
+	if !ok {
+		val, _ = s.digests.LoadOrStore(key, &digests{})
+	}
+	d, _ := val.(*digests)
+	return d
+}
+
+// forgetDigests drops the digests of the key being deleted or purged with the digests file,
+// the digests being unloaded are not written after.
+func (s *Store) forgetDigests(key string) error {
+	if val, ok := s.digests.LoadAndDelete(key); ok {
+		d, _ := val.(*digests)
+		d.mutex.Lock()
+		d.removed = true
+		d.mutex.Unlock()
+	}
+
+	path := fmt.Sprintf("%s/%s", s.keyPath(key), digestsFileName)
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "remove digests by path - %s", path)
+	}
+	return nil
+}
+
+// seen reports whether the digest has been seen within the window.
+func (d *digests) seen(sum digestSum, now time.Time, window time.Duration) bool {
+	seen, ok := d.sums[sum]
+	return ok && now.Sub(seen) <= window
+}
+
+// see remembers the digest, the digests older than the window are forgotten
+// in the order of seeing.
+func (d *digests) see(sum digestSum, now time.Time, window time.Duration) {
+	d.expire(now, window)
+
+	if d.sums == nil {
+		d.sums = make(map[digestSum]time.Time)
+	}
+	d.sums[sum] = now
+	d.order = append(d.order, digest{sum: sum, seen: now})
+	d.dirty = true
+}
+
+func (d *digests) expire(now time.Time, window time.Duration) {
+	i := 0
+	for ; i < len(d.order) && now.Sub(d.order[i].seen) > window; i++ {
+		// the digest seen again later stays
+		if o := d.order[i]; d.sums[o.sum].Equal(o.seen) {
+			delete(d.sums, o.sum)
+		}
+	}
+	if i > 0 {
+		d.order = d.order[i:]
+		d.dirty = true
+	}
+}
+
+// unloadDigests writes the digests changed since the last unloading to the directories
+// of their keys, returns the written bytes.
+func (s *Store) unloadDigests() (n int64, err error) {
+	s.digests.Range(func(key, val any) bool {
+		d, _ := val.(*digests)
+		written, derr := s.unloadKeyDigests(key.(string), d)
+		n += written
+		err = multierr.Append(err, derr)
+		return true
+	})
+	return n, err
+}
+
+func (s *Store) unloadKeyDigests(key string, d *digests) (int64, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.removed || !d.dirty {
+		return 0, nil
+	}
+
+	d.expire(time.Now(), s.dedupWindowOf(key))
+	written, err := writeDigests(s.keyPath(key), d.order)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unload digests by key - %s", key)
+	}
+	d.dirty = false
+	s.unloadedBytes.Add(written)
+	return written, nil
+}
+
+func (s *Store) writeDedup(key string, window time.Duration) error {
+	path := s.keyPath(key)
+	err := os.MkdirAll(path, os.ModePerm)
+	if err != nil {
+		return errors.Wrapf(err, "mkdir by path - %s", path)
+	}
+
+	bb := make([]byte, 8)
+	binary.LittleEndian.PutUint64(bb, uint64(window))
+	path = fmt.Sprintf("%s/%s", path, dedupFileName)
+	return errors.Wrapf(os.WriteFile(path, bb, os.ModePerm), "write dedup by path - %s", path)
+}
+
+// readDedup reads the dedup window of the key directory, zero for the key not deduplicated.
+func (s *Store) readDedup(key string) (time.Duration, error) {
+	path := fmt.Sprintf("%s/%s", s.keyPath(key), dedupFileName)
+	bb, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "read dedup by path - %s", path)
+	}
+	if len(bb) != 8 {
+		return 0, errors.Errorf("dedup file %s is corrupted", path)
+	}
+
+	return time.Duration(binary.LittleEndian.Uint64(bb)), nil
+}
+
+// writeDigests writes the digests of the key directory in the order of seeing:
+// the number of the digests and every digest by the sum and the unix time of seeing,
+// little endian. Returns the written bytes, the directory of the key deleted meanwhile
+// is not created again.
+func writeDigests(dirPath string, digests []digest) (int64, error) {
+	path := fmt.Sprintf("%s/%s", dirPath, digestsFileName)
+	if len(digests) == 0 {
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return 0, errors.Wrapf(err, "remove digests by path - %s", path)
+		}
+		return 0, nil
+	}
+
+	buf := &bytes.Buffer{}
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(digests)))
+	for _, d := range digests {
+		buf.Write(d.sum[:])
+		_ = binary.Write(buf, binary.LittleEndian, d.seen.UnixNano())
+	}
+
+	err := os.WriteFile(path, buf.Bytes(), os.ModePerm)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "write digests by path - %s", path)
+	}
+	return int64(buf.Len()), nil
+}
+
+// readDigests reads the digests of the key directory, nil without the digests file.
+func readDigests(dirPath string) (*digests, error) {
+	path := fmt.Sprintf("%s/%s", dirPath, digestsFileName)
+	bb, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "read digests by path - %s", path)
+	}
+
+	const digestLen = sumLen + 8
+	if len(bb) < 4 || int64(len(bb)-4) != int64(binary.LittleEndian.Uint32(bb))*digestLen {
+		return nil, errors.Errorf("digests file %s is corrupted", path)
+	}
+	n := int64(binary.LittleEndian.Uint32(bb))
+
+	d := &digests{sums: make(map[digestSum]time.Time, n)}
+	for off := 4; off < len(bb); off += digestLen {
+		o := digest{seen: time.Unix(0, int64(binary.LittleEndian.Uint64(bb[off+sumLen:])))}
+		copy(o.sum[:], bb[off:])
+		d.sums[o.sum] = o.seen
+		d.order = append(d.order, o)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,215 @@
+// Package store
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"context"
+	"encoding/hex"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/baibikov/jellydb/internal/pkg/jell"
+)
+
+func TestStore_Dedup(t *testing.T) {
+	path := t.TempDir()
+	store, err := New(&Config{Path: path})
+	require.NoError(t, err)
+
+	require.NoError(t, store.Dedup("key", time.Hour))
+	require.Error(t, store.Dedup("key", -time.Second))
+	_, err = store.SetDedupID("other", "id", []byte("message"))
+	require.Error(t, err)
+
+	// the repeated contents and ids are dropped
+	require.NoError(t, store.Set("key", []byte("message-1")))
+	require.NoError(t, store.Set("key", []byte("message-1")))
+	require.NoError(t, store.SetBatch([]jell.Entry{
+		{Key: "key", Value: []byte("message-2")},
+		{Key: "key", Value: []byte("message-1")},
+		{Key: "key", Value: []byte("message-2")},
+	}))
+	dropped, err := store.SetDedupID("key", "id-1", []byte("message-3"))
+	require.NoError(t, err)
+	require.False(t, dropped)
+	dropped, err = store.SetDedupID("key", "id-1", []byte("message-4"))
+	require.NoError(t, err)
+	require.True(t, dropped)
+
+	// the keys not deduplicated keep the repeats
+	require.NoError(t, store.Set("other", []byte("message-1")))
+	require.NoError(t, store.Set("other", []byte("message-1")))
+
+	want := [][]byte{[]byte("message-1"), []byte("message-2"), []byte("message-3")}
+	bb, err := store.Get("key", 10)
+	require.NoError(t, err)
+	require.Equal(t, want, bb)
+	bb, err = store.Get("other", 10)
+	require.NoError(t, err)
+	require.Len(t, bb, 2)
+
+	// the key deduplicated before its first message keeps the window
+	require.NoError(t, store.Dedup("empty", time.Minute))
+	require.NoError(t, store.Unload(context.Background()))
+
+	// the windows and the digests survive the unloading and the loading
+	for _, lazy := range []bool{false, true} {
+		loadStore, err := New(&Config{Path: path, LazyLoad: lazy})
+		require.NoError(t, err)
+		require.NoError(t, loadStore.Load(context.Background()))
+
+		window, err := loadStore.DedupWindow("empty")
+		require.NoError(t, err)
+		require.Equal(t, time.Minute, window)
+
+		require.NoError(t, loadStore.Set("key", []byte("message-2")))
+		dropped, err = loadStore.SetDedupID("key", "id-1", []byte("message-4"))
+		require.NoError(t, err)
+		require.True(t, dropped)
+
+		bb, err := loadStore.Get("key", 10)
+		require.NoError(t, err)
+		require.Equal(t, want, bb)
+
+		keys, err := loadStore.List("")
+		require.NoError(t, err)
+		require.Equal(t, []string{"key", "other"}, keys)
+	}
+
+	// the purged key forgets the digests and stays deduplicated
+	require.NoError(t, store.Purge("key"))
+	require.NoError(t, store.Set("key", []byte("message-1")))
+	require.NoError(t, store.Set("key", []byte("message-1")))
+	stats, err := store.Stats("key")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, stats.Total)
+
+	// the switched off key keeps the repeats
+	require.NoError(t, store.Dedup("key", 0))
+	require.NoError(t, store.Set("key", []byte("message-1")))
+	stats, err = store.Stats("key")
+	require.NoError(t, err)
+	require.EqualValues(t, 2, stats.Total)
+	require.NoFileExists(t, path+"/key/"+dedupFileName)
+}
+
+func TestStore_DedupExpired(t *testing.T) {
+	store, err := New(&Config{Path: t.TempDir()})
+	require.NoError(t, err)
+	require.NoError(t, store.Dedup("key", time.Millisecond))
+
+	require.NoError(t, store.Set("key", []byte("message")))
+	time.Sleep(5 * time.Millisecond)
+	require.NoError(t, store.Set("key", []byte("message")))
+
+	stats, err := store.Stats("key")
+	require.NoError(t, err)
+	require.EqualValues(t, 2, stats.Total)
+
+	// the expired digests are forgotten
+	d := store.digestsOf("key")
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	require.Len(t, d.sums, 1)
+	require.Len(t, d.order, 1)
+}
+
+func TestStore_DedupShared(t *testing.T) {
+	path := t.TempDir()
+	store, err := New(&Config{Path: path})
+	require.NoError(t, err)
+
+	require.NoError(t, store.Partition("orders", 4))
+	require.NoError(t, store.Dedup("orders", time.Hour))
+	require.NoError(t, store.Dedup("tasks", time.Hour))
+
+	// the partitions share the digests of the key
+	for _, partitionKey := range []string{"a", "b", "c", "d", "e", "f"} {
+		_, err = store.SetPartitioned("orders", partitionKey, []byte("message"))
+		require.NoError(t, err)
+	}
+	stats, err := store.Stats("orders")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, stats.Total)
+
+	// and so do the priority levels
+	require.NoError(t, store.Set("tasks", []byte("message")))
+	require.NoError(t, store.SetPriority("tasks", 5, []byte("message")))
+	require.NoError(t, store.SetPriority("tasks", 9, []byte("message")))
+	stats, err = store.Stats("tasks")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, stats.Total)
+
+	// the digests of the key survive the unloading and the loading
+	require.NoError(t, store.Unload(context.Background()))
+	require.FileExists(t, path+"/orders/"+digestsFileName)
+	require.FileExists(t, path+"/tasks/"+digestsFileName)
+
+	loadStore, err := New(&Config{Path: path})
+	require.NoError(t, err)
+	require.NoError(t, loadStore.Load(context.Background()))
+	_, err = loadStore.SetPartitioned("orders", "g", []byte("message"))
+	require.NoError(t, err)
+	require.NoError(t, loadStore.SetPriority("tasks", 1, []byte("message")))
+
+	for _, key := range []string{"orders", "tasks"} {
+		stats, err = loadStore.Stats(key)
+		require.NoError(t, err)
+		require.EqualValues(t, 1, stats.Total)
+	}
+
+	// the purged partitioned key forgets the digests
+	require.NoError(t, loadStore.Purge("orders"))
+	require.NoFileExists(t, path+"/orders/"+digestsFileName)
+	_, err = loadStore.SetPartitioned("orders", "a", []byte("message"))
+	require.NoError(t, err)
+	stats, err = loadStore.Stats("orders")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, stats.Total)
+}
+
+func TestDedupSum(t *testing.T) {
+	// the digests are the truncated SHA-256 sums of the contents or of the dedup ids
+	sum := dedupSum(nil, []byte("message"))
+	require.Equal(t, "ab530a13e45914982b79f9b7e3fba994", hex.EncodeToString(sum[:]))
+	require.Equal(t, dedupSum(nil, []byte("id")), dedupSum(&produced{dedupID: "id"}, []byte("message")))
+	require.NotEqual(t, sum, dedupSum(nil, []byte("message-2")))
+}
+
+func TestReadDigests(t *testing.T) {
+	path := t.TempDir()
+	seen := time.Unix(0, time.Now().UnixNano())
+	written := []digest{
+		{sum: dedupSum(nil, []byte("message-1")), seen: seen},
+		{sum: dedupSum(nil, []byte("message-2")), seen: seen},
+	}
+	n, err := writeDigests(path, written)
+	require.NoError(t, err)
+	require.EqualValues(t, 4+2*(sumLen+8), n)
+
+	d, err := readDigests(path)
+	require.NoError(t, err)
+	require.Equal(t, written, d.order)
+	require.Len(t, d.sums, 2)
+
+	// the truncated digests file is corrupted
+	bb, err := os.ReadFile(path + "/" + digestsFileName)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path+"/"+digestsFileName, bb[:10], os.ModePerm))
+	_, err = readDigests(path)
+	require.Error(t, err)
+}
@@ -0,0 +1,166 @@
+// Package jellystore
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/internal/pkg/jell"
+)
+
+const (
+	// maxFilterScan messages scanned by a filtered getting at once,
+	// so the key is not locked by scanning the huge queue
+	maxFilterScan = 1 << 14
+	// filterChunk messages read to match at once
+	filterChunk = 256
+)
+
+func (s *Store) GetFiltered(key string, filter jell.Filter, n int64) ([][]byte, int64, error) {
+	if err := validateFilter(filter); err != nil {
+		return nil, 0, err
+	}
+	if err := s.checkPartitioned(key); err != nil {
+		return nil, 0, err
+	}
+	if p := s.prioritiesOf(key); p != nil {
+		return s.getPriorities(key, p, &filter, n)
+	}
+	return s.getFiltered(key, &filter, n, maxFilterScan)
+}
+
+func (s *Store) GetPartitionFiltered(key string, partition int, filter jell.Filter, n int64) ([][]byte, int64, error) {
+	if err := validateFilter(filter); err != nil {
+		return nil, 0, err
+	}
+	name, err := s.partition(key, partition)
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.getFiltered(name, &filter, n, maxFilterScan)
+}
+
+// getFiltered gets up to n uncommitted messages of the key or the partition matching the filter
+// scanning up to the limit messages, returns the messages and the scanned messages
+// up to the last matching one, or all scanned messages if the batch is not got.
+func (s *Store) getFiltered(key string, f *jell.Filter, n, limit int64) ([][]byte, int64, error) {
+	if err := s.ensureLoaded(key); err != nil {
+		return nil, 0, err
+	}
+
+	m, err := s.subject.lock(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer m.mutex.Unlock()
+
+	m.delivered = 0
+	if n <= 0 {
+		return nil, 0, nil
+	}
+	from, to, ok := m.batchBounds(limit)
+	if !ok {
+		return nil, 0, nil
+	}
+	m.touched = time.Now()
+
+	var (
+		bb      [][]byte
+		scanned int64
+	)
+	for i := from; i < to && int64(len(bb)) < n; i += filterChunk {
+		end := i + filterChunk
+		if end > to {
+			end = to
+		}
+
+		chunk, err := s.read(key, m, i, end)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, b := range chunk {
+			scanned++
+			if !match(f, b) {
+				continue
+			}
+
+			bb = append(bb, b)
+			if int64(len(bb)) == n {
+				break
+			}
+		}
+	}
+
+	m.delivered = scanned
+	return bb, scanned, nil
+}
+
+func validateFilter(f jell.Filter) error {
+	if f.Field == "" && f.Value != "" {
+		return errors.New("filter value without field")
+	}
+	return nil
+}
+
+// match reports whether the message matches all conditions of the filter.
+func match(f *jell.Filter, b []byte) bool {
+	if !bytes.HasPrefix(b, f.Prefix) {
+		return false
+	}
+	if !bytes.Contains(b, f.Contains) {
+		return false
+	}
+	if f.Field == "" {
+		return true
+	}
+
+	raw, ok := jsonField(b, f.Field)
+	if !ok {
+		return false
+	}
+
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		return str == f.Value
+	}
+
+	compact := bytes.Buffer{}
+	if err := json.Compact(&compact, raw); err != nil {
+		return false
+	}
+	return compact.String() == f.Value
+}
+
+// jsonField the raw value of the field of the JSON object by the dotted path.
+func jsonField(b []byte, path string) (json.RawMessage, bool) {
+	raw := json.RawMessage(b)
+	for _, name := range strings.Split(path, ".") {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &object); err != nil {
+			return nil, false
+		}
+
+		var ok bool
+		raw, ok = object[name]
+		if !ok {
+			return nil, false
+		}
+	}
+	return raw, true
+}
@@ -0,0 +1,145 @@
+// Package store
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/baibikov/jellydb/internal/pkg/jell"
+)
+
+func TestStore_GetFiltered(t *testing.T) {
+	store, err := New(&Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	for _, value := range []string{
+		`order:1`,
+		`{"type": "refund", "customer": {"id": 42}}`,
+		`payment:1`,
+		`{"type": "order", "customer": {"id": 7}}`,
+		`order:2`,
+		`{"type": "order", "customer": {"id": 42}}`,
+		`payment:2`,
+	} {
+		require.NoError(t, store.Set("events", []byte(value)))
+	}
+
+	_, _, err = store.GetFiltered("events", jell.Filter{Value: "order"}, 10)
+	require.Error(t, err)
+
+	tests := []struct {
+		name    string
+		filter  jell.Filter
+		batch   int64
+		want    []string
+		scanned int64
+	}{
+		{
+			name:    "prefix",
+			filter:  jell.Filter{Prefix: []byte("order:")},
+			batch:   10,
+			want:    []string{`order:1`, `order:2`},
+			scanned: 7,
+		},
+		{
+			name:    "prefix batch",
+			filter:  jell.Filter{Prefix: []byte("order:")},
+			batch:   1,
+			want:    []string{`order:1`},
+			scanned: 1,
+		},
+		{
+			name:    "contains",
+			filter:  jell.Filter{Contains: []byte(":2")},
+			batch:   1,
+			want:    []string{`order:2`},
+			scanned: 5,
+		},
+		{
+			name:    "string field",
+			filter:  jell.Filter{Field: "type", Value: "order"},
+			batch:   10,
+			want:    []string{`{"type": "order", "customer": {"id": 7}}`, `{"type": "order", "customer": {"id": 42}}`},
+			scanned: 7,
+		},
+		{
+			name:    "nested field",
+			filter:  jell.Filter{Field: "customer.id", Value: "42"},
+			batch:   2,
+			want:    []string{`{"type": "refund", "customer": {"id": 42}}`, `{"type": "order", "customer": {"id": 42}}`},
+			scanned: 6,
+		},
+		{
+			name:    "no match",
+			filter:  jell.Filter{Field: "customer", Value: "42"},
+			batch:   10,
+			scanned: 7,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bb, scanned, err := store.GetFiltered("events", tt.filter, tt.batch)
+			require.NoError(t, err)
+			require.Equal(t, tt.scanned, scanned)
+
+			got := make([]string, 0, len(bb))
+			for _, b := range bb {
+				got = append(got, string(b))
+			}
+			require.Equal(t, len(tt.want), len(got))
+			if len(got) > 0 {
+				require.Equal(t, tt.want, got)
+			}
+		})
+	}
+
+	// the commit of the scanned messages advances past the skipped ones
+	_, scanned, err := store.GetFiltered("events", jell.Filter{Prefix: []byte("payment:")}, 1)
+	require.NoError(t, err)
+	require.NoError(t, store.Commit("events", scanned))
+
+	bb, _, err := store.GetFiltered("events", jell.Filter{Prefix: []byte("payment:")}, 1)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("payment:2")}, bb)
+}
+
+func TestStore_GetFilteredPriorities(t *testing.T) {
+	store, err := New(&Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, store.Set("tasks", []byte(fmt.Sprint("low:", i))))
+		require.NoError(t, store.SetPriority("tasks", 2, []byte(fmt.Sprint("high:", i))))
+	}
+
+	filter := jell.Filter{Contains: []byte(":1")}
+	bb, scanned, err := store.GetFiltered("tasks", filter, 10)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("high:1"), []byte("low:1")}, bb)
+	require.Equal(t, int64(6), scanned)
+
+	// the levels are committed by their scanned messages
+	bb, scanned, err = store.GetFiltered("tasks", filter, 1)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("high:1")}, bb)
+	require.Equal(t, int64(2), scanned)
+	require.NoError(t, store.Commit("tasks", scanned))
+
+	bb, err = store.Get("tasks", 10)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("high:2"), []byte("low:0"), []byte("low:1"), []byte("low:2")}, bb)
+}
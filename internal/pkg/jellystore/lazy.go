@@ -0,0 +1,112 @@
+// Package jellystore
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/internal/pkg/jell"
+)
+
+// lazyKey the key registered by the lazy loading, it is loaded on the first access
+type lazyKey struct {
+	once sync.Once
+	err  error
+}
+
+// registerKeys registers the keys to load them on the first access.
+func (s *Store) registerKeys(jobs []loadJob) {
+	p := &s.progress
+	p.keys.Store(int64(len(jobs)))
+	p.loaded.Store(0)
+	p.failed.Store(0)
+	p.bytes.Store(0)
+
+	for _, job := range jobs {
+		if job.store.subject.has(job.key) {
+			// the key is in memory already
+			continue
+		}
+		job.store.lazy.Store(job.key, &lazyKey{})
+	}
+}
+
+// ensureLoaded loads the key registered by the lazy loading,
+// concurrent accesses wait for the loading.
+func (s *Store) ensureLoaded(key string) error {
+	val, ok := s.lazy.Load(key)
+	if !ok {
+		return nil
+	}
+
+	lk, ok := val.(*lazyKey)
+	if !ok {
+		return errors.Errorf("fatal type assertion to lazy key %[1]T %+[1]v", val)
+	}
+
+	lk.once.Do(func() {
+		p := &s.top().progress
+		lk.err = s.loadKey(key)
+		if lk.err != nil {
+			p.failed.Add(1)
+		} else {
+			p.loaded.Add(1)
+		}
+
+		// the key is unregistered after loading, so the concurrent
+		// accesses got the registered key wait for the loading
+		s.lazy.Delete(key)
+	})
+
+	return lk.err
+}
+
+// lazyStats statistics of the registered key by its meta file without loading.
+func (s *Store) lazyStats(key string) (*jell.Stats, error) {
+	metaInfo, err := openMeta(fmt.Sprintf("%s/%s", s.keyPath(key), metaFileName))
+	if err != nil {
+		return nil, err
+	}
+	defer metaInfo.Close()
+
+	writtenOffset, err := metaInfo.written.offset()
+	if err != nil {
+		return nil, err
+	}
+	committedOffset, err := metaInfo.committed.offset()
+	if err != nil {
+		return nil, err
+	}
+
+	diskBytes, err := s.diskBytes(key)
+	if err != nil {
+		return nil, err
+	}
+
+	const slot = messageLen + maxMessageSize
+	stats := &jell.Stats{
+		Total:           writtenOffset.int64() / slot,
+		CommittedOffset: committedOffset.int64(),
+		WrittenOffset:   writtenOffset.int64(),
+		DiskBytes:       diskBytes,
+	}
+	if writtenOffset > committedOffset {
+		stats.Uncommitted = (writtenOffset.int64() - committedOffset.int64()) / slot
+	}
+
+	return stats, nil
+}
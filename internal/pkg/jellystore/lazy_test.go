@@ -0,0 +1,95 @@
+// Package store
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_LazyLoad(t *testing.T) {
+	path := t.TempDir()
+	store, err := New(&Config{Path: path})
+	require.NoError(t, err)
+	team, err := store.Namespace("team")
+	require.NoError(t, err)
+	for _, key := range []string{"get", "set", "commit", "idle"} {
+		require.NoError(t, store.Set(key, []byte("message1")))
+		require.NoError(t, store.Set(key, []byte("message2")))
+	}
+	require.NoError(t, team.Set("get", []byte("message1")))
+	require.NoError(t, store.Unload(context.Background()))
+
+	lazyStore, err := New(&Config{Path: path, LazyLoad: true})
+	require.NoError(t, err)
+	require.NoError(t, lazyStore.Load(context.Background()))
+	require.Equal(t, LoadProgress{Keys: 5}, lazyStore.LoadProgress())
+
+	keys, err := lazyStore.List("")
+	require.NoError(t, err)
+	require.Equal(t, []string{"commit", "get", "idle", "set"}, keys)
+
+	// the statistics don't load the key
+	stats, err := lazyStore.Stats("idle")
+	require.NoError(t, err)
+	require.EqualValues(t, 2, stats.Uncommitted)
+	require.Zero(t, lazyStore.LoadProgress().Loaded)
+
+	// concurrent accesses load the key once
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bb, err := lazyStore.Get("get", 10)
+			require.NoError(t, err)
+			require.Len(t, bb, 2)
+		}()
+	}
+	wg.Wait()
+
+	require.NoError(t, lazyStore.Set("set", []byte("message3")))
+	bb, err := lazyStore.Get("set", 10)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("message1"), []byte("message2"), []byte("message3")}, bb)
+
+	require.NoError(t, lazyStore.Commit("commit", 1))
+	require.Equal(t, LoadProgress{Keys: 5, Loaded: 3, Bytes: 3 * (2*messageLen + 2*(messageLen+maxMessageSize))}, lazyStore.LoadProgress())
+
+	lazyTeam, err := lazyStore.Namespace("team")
+	require.NoError(t, err)
+	bb, err = lazyTeam.Get("get", 10)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("message1")}, bb)
+
+	// the key not loaded is not unloaded
+	require.NoError(t, os.RemoveAll(path+"/idle"))
+	require.NoError(t, lazyStore.Unload(context.Background()))
+	_, err = os.Stat(path + "/idle")
+	require.True(t, os.IsNotExist(err))
+
+	loadStore, err := New(&Config{Path: path})
+	require.NoError(t, err)
+	require.NoError(t, loadStore.Load(context.Background()))
+	bb, err = loadStore.Get("commit", 10)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("message2")}, bb)
+	bb, err = loadStore.Get("set", 10)
+	require.NoError(t, err)
+	require.Len(t, bb, 3)
+}
@@ -15,10 +15,13 @@ package jellystore
 
 import (
 	"context"
-	"encoding/binary"
 	"fmt"
-	"io"
 	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/pkg/errors"
 	"go.uber.org/multierr"
@@ -26,60 +29,292 @@ import (
 )
 
 func (s *Store) Load(ctx context.Context) error {
-	return s.load(ctx)
-}
+	if s.root != nil {
+		return s.root.Load(ctx)
+	}
 
-func (s *Store) load(ctx context.Context) error {
-	entities, err := os.ReadDir(s.config.Path)
+	jobs, err := s.loadJobs()
 	if err != nil {
-		return errors.Wrapf(err, "read dir by path - %s", s.config.Path)
+		return err
 	}
-	if len(entities) == 0 {
-		return nil
+
+	if s.config.LazyLoad {
+		s.registerKeys(jobs)
+	} else {
+		// the keys failed to load don't stop replaying the transactions
+		var loadErr *LoadError
+		err = s.loadKeys(ctx, jobs)
+		if err != nil && !errors.As(err, &loadErr) {
+			return err
+		}
 	}
 
-	left, right := entities[:len(entities)/2], entities[len(entities)/2:]
+	return multierr.Append(err, s.replayTransactions())
+}
 
-	eg := errgroup.Group{}
-	eg.Go(func() error {
-		return s.loadEntities(ctx, left)
-	})
+// LoadProgress the progress of the current or the last loading.
+type LoadProgress struct {
+	// Keys to load of all namespaces.
+	Keys int64
+	// Loaded keys.
+	Loaded int64
+	// Failed keys, see LoadError.
+	Failed int64
+	// Bytes read from the file storage.
+	Bytes int64
+}
 
-	eg.Go(func() error {
-		return s.loadEntities(ctx, right)
-	})
+// LoadProgress getting the progress of the current or the last loading,
+// it may be called concurrently with Load to report the progress.
+func (s *Store) LoadProgress() LoadProgress {
+	p := &s.top().progress
+	return LoadProgress{
+		Keys:   p.keys.Load(),
+		Loaded: p.loaded.Load(),
+		Failed: p.failed.Load(),
+		Bytes:  p.bytes.Load(),
+	}
+}
 
-	return errors.Wrap(eg.Wait(), "load files by entities")
+type loadProgress struct {
+	keys   atomic.Int64
+	loaded atomic.Int64
+	failed atomic.Int64
+	bytes  atomic.Int64
 }
 
-const (
-	logFileName  = "log.jelly.db"
-	metaFileName = "meta.jelly.format"
-)
+// LoadError the keys failed to load, the other keys are loaded.
+// The failed keys are not served and can't be set until they are deleted or purged.
+type LoadError struct {
+	// Keys errors by the keys, the keys of the namespaces are prefixed
+	// by the namespace name: "namespace/key".
+	Keys map[string]error
+}
+
+func (e *LoadError) Error() string {
+	keys := make([]string, 0, len(e.Keys))
+	for key := range e.Keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	ss := make([]string, 0, len(keys))
+	for _, key := range keys {
+		ss = append(ss, fmt.Sprintf("%s: %v", key, e.Keys[key]))
+	}
+	return fmt.Sprintf("failed to load %d keys: %s", len(keys), strings.Join(ss, "; "))
+}
+
+// loadJob the key of the store to load
+type loadJob struct {
+	store *Store
+	key   string
+}
+
+func (j loadJob) String() string {
+	if j.store.name == "" {
+		return j.key
+	}
+	return j.store.name + "/" + j.key
+}
+
+// loadJobs the keys of the store and its namespaces found in the directories.
+func (s *Store) loadJobs() ([]loadJob, error) {
+	jobs, err := s.keyJobs()
+	if err != nil {
+		return nil, err
+	}
+
+	path := fmt.Sprintf("%s/%s", s.config.Path, namespacesDir)
+	entities, err := os.ReadDir(path)
+	if os.IsNotExist(err) {
+		return jobs, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "read dir by path - %s", path)
+	}
 
-func (s *Store) loadEntities(ctx context.Context, entities []os.DirEntry) error {
 	for _, e := range entities {
+		ns, err := s.namespace(e.Name(), true)
+		if err != nil {
+			return nil, err
+		}
+
+		nsJobs, err := ns.keyJobs()
+		if err != nil {
+			return nil, errors.Wrapf(err, "load namespace %s", e.Name())
+		}
+		jobs = append(jobs, nsJobs...)
+	}
+
+	return jobs, nil
+}
+
+// keyJobs the keys found in the directory of the store.
+func (s *Store) keyJobs() ([]loadJob, error) {
+	entities, err := os.ReadDir(s.config.Path)
+	if os.IsNotExist(err) && s.root != nil {
+		// the namespace has not been unloaded yet
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "read dir by path - %s", s.config.Path)
+	}
+
+	jobs := make([]loadJob, 0, len(entities))
+	for _, e := range entities {
+		if e.Name() == namespacesDir || e.Name() == transactionsDir {
+			continue
+		}
+
+		window, err := s.readDedup(e.Name())
+		if err != nil {
+			return nil, err
+		}
+		if window > 0 {
+			s.dedups.Store(e.Name(), window)
+
+			// the digests are shared by the partitions and the priority levels of the key
+			d, err := readDigests(s.keyPath(e.Name()))
+			if err != nil {
+				return nil, err
+			}
+			if d != nil {
+				s.digests.Store(e.Name(), d)
+			}
+		}
+
+		n, err := s.readPartitions(e.Name())
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			jobs = append(jobs, s.partitionJobs(e.Name(), n)...)
+			continue
+		}
+
+		levels, err := s.readPriorities(e.Name())
+		if err != nil {
+			return nil, err
+		}
+		if levels > 0 {
+			jobs = append(jobs, s.priorityJobs(e.Name(), levels)...)
+		}
+
+		// the key deduplicated or having the priorities before its first message
+		// without the priority has no messages to load
+		if window > 0 || levels > 0 {
+			_, err = os.Stat(fmt.Sprintf("%s/%s", s.keyPath(e.Name()), metaFileName))
+			if os.IsNotExist(err) {
+				continue
+			}
+		}
+		jobs = append(jobs, loadJob{store: s, key: e.Name()})
+	}
+	return jobs, nil
+}
+
+// loadConcurrency workers loading the keys
+func (c Config) loadConcurrency() int {
+	if c.LoadConcurrency > 0 {
+		return c.LoadConcurrency
+	}
+	return runtime.NumCPU()
+}
+
+// loadKeys loads the keys by the pool of the workers, the failed keys
+// don't stop the loading and are reported by the LoadError.
+func (s *Store) loadKeys(ctx context.Context, jobs []loadJob) error {
+	p := &s.progress
+	p.keys.Store(int64(len(jobs)))
+	p.loaded.Store(0)
+	p.failed.Store(0)
+	p.bytes.Store(0)
+
+	var (
+		mutex  sync.Mutex
+		failed = make(map[string]error)
+	)
+
+	queue := make(chan loadJob)
+	eg := errgroup.Group{}
+	for i := 0; i < s.config.loadConcurrency(); i++ {
+		eg.Go(func() error {
+			for job := range queue {
+				err := job.store.loadKey(job.key)
+				if err == nil {
+					p.loaded.Add(1)
+					continue
+				}
+
+				p.failed.Add(1)
+				mutex.Lock()
+				failed[job.String()] = err
+				mutex.Unlock()
+			}
+			return nil
+		})
+	}
+
+	var err error
+feed:
+	for _, job := range jobs {
 		select {
 		case <-ctx.Done():
-			return errors.New("failed to load all file data")
-		default:
-			if err := s.loadByFile(e.Name()); err != nil {
-				return err
-			}
+			err = errors.Wrap(ctx.Err(), "failed to load all file data")
+			break feed
+		case queue <- job:
 		}
 	}
-	return nil
+	close(queue)
+	_ = eg.Wait()
+
+	if len(failed) > 0 {
+		err = multierr.Append(err, &LoadError{Keys: failed})
+	}
+	return err
 }
 
+// loadKey loads the key, the failed key is dropped from memory
+// and marked as broken so as not to overwrite its files.
+func (s *Store) loadKey(key string) error {
+	err := s.loadByFile(key)
+	if err == nil {
+		return nil
+	}
+
+	if m, err := s.subject.lock(key); err == nil {
+		s.release(m)
+		s.subject.remove(key, m)
+		m.mutex.Unlock()
+	}
+	s.broken.Store(key, err)
+	return err
+}
+
+// checkBroken checks the key has been loaded without errors.
+func (s *Store) checkBroken(key string) error {
+	val, ok := s.broken.Load(key)
+	if !ok {
+		return nil
+	}
+	return errors.Errorf("key %s failed to load, delete or purge it: %v", key, val)
+}
+
+const (
+	logFileName  = "log.jelly.db"
+	metaFileName = "meta.jelly.format"
+)
+
 const (
 	messageLen = 4
 )
 
 func (s *Store) loadByFile(key string) (err error) {
 	// usable path by db data
-	pdata := fmt.Sprintf("%s/%s/%s", s.config.Path, key, logFileName)
+	pdata := fmt.Sprintf("%s/%s", s.keyPath(key), logFileName)
 
-	metaInfo, err := openMeta(fmt.Sprintf("%s/%s/%s", s.config.Path, key, metaFileName))
+	metaInfo, err := openMeta(fmt.Sprintf("%s/%s", s.keyPath(key), metaFileName))
 	if err != nil {
 		return err
 	}
@@ -95,42 +330,128 @@ func (s *Store) loadByFile(key string) (err error) {
 		return err
 	}
 
-	s.setWrittenOffset(key, writtenOffset.int64(), committedOffset.int64())
+	s.addLoaded(2 * messageLen)
+
+	// the message is locked until the loading of the key is done
+	m, _ := s.subject.lockStore(key)
+	defer m.mutex.Unlock()
+	m.setWrittenOffset(writtenOffset.int64(), committedOffset.int64())
+
+	m.producers, err = readProducers(s.keyPath(key))
+	if err != nil {
+		return err
+	}
 
 	// don't load if committed and written offsets equal
 	if committedOffset.equal(writtenOffset) {
 		return nil
 	}
 
-	logInfo, err := openLog(pdata)
+	logInfo, err := openSlotReader(pdata)
 	if err != nil {
 		return err
 	}
 	defer multierr.AppendInvoke(&err, multierr.Close(logInfo))
 
-	iteration := int64(committedOffset)
-	for {
-		bb := make([]byte, maxMessageSize+messageLen)
-		_, err = logInfo.readAt(bb, iteration)
-		if errors.Is(err, io.EOF) {
-			break
+	size, err := logInfo.size()
+	if err != nil {
+		return err
+	}
+
+	// the slots are decoded by the windows in parallel, so a huge key
+	// is loaded by all workers while the memory held by a window is bounded
+	const slot = messageLen + maxMessageSize
+	workers := s.top().config.loadConcurrency()
+	window := int64(loadWindowSlots * workers)
+	for off := committedOffset.int64(); (size-off)/slot > 0; {
+		n := (size - off) / slot
+		if n > window {
+			n = window
 		}
+
+		bodies, err := decodeSlots(logInfo, off, n, workers)
 		if err != nil {
 			return errors.Wrapf(err, "read messages by key %s from path %s", key, pdata)
 		}
+		s.addLoaded(n * slot)
 
-		length := binary.LittleEndian.Uint32(bb[:messageLen])
-		if messageLen+length > uint32(len(bb)) {
-			return errors.New("message slice mismatch for load")
-		}
+		for _, b := range bodies {
+			// the messages over the memory budget stay in the log from the oldest
+			// ones, the loaded messages are set even if they exceed the quota
+			held := m.held()
+			size := int64(len(b)) + messageOverhead
+			if s.overBudget(size) {
+				// the oldest loaded messages are spilled as the spilling does
+				m.evict(m.len(), s.top().memory.Load()+size-s.top().config.MemoryBudget)
+				s.top().memory.Add(m.held() - held)
+				held = m.held()
+			}
+			if s.overBudget(size) {
+				m.appendSpilled()
+				s.top().memory.Add(m.held() - held)
+				continue
+			}
 
-		err = s.Set(key, bb[messageLen:messageLen+length])
-		if err != nil {
-			return errors.Wrapf(err, "set memorry by key %s from path %s", key, pdata)
+			err = m.append(b)
+			if err != nil {
+				return errors.Wrapf(err, "set memorry by key %s from path %s", key, pdata)
+			}
+			s.top().memory.Add(m.held() - held)
 		}
 
-		iteration += messageLen + maxMessageSize
+		off += n * slot
 	}
 
 	return nil
 }
+
+// loadWindowSlots slots decoded by a worker at once
+const loadWindowSlots = 1024
+
+// decodeSlots decodes n messages of the slots from the offset,
+// the slots are split between the workers.
+func decodeSlots(r slotReader, off, n int64, workers int) ([][]byte, error) {
+	const slot = messageLen + maxMessageSize
+
+	bodies := make([][]byte, n)
+	decode := func(from, to int64) error {
+		for i := from; i < to; i++ {
+			bb, err := r.slot(off + i*slot)
+			if err != nil {
+				return err
+			}
+
+			// the slot may refer to the mapped log, the message is copied
+			b, err := decodeSlot(bb)
+			if err != nil {
+				return err
+			}
+			bodies[i] = append([]byte(nil), b...)
+		}
+		return nil
+	}
+
+	chunk := (n + int64(workers) - 1) / int64(workers)
+	if chunk < loadWindowSlots {
+		return bodies, decode(0, n)
+	}
+
+	eg := errgroup.Group{}
+	for from := int64(0); from < n; from += chunk {
+		from, to := from, from+chunk
+		if to > n {
+			to = n
+		}
+		eg.Go(func() error {
+			return decode(from, to)
+		})
+	}
+
+	return bodies, eg.Wait()
+}
+
+// addLoaded counts the bytes read from the file storage.
+func (s *Store) addLoaded(n int64) {
+	s.loadedBytes.Add(n)
+	s.top().progress.bytes.Add(n)
+}
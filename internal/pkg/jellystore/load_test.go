@@ -15,6 +15,7 @@ package jellystore
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"testing"
 
@@ -134,3 +135,120 @@ func TestStore_Load(t *testing.T) {
 		})
 	}
 }
+
+func TestStore_LoadAfterReload(t *testing.T) {
+	makeTestPath(t)
+
+	const key = "reload-load"
+	err := os.RemoveAll(testPath + "/" + key)
+	require.NoError(t, err)
+
+	unloadStore, err := New(testConfig)
+	require.NoError(t, err)
+	for _, bb := range [][]byte{[]byte("message1"), []byte("message2"), []byte("message3")} {
+		err = unloadStore.Set(key, bb)
+		require.NoError(t, err)
+	}
+	err = unloadStore.Commit(key, 1)
+	require.NoError(t, err)
+	err = unloadStore.Unload(context.Background())
+	require.NoError(t, err)
+
+	// the loaded queue starts with the committed offset,
+	// new messages must be written after the loaded ones
+	reloadStore, err := New(testConfig)
+	require.NoError(t, err)
+	err = reloadStore.Load(context.Background())
+	require.NoError(t, err)
+	err = reloadStore.Commit(key, 1)
+	require.NoError(t, err)
+	err = reloadStore.Unload(context.Background())
+	require.NoError(t, err)
+	err = reloadStore.Set(key, []byte("message4"))
+	require.NoError(t, err)
+	err = reloadStore.Unload(context.Background())
+	require.NoError(t, err)
+
+	loadStore, err := New(testConfig)
+	require.NoError(t, err)
+	err = loadStore.Load(context.Background())
+	require.NoError(t, err)
+
+	bb, err := loadStore.Get(key, 10)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("message3"), []byte("message4")}, bb)
+}
+
+func TestStore_LoadConcurrency(t *testing.T) {
+	// the key is split between the workers
+	const n = 4*loadWindowSlots + 10
+
+	path := t.TempDir()
+	writeTestLog(t, path, n)
+
+	store, err := New(&Config{Path: path, LoadConcurrency: 4})
+	require.NoError(t, err)
+	require.NoError(t, store.Load(context.Background()))
+
+	bb, err := store.Get("key", n+1)
+	require.NoError(t, err)
+	require.Len(t, bb, n)
+	for i, b := range bb {
+		require.Equal(t, fmt.Sprintf("message-%d", i), string(b))
+	}
+
+	progress := store.LoadProgress()
+	require.Equal(t, LoadProgress{
+		Keys:   1,
+		Loaded: 1,
+		Bytes:  2*messageLen + n*(messageLen+maxMessageSize),
+	}, progress)
+}
+
+func TestStore_LoadPartial(t *testing.T) {
+	path := t.TempDir()
+	store, err := New(&Config{Path: path})
+	require.NoError(t, err)
+	team, err := store.Namespace("team")
+	require.NoError(t, err)
+	for _, key := range []string{"good", "broken"} {
+		require.NoError(t, store.Set(key, []byte("message")))
+		require.NoError(t, team.Set(key, []byte("message")))
+	}
+	require.NoError(t, store.Unload(context.Background()))
+
+	// the meta file can't be opened
+	for _, dir := range []string{path, path + "/" + namespacesDir + "/team"} {
+		meta := dir + "/broken/" + metaFileName
+		require.NoError(t, os.Remove(meta))
+		require.NoError(t, os.Mkdir(meta, os.ModePerm))
+	}
+
+	loadStore, err := New(&Config{Path: path, LoadConcurrency: 1})
+	require.NoError(t, err)
+
+	err = loadStore.Load(context.Background())
+	var loadErr *LoadError
+	require.ErrorAs(t, err, &loadErr)
+	require.Len(t, loadErr.Keys, 2)
+	require.Contains(t, loadErr.Keys, "broken")
+	require.Contains(t, loadErr.Keys, "team/broken")
+	require.Equal(t, LoadProgress{Keys: 4, Loaded: 2, Failed: 2, Bytes: 2 * (2*messageLen + messageLen + maxMessageSize)}, loadStore.LoadProgress())
+
+	loadTeam, err := loadStore.Namespace("team")
+	require.NoError(t, err)
+	for _, jelly := range []*Store{loadStore, loadTeam.(*Store)} {
+		bb, err := jelly.Get("good", 1)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("message")}, bb)
+
+		// the files of the broken key are not overwritten
+		keys, err := jelly.List("")
+		require.NoError(t, err)
+		require.Equal(t, []string{"good"}, keys)
+		require.Error(t, jelly.Set("broken", []byte("message")))
+
+		require.NoError(t, jelly.Delete("broken"))
+		require.NoError(t, jelly.Set("broken", []byte("message")))
+	}
+}
@@ -14,18 +14,26 @@
 package jellystore
 
 import (
+	"encoding/binary"
 	"io"
 	"os"
 
 	"github.com/pkg/errors"
-
-	"github.com/baibikov/jellydb/pkg/utils"
 )
 
 type log struct {
 	file *os.File
 }
 
+// slotReader reads the slots of the log by the offsets,
+// io.EOF is returned for the slots out of the log.
+type slotReader interface {
+	slot(off int64) ([]byte, error)
+	// size bytes of the log
+	size() (int64, error)
+	Close() error
+}
+
 func openLog(path string) (*log, error) {
 	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, os.ModePerm)
 	if err != nil {
@@ -50,14 +58,45 @@ func (l *log) readAt(b []byte, off int64) (n int, err error) {
 	return 0, err
 }
 
-func (l *log) write(bb []byte) error {
-	err := utils.Uint32ToWriter(l.file, messageLen, uint32(len(bb)))
+// slot reads the slot by the offset.
+func (l *log) slot(off int64) ([]byte, error) {
+	bb := make([]byte, messageLen+maxMessageSize)
+	_, err := l.readAt(bb, off)
+	if err != nil {
+		return nil, err
+	}
+
+	return bb, nil
+}
+
+func (l *log) size() (int64, error) {
+	info, err := l.file.Stat()
 	if err != nil {
-		return errors.Wrap(err, "write message-len")
+		return 0, errors.Wrap(err, "stating logfile")
+	}
+	return info.Size(), nil
+}
+
+// writeBatch writes the slots of the messages by the single write.
+func (l *log) writeBatch(bbs [][]byte) error {
+	const slot = messageLen + maxMessageSize
+
+	buf := make([]byte, len(bbs)*slot)
+	for i, bb := range bbs {
+		binary.LittleEndian.PutUint32(buf[i*slot:], uint32(len(bb)))
+		copy(buf[i*slot+messageLen:(i+1)*slot], bb)
+	}
+
+	_, err := l.file.Write(buf)
+	return errors.Wrap(err, "write messages")
+}
+
+// decodeSlot decodes the message of the log slot.
+func decodeSlot(bb []byte) ([]byte, error) {
+	length := binary.LittleEndian.Uint32(bb[:messageLen])
+	if messageLen+length > uint32(len(bb)) {
+		return nil, errors.New("message slice mismatch for load")
 	}
 
-	mb := make([]byte, maxMessageSize)
-	copy(mb, bb)
-	_, err = l.file.Write(mb)
-	return errors.Wrap(err, "write message")
+	return bb[messageLen : messageLen+length], nil
 }
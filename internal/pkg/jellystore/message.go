@@ -14,23 +14,206 @@
 package jellystore
 
 import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
 	"github.com/pkg/errors"
 )
 
+// message the queue of the key, the fields are guarded by the mutex
 type message struct {
+	mutex sync.Mutex
+	// removed the message has been removed from the subject,
+	// the accesses waiting for the mutex look up the key again
+	removed bool
+
+	// queue the messages held in memory after the spilled ones,
+	// the indexes of the messages count the spilled ones too
 	queue            [][]byte
 	firstCommitIndex int64
 	lastCommitIndex  int64
 
+	// appended time of appending each message of the queue,
+	// messages restored by loading get the time of loading
+	appended []time.Time
+	// memoryBytes bytes of the queue messages held in memory
+	memoryBytes int64
+	// spilled messages at the start of the queue evicted from memory,
+	// they are dropped from the queue and read from the log
+	spilled int64
+	// spilledTimes times of appending of the spilled messages sampled
+	// by the log indexes, for the age of the oldest message
+	spilledTimes []spilledTime
+	// reader the log opened for reading the spilled messages,
+	// it is closed once the log is written or no message is spilled
+	reader slotReader
+	// touched time of the last setting or getting
+	touched time.Time
+	// delivered messages got by the last getting and not committed yet,
+	// kept for the key getting the priorities before the committing
+	delivered int64
+
 	writtenOffset   int64
 	committedOffset int64
+	// offset of the first queue message in the log
+	offset int64
 
 	writtenIndex   int64
 	committedIndex int64
+
+	// producers the last sequences of the idempotent producers by the ids
+	producers map[string]producer
 }
 
 func (m *message) len() int64 {
-	return int64(len(m.queue))
+	return m.spilled + int64(len(m.queue))
+}
+
+// body the message by the index, nil if it is spilled
+func (m *message) body(i int64) []byte {
+	if i < m.spilled {
+		return nil
+	}
+	return m.queue[i-m.spilled]
+}
+
+// messageOverhead bytes held in memory by every queue message besides its body
+// until it is spilled: the slice of the queue and the time of appending
+const messageOverhead = 48
+
+// held bytes of the message counted by the memory budget: the bodies held
+// in memory and the overhead of the queue messages.
+func (m *message) held() int64 {
+	return m.memoryBytes + messageOverhead*int64(len(m.queue))
+}
+
+// spilledTimeEvery the spilled messages are sampled every so many messages
+const spilledTimeEvery = 1024
+
+// spilledTime time of appending of the spilled message by its index in the log
+type spilledTime struct {
+	index    int64
+	appended time.Time
+}
+
+// sample keeps the time of appending of the message spilled by the index.
+func (m *message) sample(i int64, appended time.Time) {
+	index := m.offset/(messageLen+maxMessageSize) + i
+	n := len(m.spilledTimes)
+	if n > 0 && index-m.spilledTimes[n-1].index < spilledTimeEvery {
+		return
+	}
+	m.spilledTimes = append(m.spilledTimes, spilledTime{index: index, appended: appended})
+}
+
+// appendedAt time of appending of the message by the index, a spilled
+// message gets the time of the message sampled before it.
+func (m *message) appendedAt(i int64) (time.Time, bool) {
+	if i >= m.spilled {
+		i -= m.spilled
+		if i >= int64(len(m.appended)) {
+			return time.Time{}, false
+		}
+		return m.appended[i], true
+	}
+
+	index := m.offset/(messageLen+maxMessageSize) + i
+	j := sort.Search(len(m.spilledTimes), func(j int) bool {
+		return m.spilledTimes[j].index > index
+	}) - 1
+	if j < 0 {
+		return time.Time{}, false
+	}
+	return m.spilledTimes[j].appended, true
+}
+
+// maxLogSize the offsets of the meta file are 4 bytes, the log of a key can't grow over it
+const maxLogSize = math.MaxUint32
+
+// fits checks the n messages fit the log of the key.
+func (m *message) fits(n int64) error {
+	const slot = messageLen + maxMessageSize
+
+	size := m.offset + (m.len()+n)*slot
+	if m.writtenOffset+n*slot > size {
+		size = m.writtenOffset + n*slot
+	}
+	if size > maxLogSize {
+		return errors.Errorf("the log of the key is full (%d bytes), purge or delete the key", int64(maxLogSize))
+	}
+	return nil
+}
+
+// compact drops the committed messages written to the log from the start
+// of the queue, they are not read anymore and the queue starts after them
+// as it does after loading. Unless force, the queue is compacted once half
+// of it can be dropped, so the copying is amortized. Returns the freed bytes
+// counted by the memory budget.
+func (m *message) compact(force bool) int64 {
+	index := m.batchIndex()
+	k := index
+	if k > m.writtenIndex {
+		k = m.writtenIndex
+	}
+	if k > m.len() {
+		k = m.len()
+	}
+	if k <= 0 || (!force && 2*k < m.len()) {
+		return 0
+	}
+
+	// the spilled messages are dropped without the queue
+	before := m.held()
+	r := k - m.spilled
+	if r < 0 {
+		r = 0
+	}
+	for _, b := range m.queue[:r] {
+		m.memoryBytes -= int64(len(b))
+	}
+	m.queue = append(make([][]byte, 0, int64(len(m.queue))-r), m.queue[r:]...)
+	if int64(len(m.appended)) > r {
+		m.appended = append(make([]time.Time, 0, int64(len(m.appended))-r), m.appended[r:]...)
+	} else {
+		m.appended = nil
+	}
+
+	// the indexes are relative to the first queue message
+	m.offset += k * (messageLen + maxMessageSize)
+	m.firstCommitIndex = -1
+	m.lastCommitIndex = index - k
+	m.committedIndex -= k
+	m.writtenIndex -= k
+	m.spilled -= k - r
+
+	// the samples before the first message are dropped but the last one
+	if m.spilled == 0 {
+		m.spilledTimes = nil
+		m.closeReader()
+	}
+	first := m.offset / (messageLen + maxMessageSize)
+	j := 0
+	for j+1 < len(m.spilledTimes) && m.spilledTimes[j+1].index <= first {
+		j++
+	}
+	if j > 0 {
+		m.spilledTimes = append([]spilledTime(nil), m.spilledTimes[j:]...)
+	}
+
+	return before - m.held()
+}
+
+// total messages of the key since the log was created,
+// the messages before the queue were committed before loading
+func (m *message) total() int64 {
+	return m.offset/(messageLen+maxMessageSize) + m.len()
+}
+
+// committed messages of the key since the log was created
+func (m *message) committed() int64 {
+	return m.offset/(messageLen+maxMessageSize) + m.len() - m.uncommitted()
 }
 
 func newMessage() *message {
@@ -40,6 +223,43 @@ func newMessage() *message {
 	}
 }
 
+// reset drops the messages and the offsets, the message stays in the subject.
+func (m *message) reset() {
+	m.queue = make([][]byte, 0)
+	m.firstCommitIndex = -1
+	m.lastCommitIndex = 0
+	m.appended = nil
+	m.memoryBytes = 0
+	m.spilled = 0
+	m.spilledTimes = nil
+	m.closeReader()
+	m.writtenOffset = 0
+	m.committedOffset = 0
+	m.offset = 0
+	m.writtenIndex = 0
+	m.committedIndex = 0
+	m.delivered = 0
+	m.producers = nil
+}
+
+func (m *message) setWrittenOffset(wo, co int64) {
+	m.writtenOffset = wo
+	m.committedOffset = co
+	m.offset = co
+
+	if wo == co {
+		m.writtenIndex = 0
+		return
+	}
+
+	if wo < co {
+		return
+	}
+
+	// offset shift by commented offset with written offset
+	m.writtenIndex = (wo - co) / (messageLen + maxMessageSize)
+}
+
 const maxMessageSize = 512
 
 func (m *message) commit(n int64) {
@@ -47,6 +267,12 @@ func (m *message) commit(n int64) {
 		return
 	}
 
+	// the delivered messages are committed first
+	m.delivered -= n
+	if m.delivered < 0 {
+		m.delivered = 0
+	}
+
 	m.firstCommitIndex = m.lastCommitIndex
 
 	// if committed once message
@@ -69,11 +295,8 @@ func (m *message) commit(n int64) {
 	m.lastCommitIndex = m.lastCommitIndex + n
 }
 
-func (m *message) batch(n int64) [][]byte {
-	if n <= 0 {
-		return nil
-	}
-
+// batchIndex index of the first uncommitted message in the queue
+func (m *message) batchIndex() int64 {
 	index := m.lastCommitIndex
 
 	// case of one committed message
@@ -81,17 +304,48 @@ func (m *message) batch(n int64) [][]byte {
 		index += 1
 	}
 
-	if index > m.len() {
+	return index
+}
+
+func (m *message) uncommitted() int64 {
+	n := m.len() - m.batchIndex()
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+func (m *message) batch(n int64) [][]byte {
+	from, to, ok := m.batchBounds(n)
+	if !ok {
 		return nil
 	}
 
+	bb := make([][]byte, 0, to-from)
+	for i := from; i < to; i++ {
+		bb = append(bb, m.body(i))
+	}
+	return bb
+}
+
+// batchBounds bounds of the batch of n messages in the queue
+func (m *message) batchBounds(n int64) (int64, int64, bool) {
+	if n <= 0 {
+		return 0, 0, false
+	}
+
+	index := m.batchIndex()
+	if index > m.len() {
+		return 0, 0, false
+	}
+
 	sliceUp := index + n
 
 	if n > m.len()-1 || sliceUp > m.len() {
-		return m.queue[index:]
+		return index, m.len(), true
 	}
 
-	return m.queue[index:sliceUp]
+	return index, sliceUp, true
 }
 
 func (m *message) append(b []byte) error {
@@ -101,7 +355,27 @@ func (m *message) append(b []byte) error {
 	if len(b) > maxMessageSize {
 		return errors.Errorf("transmitted message is larger than allowed")
 	}
+	if err := m.fits(1); err != nil {
+		return err
+	}
 
+	now := time.Now()
 	m.queue = append(m.queue, b)
+	m.memoryBytes += int64(len(b))
+	m.appended = append(m.appended, now)
+	m.touched = now
 	return nil
 }
+
+// appendSpilled appends the message kept only in the log,
+// it is spilled unless the queue holds messages in memory.
+func (m *message) appendSpilled() {
+	now := time.Now()
+	if len(m.queue) == 0 {
+		m.sample(m.spilled, now)
+		m.spilled++
+		return
+	}
+	m.queue = append(m.queue, nil)
+	m.appended = append(m.appended, now)
+}
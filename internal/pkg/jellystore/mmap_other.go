@@ -0,0 +1,33 @@
+//go:build !unix
+
+// Package jellystore
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// openSlotReader opens the log for reading the slots,
+// the slots are read by the file reads without the mmap.
+func openSlotReader(path string) (slotReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open logfile by path - %s", path)
+	}
+
+	return &log{file: file}, nil
+}
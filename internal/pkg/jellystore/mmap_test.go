@@ -0,0 +1,120 @@
+// Package store
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestLog writes the store with the log of the key with n messages.
+func writeTestLog(tb testing.TB, path string, n int) {
+	tb.Helper()
+
+	store, err := New(&Config{Path: path})
+	require.NoError(tb, err)
+	for i := 0; i < n; i++ {
+		require.NoError(tb, store.Set("key", []byte(fmt.Sprintf("message-%d", i))))
+	}
+	require.NoError(tb, store.Unload(context.Background()))
+}
+
+func TestSlotReader(t *testing.T) {
+	const n = 100
+
+	path := t.TempDir()
+	writeTestLog(t, path, n)
+
+	logPath := fmt.Sprintf("%s/key/%s", path, logFileName)
+	mapped, err := openSlotReader(logPath)
+	require.NoError(t, err)
+	defer mapped.Close()
+	file, err := openLog(logPath)
+	require.NoError(t, err)
+	defer file.Close()
+
+	for i := 0; i < n; i++ {
+		off := int64(i) * (messageLen + maxMessageSize)
+
+		want, err := file.slot(off)
+		require.NoError(t, err)
+		got, err := mapped.slot(off)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+
+		b, err := decodeSlot(got)
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("message-%d", i), string(b))
+	}
+
+	_, err = mapped.slot(n * (messageLen + maxMessageSize))
+	require.ErrorIs(t, err, io.EOF)
+	_, err = file.slot(n * (messageLen + maxMessageSize))
+	require.ErrorIs(t, err, io.EOF)
+}
+
+// benchmarkLogSlots 100k slots, about 50MB of the log
+const benchmarkLogSlots = 100_000
+
+func BenchmarkSlotReader(b *testing.B) {
+	path := b.TempDir()
+	writeTestLog(b, path, benchmarkLogSlots)
+	logPath := fmt.Sprintf("%s/key/%s", path, logFileName)
+
+	readers := []struct {
+		Name string
+		Open func(path string) (slotReader, error)
+	}{
+		{Name: "readAt", Open: func(path string) (slotReader, error) { return openLog(path) }},
+		{Name: "mmap", Open: openSlotReader},
+	}
+
+	for _, r := range readers {
+		b.Run(r.Name, func(b *testing.B) {
+			b.SetBytes(benchmarkLogSlots * (messageLen + maxMessageSize))
+			for i := 0; i < b.N; i++ {
+				reader, err := r.Open(logPath)
+				require.NoError(b, err)
+
+				for off := int64(0); ; off += messageLen + maxMessageSize {
+					bb, err := reader.slot(off)
+					if err == io.EOF {
+						break
+					}
+					require.NoError(b, err)
+					_, err = decodeSlot(bb)
+					require.NoError(b, err)
+				}
+
+				require.NoError(b, reader.Close())
+			}
+		})
+	}
+}
+
+func BenchmarkStore_Load(b *testing.B) {
+	path := b.TempDir()
+	writeTestLog(b, path, benchmarkLogSlots)
+
+	b.SetBytes(benchmarkLogSlots * (messageLen + maxMessageSize))
+	for i := 0; i < b.N; i++ {
+		store, err := New(&Config{Path: path})
+		require.NoError(b, err)
+		require.NoError(b, store.Load(context.Background()))
+	}
+}
@@ -0,0 +1,83 @@
+//go:build unix
+
+// Package jellystore
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"io"
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// mmapLog reads the slots of the log mapped into memory,
+// the slots written after the opening are not visible.
+type mmapLog struct {
+	data []byte
+}
+
+// openSlotReader opens the log for reading the slots.
+func openSlotReader(path string) (slotReader, error) {
+	return openMmapLog(path)
+}
+
+func openMmapLog(path string) (*mmapLog, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open logfile by path - %s", path)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, errors.Wrapf(err, "stating logfile by path - %s", path)
+	}
+	if info.Size() == 0 {
+		return &mmapLog{}, nil
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, errors.Wrapf(err, "mmap logfile by path - %s", path)
+	}
+
+	return &mmapLog{data: data}, nil
+}
+
+// slot the slot by the offset, the slot refers to the mapped memory
+// and is valid until closing.
+func (l *mmapLog) slot(off int64) ([]byte, error) {
+	end := off + messageLen + maxMessageSize
+	if off < 0 || end > int64(len(l.data)) {
+		return nil, io.EOF
+	}
+
+	return l.data[off:end], nil
+}
+
+func (l *mmapLog) size() (int64, error) {
+	return int64(len(l.data)), nil
+}
+
+func (l *mmapLog) Close() error {
+	if l.data == nil {
+		return nil
+	}
+
+	data := l.data
+	l.data = nil
+	return errors.Wrap(syscall.Munmap(data), "munmap logfile")
+}
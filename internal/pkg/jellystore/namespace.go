@@ -0,0 +1,127 @@
+// Package jellystore
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/internal/pkg/jell"
+)
+
+// namespacesDir directory of the namespaces under the store path,
+// the name is reserved and can't be used as a key.
+const namespacesDir = ".namespaces"
+
+// Namespace getting the store of the namespace, the namespace is created on the first use.
+// Namespaces have their own keys and directories under the store path,
+// an empty name is the default namespace, the store itself.
+// Load and Unload of any namespace store load and unload all namespaces.
+func (s *Store) Namespace(name string) (jell.Jelly, error) {
+	return s.namespace(name, true)
+}
+
+// LookupNamespace getting the store of the existing namespace, the absent one is not created.
+func (s *Store) LookupNamespace(name string) (jell.Jelly, error) {
+	return s.namespace(name, false)
+}
+
+// namespace the store of the namespace, the absent namespace is created if create.
+func (s *Store) namespace(name string, create bool) (*Store, error) {
+	if s.root != nil {
+		return s.root.namespace(name, create)
+	}
+	if name == "" {
+		return s, nil
+	}
+	if err := validateKey(name); err != nil {
+		return nil, errors.Wrap(err, "namespace")
+	}
+
+	val, ok := s.namespaces.Load(name)
+	if !ok && !create {
+		return nil, errors.Wrapf(jell.ErrNamespaceNotFound, "namespace %s", name)
+	}
+	if !ok {
+		val, _ = s.namespaces.LoadOrStore(name, &Store{
+			config: &Config{
+				Path:            fmt.Sprintf("%s/%s/%s", s.config.Path, namespacesDir, name),
+				Shards:          s.config.Shards,
+				DedupWindow:     s.config.DedupWindow,
+				Quota:           s.config.Quota,
+				NamespaceQuotas: s.config.NamespaceQuotas,
+			},
+			subject: newSubject(s.config.shards()),
+			name:    name,
+			root:    s,
+		})
+	}
+
+	ns, ok := val.(*Store)
+	if !ok {
+		return nil, errors.Errorf("fatal type assertion to store %[1]T %+[1]v", val)
+	}
+	return ns, nil
+}
+
+// Namespaces listing of the namespaces except the default one. Namespaces are sorted.
+func (s *Store) Namespaces() ([]string, error) {
+	if s.root != nil {
+		return s.root.Namespaces()
+	}
+
+	names := make([]string, 0)
+	s.namespaces.Range(func(key, _ any) bool {
+		name, _ := key.(string)
+		names = append(names, name)
+		return true
+	})
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// rangeNamespaces calls f for the stores of the namespaces except the default one.
+func (s *Store) rangeNamespaces(f func(ns *Store) error) (err error) {
+	s.namespaces.Range(func(_, value any) bool {
+		ns, _ := value.(*Store)
+		if err = f(ns); err != nil {
+			return false
+		}
+		return true
+	})
+
+	return err
+}
+
+// unloadNamespaces unloads the namespaces, their directories are created
+// if absent, the namespaces without changed keys are not unloaded.
+func (s *Store) unloadNamespaces(ctx context.Context, report *UnloadReport) error {
+	return s.rangeNamespaces(func(ns *Store) error {
+		if !ns.subject.dirty() {
+			return nil
+		}
+
+		err := os.MkdirAll(ns.config.Path, os.ModePerm)
+		if err != nil {
+			return errors.Wrapf(err, "mkdir namespace by path - %s", ns.config.Path)
+		}
+
+		return errors.Wrapf(ns.unload(ctx, report), "unload namespace by path - %s", ns.config.Path)
+	})
+}
@@ -0,0 +1,109 @@
+// Package store
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/baibikov/jellydb/internal/pkg/jell"
+)
+
+func TestStore_Namespace(t *testing.T) {
+	makeTestPath(t)
+
+	const key = "namespace-key"
+	err := os.RemoveAll(testPath + "/" + key)
+	require.NoError(t, err)
+	err = os.RemoveAll(testPath + "/" + namespacesDir)
+	require.NoError(t, err)
+
+	store, err := New(testConfig)
+	require.NoError(t, err)
+
+	team1, err := store.Namespace("team1")
+	require.NoError(t, err)
+	team2, err := store.Namespace("team2")
+	require.NoError(t, err)
+
+	// the same key of the namespaces doesn't collide
+	require.NoError(t, store.Set(key, []byte("default")))
+	require.NoError(t, team1.Set(key, []byte("team1")))
+
+	_, err = team2.Get(key, 1)
+	require.Error(t, err)
+
+	bb, err := team1.Get(key, 1)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("team1")}, bb)
+
+	// the namespace of a namespace is looked up in the store
+	same, err := team2.Namespace("team1")
+	require.NoError(t, err)
+	require.Same(t, team1, same)
+
+	dflt, err := team1.Namespace("")
+	require.NoError(t, err)
+	require.Same(t, store, dflt)
+
+	names, err := team1.Namespaces()
+	require.NoError(t, err)
+	require.Equal(t, []string{"team1", "team2"}, names)
+
+	_, err = store.Namespace("../team1")
+	require.Error(t, err)
+
+	// the lookup doesn't create the namespace
+	found, err := store.LookupNamespace("team1")
+	require.NoError(t, err)
+	require.Same(t, team1, found)
+	_, err = team1.LookupNamespace("team3")
+	require.ErrorIs(t, err, jell.ErrNamespaceNotFound)
+	_, err = store.LookupNamespace("../team3")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, jell.ErrNamespaceNotFound)
+	names, err = store.Namespaces()
+	require.NoError(t, err)
+	require.Equal(t, []string{"team1", "team2"}, names)
+	require.Error(t, store.Set(namespacesDir, []byte("reserved")))
+
+	// unloading of a namespace unloads the whole store
+	require.NoError(t, team1.Unload(context.Background()))
+
+	loadStore, err := New(testConfig)
+	require.NoError(t, err)
+	require.NoError(t, loadStore.Load(context.Background()))
+
+	bb, err = loadStore.Get(key, 1)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("default")}, bb)
+
+	keys, err := loadStore.List(namespacesDir)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	// the empty namespace is not unloaded
+	names, err = loadStore.Namespaces()
+	require.NoError(t, err)
+	require.Equal(t, []string{"team1"}, names)
+
+	loadTeam1, err := loadStore.Namespace("team1")
+	require.NoError(t, err)
+	bb, err = loadTeam1.Get(key, 1)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("team1")}, bb)
+}
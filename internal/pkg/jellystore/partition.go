@@ -0,0 +1,296 @@
+// Package jellystore
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/internal/pkg/jell"
+)
+
+// partitionsFileName file of the partitioned key with the number of its partitions,
+// the partitions are kept as the keys in the numbered directories of the key directory.
+const partitionsFileName = "partitions.jelly.format"
+
+// maxPartitions partitions of a key
+const maxPartitions = 1024
+
+// Partition splitting the key into n partitions, the partitions file is written at once.
+func (s *Store) Partition(key string, n int) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	if n <= 0 || n > maxPartitions {
+		return errors.Errorf("partitions %d of key %s out of range 1-%d", n, key, maxPartitions)
+	}
+	if err := s.checkPrioritized(key); err != nil {
+		return err
+	}
+
+	val, loaded := s.partitions.LoadOrStore(key, n)
+	if loaded {
+		if current, _ := val.(int); current != n {
+			return errors.Errorf("key %s has %d partitions already", key, current)
+		}
+		return nil
+	}
+
+	// the key set without the partitions meanwhile stays not partitioned
+	err := s.checkNotSet(key)
+	if err == nil {
+		err = s.writePartitions(key, n)
+	}
+	if err != nil {
+		s.partitions.Delete(key)
+		return err
+	}
+
+	return nil
+}
+
+// Partitions getting the number of partitions of the key, zero for the key not partitioned.
+func (s *Store) Partitions(key string) (int, error) {
+	return s.partitionsOf(key), nil
+}
+
+func (s *Store) SetPartitioned(key, partitionKey string, value []byte) (int, error) {
+	n := s.partitionsOf(key)
+	if n == 0 {
+		return 0, errors.Errorf("key %s is not partitioned", key)
+	}
+
+	p := partitionOf(partitionKey, n)
+	return p, s.set(partitionName(key, p), value)
+}
+
+// partitionOf the partition of the partition key out of n partitions
+func partitionOf(partitionKey string, n int) int {
+	return int(hashKey(partitionKey) % uint32(n))
+}
+
+func (s *Store) GetPartition(key string, partition int, n int64) ([][]byte, error) {
+	name, err := s.partition(key, partition)
+	if err != nil {
+		return nil, err
+	}
+	return s.get(name, n)
+}
+
+func (s *Store) CommitPartition(key string, partition int, n int64) error {
+	name, err := s.partition(key, partition)
+	if err != nil {
+		return err
+	}
+	return s.commit(name, n)
+}
+
+func (s *Store) partitionsOf(key string) int {
+	val, _ := s.partitions.Load(key)
+	n, _ := val.(int)
+	return n
+}
+
+// partition the key of the partition of the partitioned key
+func (s *Store) partition(key string, partition int) (string, error) {
+	n := s.partitionsOf(key)
+	if n == 0 {
+		return "", errors.Errorf("key %s is not partitioned", key)
+	}
+	if partition < 0 || partition >= n {
+		return "", errors.Errorf("partition %d of key %s out of range 0-%d", partition, key, n-1)
+	}
+
+	return partitionName(key, partition), nil
+}
+
+// partitionName the key of the partition, the partition directory
+// is nested into the directory of the partitioned key
+func partitionName(key string, partition int) string {
+	return fmt.Sprintf("%s/%d", key, partition)
+}
+
+// splitPartitionKey the partitioned key and the partition of the partition key.
+func splitPartitionKey(key string) (string, int, bool) {
+	i := strings.LastIndexByte(key, '/')
+	if i < 0 {
+		return "", 0, false
+	}
+
+	partition, err := strconv.Atoi(key[i+1:])
+	if err != nil {
+		return "", 0, false
+	}
+	return key[:i], partition, true
+}
+
+// checkPartitioned checks the key is not partitioned to be used as is.
+func (s *Store) checkPartitioned(key string) error {
+	if s.partitionsOf(key) > 0 {
+		return errors.Errorf("key %s is partitioned, use its partitions", key)
+	}
+	return nil
+}
+
+// checkPartitionKey checks the key is not partitioned
+// or the partition of the key is the partition of the partitioned key.
+func (s *Store) checkPartitionKey(key string) error {
+	partitioned, partition, ok := splitPartitionKey(key)
+	if !ok {
+		return s.checkPartitioned(key)
+	}
+
+	_, err := s.partition(partitioned, partition)
+	return err
+}
+
+// checkNotSet checks the key has not been set without the partitions.
+func (s *Store) checkNotSet(key string) error {
+	_, lazy := s.lazy.Load(key)
+	_, broken := s.broken.Load(key)
+	_, err := os.Stat(fmt.Sprintf("%s/%s", s.keyPath(key), metaFileName))
+	if s.subject.has(key) || lazy || broken || err == nil {
+		return errors.Errorf("key %s has been set without partitions", key)
+	}
+
+	return nil
+}
+
+func (s *Store) writePartitions(key string, n int) error {
+	path := s.keyPath(key)
+	err := os.MkdirAll(path, os.ModePerm)
+	if err != nil {
+		return errors.Wrapf(err, "mkdir by path - %s", path)
+	}
+
+	bb := make([]byte, messageLen)
+	binary.LittleEndian.PutUint32(bb, uint32(n))
+	path = fmt.Sprintf("%s/%s", path, partitionsFileName)
+	return errors.Wrapf(os.WriteFile(path, bb, os.ModePerm), "write partitions by path - %s", path)
+}
+
+// readPartitions reads the number of partitions of the key directory,
+// zero for the key not partitioned.
+func (s *Store) readPartitions(key string) (int, error) {
+	path := fmt.Sprintf("%s/%s", s.keyPath(key), partitionsFileName)
+	bb, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "read partitions by path - %s", path)
+	}
+	if len(bb) != messageLen {
+		return 0, errors.Errorf("partitions file %s is corrupted", path)
+	}
+
+	return int(binary.LittleEndian.Uint32(bb)), nil
+}
+
+// partitionJobs registers the partitions of the partitioned key found in the directory,
+// the partitions having the directories are loaded.
+func (s *Store) partitionJobs(key string, n int) []loadJob {
+	s.partitions.Store(key, n)
+
+	jobs := make([]loadJob, 0, n)
+	for p := 0; p < n; p++ {
+		name := partitionName(key, p)
+		if _, err := os.Stat(s.keyPath(name)); err == nil {
+			jobs = append(jobs, loadJob{store: s, key: name})
+		}
+	}
+	return jobs
+}
+
+// deletePartitions removes the partitions of the key with the key directory.
+func (s *Store) deletePartitions(key string, n int) error {
+	// the partitions set meanwhile are rejected
+	s.partitions.Delete(key)
+
+	// the partitions stay locked until the directory is removed
+	locked := make([]*message, 0, n)
+	for p := 0; p < n; p++ {
+		name := partitionName(key, p)
+		_ = s.ensureLoaded(name)
+
+		m, _ := s.subject.lockStore(name)
+		m.closeReader()
+		locked = append(locked, m)
+	}
+	defer func() {
+		for _, m := range locked {
+			m.mutex.Unlock()
+		}
+	}()
+
+	_, err := s.removeKeyPath(key)
+	if err != nil {
+		s.partitions.Store(key, n)
+		return err
+	}
+
+	for p, m := range locked {
+		name := partitionName(key, p)
+		s.release(m)
+		s.subject.remove(name, m)
+		s.broken.Delete(name)
+	}
+	s.dedups.Delete(key)
+
+	return nil
+}
+
+// purgePartitions drops the messages of the partitions of the key, the key stays partitioned.
+func (s *Store) purgePartitions(key string, n int) error {
+	for p := 0; p < n; p++ {
+		_, err := s.purge(partitionName(key, p))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// partitionStats statistics of the partitioned key summed by the partitions.
+func (s *Store) partitionStats(key string, n int) (*jell.Stats, error) {
+	stats := &jell.Stats{Partitions: n}
+	for p := 0; p < n; p++ {
+		name := partitionName(key, p)
+		if _, ok := s.lazy.Load(name); !ok && !s.subject.has(name) {
+			// the partition has not been set yet
+			continue
+		}
+
+		ps, err := s.stats(name)
+		if err != nil {
+			return nil, err
+		}
+
+		stats.Total += ps.Total
+		stats.Uncommitted += ps.Uncommitted
+		stats.MemoryBytes += ps.MemoryBytes
+		stats.DiskBytes += ps.DiskBytes
+		if ps.OldestAge > stats.OldestAge {
+			stats.OldestAge = ps.OldestAge
+		}
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,127 @@
+// Package store
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Partition(t *testing.T) {
+	const (
+		partitions = 4
+		customers  = 10
+		messages   = 3
+	)
+
+	path := t.TempDir()
+	store, err := New(&Config{Path: path})
+	require.NoError(t, err)
+
+	require.NoError(t, store.Partition("orders", partitions))
+	require.NoError(t, store.Partition("orders", partitions))
+	require.Error(t, store.Partition("orders", partitions+1))
+	require.NoError(t, store.Set("plain", []byte("message")))
+	require.Error(t, store.Partition("plain", partitions))
+
+	// the partitioned key is used by its partitions only
+	require.Error(t, store.Set("orders", []byte("message")))
+	_, err = store.Get("orders", 1)
+	require.Error(t, err)
+	_, err = store.SetPartitioned("plain", "customer", []byte("message"))
+	require.Error(t, err)
+	_, err = store.GetPartition("orders", partitions, 1)
+	require.Error(t, err)
+
+	// the messages of a partition key go to the same partition in order
+	byPartition := make(map[int][]string)
+	for i := 0; i < messages; i++ {
+		for c := 0; c < customers; c++ {
+			customer := fmt.Sprint("customer-", c)
+			p, err := store.SetPartitioned("orders", customer, []byte(fmt.Sprintf("%s-%d", customer, i)))
+			require.NoError(t, err)
+			byPartition[p] = append(byPartition[p], fmt.Sprintf("%s-%d", customer, i))
+		}
+	}
+	require.Greater(t, len(byPartition), 1)
+
+	check := func(t *testing.T, store *Store) {
+		for p := 0; p < partitions; p++ {
+			bb, err := store.GetPartition("orders", p, customers*messages)
+			require.NoError(t, err)
+
+			got := make([]string, 0, len(bb))
+			for _, b := range bb {
+				got = append(got, string(b))
+			}
+			require.Equal(t, len(byPartition[p]), len(got))
+			if len(got) > 0 {
+				require.Equal(t, byPartition[p], got)
+			}
+		}
+
+		keys, err := store.List("")
+		require.NoError(t, err)
+		require.Equal(t, []string{"orders", "plain"}, keys)
+
+		n, err := store.Partitions("orders")
+		require.NoError(t, err)
+		require.Equal(t, partitions, n)
+
+		stats, err := store.Stats("orders")
+		require.NoError(t, err)
+		require.Equal(t, partitions, stats.Partitions)
+		require.EqualValues(t, customers*messages, stats.Total)
+	}
+	check(t, store)
+	require.NoError(t, store.Unload(context.Background()))
+
+	for _, lazy := range []bool{false, true} {
+		t.Run(fmt.Sprint("lazy-", lazy), func(t *testing.T) {
+			loadStore, err := New(&Config{Path: path, LazyLoad: lazy})
+			require.NoError(t, err)
+			require.NoError(t, loadStore.Load(context.Background()))
+			check(t, loadStore)
+		})
+	}
+
+	// the commits are made by the partitions
+	for p := range byPartition {
+		require.NoError(t, store.CommitPartition("orders", p, 1))
+	}
+	stats, err := store.Stats("orders")
+	require.NoError(t, err)
+	require.EqualValues(t, customers*messages-len(byPartition), stats.Uncommitted)
+
+	// the purged key stays partitioned, the deleted one is removed with the partitions
+	require.NoError(t, store.Purge("orders"))
+	stats, err = store.Stats("orders")
+	require.NoError(t, err)
+	require.Zero(t, stats.Total)
+	_, err = store.SetPartitioned("orders", "customer", []byte("message"))
+	require.NoError(t, err)
+
+	require.NoError(t, store.Delete("orders"))
+	n, err := store.Partitions("orders")
+	require.NoError(t, err)
+	require.Zero(t, n)
+	keys, err := store.List("")
+	require.NoError(t, err)
+	require.Equal(t, []string{"plain"}, keys)
+	require.NoDirExists(t, path+"/orders")
+	require.NoError(t, store.Set("orders", []byte("message")))
+}
@@ -0,0 +1,382 @@
+// Package jellystore
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/internal/pkg/jell"
+)
+
+// prioritiesFileName file of the key having the priorities with the highest priority level,
+// the levels over zero are kept as the keys in the "p<level>" directories of the key directory.
+const prioritiesFileName = "priorities.jelly.format"
+
+// maxPriority the highest priority level, the messages set without the priority have the zero level
+const maxPriority = 9
+
+// priorities the priority levels of the key, the gettings and the committings
+// of the key are serialized by the mutex. The committing commits the messages
+// delivered by the last getting of the levels first, so the messages of a higher level
+// set between the getting and the committing are not committed instead.
+type priorities struct {
+	mutex sync.Mutex
+	// levels the highest priority level set
+	levels int
+}
+
+func (s *Store) SetPriority(key string, priority int, value []byte) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	if err := s.checkPartitioned(key); err != nil {
+		return err
+	}
+	if priority < 0 || priority > maxPriority {
+		return errors.Errorf("priority %d of key %s out of range 0-%d", priority, key, maxPriority)
+	}
+	if priority == 0 {
+		return s.set(key, value)
+	}
+
+	err := s.prioritize(key, priority)
+	if err != nil {
+		return err
+	}
+	return s.set(priorityName(key, priority), value)
+}
+
+// prioritize registers the priority level of the key, the priorities file
+// is written when the highest level grows.
+func (s *Store) prioritize(key string, priority int) error {
+	val, loaded := s.priorities.LoadOrStore(key, &priorities{levels: priority})
+	p, ok := val.(*priorities)
+	if !ok {
+		return errors.Errorf("fatal type assertion to priorities %[1]T %+[1]v", val)
+	}
+	if !loaded {
+		err := s.writePriorities(key, priority)
+		if err != nil {
+			s.priorities.Delete(key)
+		}
+		return err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if priority <= p.levels {
+		return nil
+	}
+	err := s.writePriorities(key, priority)
+	if err != nil {
+		return err
+	}
+	p.levels = priority
+	return nil
+}
+
+// prioritiesOf the priorities of the key, nil for the key without the priorities.
+func (s *Store) prioritiesOf(key string) *priorities {
+	val, _ := s.priorities.Load(key)
+	p, _ := val.(*priorities)
+	return p
+}
+
+// getPriorities gets the uncommitted messages of the levels from the highest one matching
+// the filter if any, the messages of a level keep their order. Returns the messages
+// and the scanned messages, the scanned messages of the levels are delivered.
+func (s *Store) getPriorities(key string, p *priorities, f *jell.Filter, n int64) ([][]byte, int64, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var (
+		bb      [][]byte
+		scanned int64
+	)
+	for level := p.levels; level >= 0; level-- {
+		name := priorityName(key, level)
+		if !s.exists(name) {
+			continue
+		}
+		// the filtered levels share the scanned messages limit, so the lower level
+		// is not scanned before the higher one is scanned through
+		if int64(len(bb)) >= n || (f != nil && scanned >= maxFilterScan) {
+			s.undeliver(name)
+			continue
+		}
+
+		var (
+			got [][]byte
+			ls  int64
+			err error
+		)
+		if f != nil {
+			got, ls, err = s.getFiltered(name, f, n-int64(len(bb)), maxFilterScan-scanned)
+		} else {
+			got, err = s.get(name, n-int64(len(bb)))
+			ls = int64(len(got))
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		scanned += ls
+		bb = append(bb, got...)
+	}
+
+	return bb, scanned, nil
+}
+
+// commitPriorities commits the messages delivered by the last getting first,
+// the rest of the batch is committed from the highest level.
+func (s *Store) commitPriorities(key string, p *priorities, n int64) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for level := p.levels; level >= 0 && n > 0; level-- {
+		committed, err := s.commitLevel(priorityName(key, level), n, true)
+		if err != nil {
+			return err
+		}
+		n -= committed
+	}
+
+	for level := p.levels; level >= 0 && n > 0; level-- {
+		committed, err := s.commitLevel(priorityName(key, level), n, false)
+		if err != nil {
+			return err
+		}
+		n -= committed
+	}
+
+	return nil
+}
+
+// commitLevel commits up to n uncommitted messages of the level, up to the delivered
+// messages of the level for the delivered ones, returns the committed messages.
+func (s *Store) commitLevel(name string, n int64, delivered bool) (int64, error) {
+	if n <= 0 || !s.exists(name) {
+		return 0, nil
+	}
+	if err := s.ensureLoaded(name); err != nil {
+		return 0, err
+	}
+
+	m, err := s.subject.lock(name)
+	if err != nil {
+		return 0, err
+	}
+	defer m.mutex.Unlock()
+
+	if delivered && n > m.delivered {
+		n = m.delivered
+	}
+	if u := m.uncommitted(); n > u {
+		n = u
+	}
+	m.commit(n)
+	s.top().memory.Add(-m.compact(false))
+	s.markDirty(name)
+	return n, nil
+}
+
+// undeliver drops the delivered messages of the level not got by the last getting,
+// the level not loaded yet has not been got.
+func (s *Store) undeliver(name string) {
+	m, err := s.subject.lock(name)
+	if err != nil {
+		return
+	}
+	m.delivered = 0
+	m.mutex.Unlock()
+}
+
+// exists reports whether the key is in memory or registered by the lazy loading.
+func (s *Store) exists(key string) bool {
+	_, lazy := s.lazy.Load(key)
+	return lazy || s.subject.has(key)
+}
+
+// priorityName the key of the priority level, the zero level is the key itself
+// and the directories of the other levels are nested into the directory of the key
+func priorityName(key string, level int) string {
+	if level == 0 {
+		return key
+	}
+	return fmt.Sprintf("%s/p%d", key, level)
+}
+
+// splitPriorityKey the key and the level of the key of the priority level over zero.
+func splitPriorityKey(key string) (string, int, bool) {
+	i := strings.LastIndexByte(key, '/')
+	if i < 0 || !strings.HasPrefix(key[i+1:], "p") {
+		return "", 0, false
+	}
+
+	level, err := strconv.Atoi(key[i+2:])
+	if err != nil {
+		return "", 0, false
+	}
+	return key[:i], level, true
+}
+
+// checkPrioritized checks the key has no priorities to be committed as is.
+func (s *Store) checkPrioritized(key string) error {
+	if s.prioritiesOf(key) != nil {
+		return errors.Errorf("key %s has priorities", key)
+	}
+	return nil
+}
+
+func (s *Store) writePriorities(key string, levels int) error {
+	path := s.keyPath(key)
+	err := os.MkdirAll(path, os.ModePerm)
+	if err != nil {
+		return errors.Wrapf(err, "mkdir by path - %s", path)
+	}
+
+	bb := make([]byte, messageLen)
+	binary.LittleEndian.PutUint32(bb, uint32(levels))
+	path = fmt.Sprintf("%s/%s", path, prioritiesFileName)
+	return errors.Wrapf(os.WriteFile(path, bb, os.ModePerm), "write priorities by path - %s", path)
+}
+
+// readPriorities reads the highest priority level of the key directory,
+// zero for the key without the priorities.
+func (s *Store) readPriorities(key string) (int, error) {
+	path := fmt.Sprintf("%s/%s", s.keyPath(key), prioritiesFileName)
+	bb, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "read priorities by path - %s", path)
+	}
+	if len(bb) != messageLen {
+		return 0, errors.Errorf("priorities file %s is corrupted", path)
+	}
+
+	levels := int(binary.LittleEndian.Uint32(bb))
+	if levels > maxPriority {
+		return 0, errors.Errorf("priorities file %s is corrupted", path)
+	}
+	return levels, nil
+}
+
+// priorityJobs registers the priority levels of the key found in the directory,
+// the levels over zero having the directories are loaded.
+func (s *Store) priorityJobs(key string, levels int) []loadJob {
+	s.priorities.Store(key, &priorities{levels: levels})
+
+	jobs := make([]loadJob, 0, levels)
+	for level := 1; level <= levels; level++ {
+		name := priorityName(key, level)
+		if _, err := os.Stat(s.keyPath(name)); err == nil {
+			jobs = append(jobs, loadJob{store: s, key: name})
+		}
+	}
+	return jobs
+}
+
+// deletePriorities removes the priority levels of the key with the key directory.
+func (s *Store) deletePriorities(key string, p *priorities) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	// the levels stay locked until the directory is removed
+	locked := make([]*message, 0, p.levels+1)
+	for level := 0; level <= p.levels; level++ {
+		name := priorityName(key, level)
+		_ = s.ensureLoaded(name)
+
+		m, _ := s.subject.lockStore(name)
+		m.closeReader()
+		locked = append(locked, m)
+	}
+	defer func() {
+		for _, m := range locked {
+			m.mutex.Unlock()
+		}
+	}()
+
+	_, err := s.removeKeyPath(key)
+	if err != nil {
+		return err
+	}
+	s.priorities.Delete(key)
+
+	for level, m := range locked {
+		name := priorityName(key, level)
+		s.release(m)
+		s.subject.remove(name, m)
+		s.broken.Delete(name)
+	}
+	s.dedups.Delete(key)
+
+	return nil
+}
+
+// purgePriorities drops the messages of the priority levels of the key, the key keeps the priorities.
+func (s *Store) purgePriorities(key string, p *priorities) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	// the key directory is removed by purging the zero level, so it is the last one
+	for level := p.levels; level >= 0; level-- {
+		_, err := s.purge(priorityName(key, level))
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.writePriorities(key, p.levels)
+}
+
+// priorityStats statistics of the key having the priorities summed by the levels.
+func (s *Store) priorityStats(key string, p *priorities) (*jell.Stats, error) {
+	p.mutex.Lock()
+	levels := p.levels
+	p.mutex.Unlock()
+
+	stats := &jell.Stats{Priorities: levels}
+	for level := 0; level <= levels; level++ {
+		name := priorityName(key, level)
+		if !s.exists(name) {
+			// the level has not been set yet
+			continue
+		}
+
+		ls, err := s.stats(name)
+		if err != nil {
+			return nil, err
+		}
+
+		stats.Total += ls.Total
+		stats.Uncommitted += ls.Uncommitted
+		stats.MemoryBytes += ls.MemoryBytes
+		stats.DiskBytes += ls.DiskBytes
+		if ls.OldestAge > stats.OldestAge {
+			stats.OldestAge = ls.OldestAge
+		}
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,136 @@
+// Package store
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Priority(t *testing.T) {
+	path := t.TempDir()
+	store, err := New(&Config{Path: path})
+	require.NoError(t, err)
+
+	require.Error(t, store.SetPriority("tasks", maxPriority+1, []byte("message")))
+	require.NoError(t, store.Set("tasks", []byte("low-1")))
+	require.NoError(t, store.SetPriority("tasks", 5, []byte("high-1")))
+	require.NoError(t, store.SetPriority("tasks", 1, []byte("middle-1")))
+	require.NoError(t, store.SetPriority("tasks", 5, []byte("high-2")))
+	require.NoError(t, store.SetPriority("tasks", 0, []byte("low-2")))
+
+	// the key having the priorities is not partitioned
+	require.Error(t, store.Partition("tasks", 2))
+	// nor committed by the transactions
+	tx, err := store.Begin()
+	require.NoError(t, err)
+	require.Error(t, store.TxCommit(tx, "tasks", 1))
+	require.NoError(t, store.Abort(tx))
+
+	bb, err := store.Get("tasks", 3)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("high-1"), []byte("high-2"), []byte("middle-1")}, bb)
+
+	// the message of a higher level set meanwhile is not committed instead of the got ones
+	require.NoError(t, store.SetPriority("tasks", 9, []byte("urgent-1")))
+	require.NoError(t, store.Commit("tasks", 3))
+
+	bb, err = store.Get("tasks", 10)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("urgent-1"), []byte("low-1"), []byte("low-2")}, bb)
+	require.NoError(t, store.Commit("tasks", 1))
+
+	stats, err := store.Stats("tasks")
+	require.NoError(t, err)
+	require.Equal(t, 9, stats.Priorities)
+	require.Equal(t, int64(6), stats.Total)
+	require.Equal(t, int64(2), stats.Uncommitted)
+
+	keys, err := store.List("")
+	require.NoError(t, err)
+	require.Equal(t, []string{"tasks"}, keys)
+
+	// the levels and their commits round-trip through the file storage
+	require.NoError(t, store.SetPriority("tasks", 3, []byte("middle-2")))
+	require.NoError(t, store.Unload(context.Background()))
+
+	for _, lazy := range []bool{false, true} {
+		loaded, err := New(&Config{Path: path, LazyLoad: lazy})
+		require.NoError(t, err)
+		require.NoError(t, loaded.Load(context.Background()))
+
+		bb, err = loaded.Get("tasks", 10)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("middle-2"), []byte("low-1"), []byte("low-2")}, bb)
+
+		stats, err = loaded.Stats("tasks")
+		require.NoError(t, err)
+		require.Equal(t, 9, stats.Priorities)
+		require.Equal(t, int64(3), stats.Uncommitted)
+	}
+}
+
+func TestStore_PriorityPurgeDelete(t *testing.T) {
+	path := t.TempDir()
+	store, err := New(&Config{Path: path})
+	require.NoError(t, err)
+
+	// the key having only the messages of the priority levels
+	require.NoError(t, store.SetPriority("tasks", 2, []byte("high")))
+	require.NoError(t, store.Unload(context.Background()))
+
+	require.NoError(t, store.Purge("tasks"))
+	bb, err := store.Get("tasks", 10)
+	require.NoError(t, err)
+	require.Empty(t, bb)
+
+	// the key keeps the priorities after purging
+	require.NoError(t, store.SetPriority("tasks", 1, []byte("middle")))
+	require.NoError(t, store.Set("tasks", []byte("low")))
+	require.NoError(t, store.Unload(context.Background()))
+
+	loaded, err := New(&Config{Path: path})
+	require.NoError(t, err)
+	require.NoError(t, loaded.Load(context.Background()))
+	bb, err = loaded.Get("tasks", 10)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("middle"), []byte("low")}, bb)
+
+	require.NoError(t, loaded.Delete("tasks"))
+	_, err = loaded.Get("tasks", 10)
+	require.Error(t, err)
+	keys, err := loaded.List("")
+	require.NoError(t, err)
+	require.Empty(t, keys)
+}
+
+func TestStore_PriorityAfterGetting(t *testing.T) {
+	store, err := New(&Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	// the messages got before the key has the priorities are committed first
+	require.NoError(t, store.Set("tasks", []byte("low")))
+	bb, err := store.Get("tasks", 1)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("low")}, bb)
+
+	require.NoError(t, store.SetPriority("tasks", 5, []byte("high")))
+	require.NoError(t, store.Commit("tasks", 1))
+
+	bb, err = store.Get("tasks", 10)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("high")}, bb)
+}
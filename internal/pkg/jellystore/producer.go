@@ -0,0 +1,192 @@
+// Package jellystore
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// producersFileName file of the key with the last sequences of its idempotent producers
+const producersFileName = "producers.jelly.format"
+
+// maxProducerLen bytes of the id of the idempotent producer
+const maxProducerLen = 256
+
+// produced the message of the idempotent producer or deduplicated by the id
+type produced struct {
+	producer string
+	sequence int64
+	// dedupID deduplicates the message instead of the content
+	dedupID string
+}
+
+// producer the last sequence set by the idempotent producer of the key
+type producer struct {
+	sequence int64
+	// seen time of the last message of the producer
+	seen time.Time
+}
+
+// SetIdempotent setting the value by the idempotent producer, the value of the sequence
+// not greater than the last one of the producer is ignored, reports whether it is ignored.
+func (s *Store) SetIdempotent(key, producer string, sequence int64, value []byte) (bool, error) {
+	if err := validateKey(key); err != nil {
+		return false, err
+	}
+	if err := s.checkPartitioned(key); err != nil {
+		return false, err
+	}
+	if producer == "" || len(producer) > maxProducerLen {
+		return false, errors.Errorf("producer length out of range 1-%d", maxProducerLen)
+	}
+	if sequence < 0 {
+		return false, errors.Errorf("sequence %d of producer %s is negative", sequence, producer)
+	}
+
+	return s.produce(key, &produced{producer: producer, sequence: sequence}, [][]byte{value})
+}
+
+// duplicate reports whether the message of the producer has been set to the key.
+func (s *Store) duplicate(key string, p *produced) bool {
+	if p == nil {
+		return false
+	}
+
+	m, err := s.subject.lock(key)
+	if err != nil {
+		return false
+	}
+	defer m.mutex.Unlock()
+
+	return m.duplicate(p, time.Now(), s.config.dedupWindow())
+}
+
+// duplicate reports whether the message of the producer has been set,
+// the producers not seen within the window are forgotten.
+func (m *message) duplicate(p *produced, now time.Time, window time.Duration) bool {
+	if p == nil {
+		return false
+	}
+
+	if p.producer == "" {
+		return false
+	}
+
+	last, ok := m.producers[p.producer]
+	if !ok || now.Sub(last.seen) > window {
+		return false
+	}
+	return p.sequence <= last.sequence
+}
+
+// produce remembers the sequence of the message of the producer.
+func (m *message) produce(p *produced, now time.Time) {
+	if p == nil || p.producer == "" {
+		return
+	}
+
+	if m.producers == nil {
+		m.producers = make(map[string]producer)
+	}
+	m.producers[p.producer] = producer{sequence: p.sequence, seen: now}
+}
+
+// expireProducers forgets the producers not seen within the window.
+func (m *message) expireProducers(now time.Time, window time.Duration) {
+	for id, p := range m.producers {
+		if now.Sub(p.seen) > window {
+			delete(m.producers, id)
+		}
+	}
+}
+
+// writeProducers writes the producers of the key directory: the number of the producers
+// and every producer by the id, the sequence and the unix time of the last message, little endian.
+// Returns the written bytes.
+func writeProducers(dirPath string, producers map[string]producer) (int64, error) {
+	path := fmt.Sprintf("%s/%s", dirPath, producersFileName)
+	if len(producers) == 0 {
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return 0, errors.Wrapf(err, "remove producers by path - %s", path)
+		}
+		return 0, nil
+	}
+
+	buf := &bytes.Buffer{}
+	write := func(v any) {
+		_ = binary.Write(buf, binary.LittleEndian, v)
+	}
+
+	write(uint32(len(producers)))
+	for id, p := range producers {
+		write(uint32(len(id)))
+		buf.WriteString(id)
+		write(p.sequence)
+		write(p.seen.UnixNano())
+	}
+
+	err := os.WriteFile(path, buf.Bytes(), os.ModePerm)
+	if err != nil {
+		return 0, errors.Wrapf(err, "write producers by path - %s", path)
+	}
+	return int64(buf.Len()), nil
+}
+
+// readProducers reads the producers of the key directory, nil without the producers file.
+func readProducers(dirPath string) (map[string]producer, error) {
+	path := fmt.Sprintf("%s/%s", dirPath, producersFileName)
+	bb, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "read producers by path - %s", path)
+	}
+
+	buf := bytes.NewReader(bb)
+	read := func(v any) error {
+		return binary.Read(buf, binary.LittleEndian, v)
+	}
+	corrupted := errors.Errorf("producers file %s is corrupted", path)
+
+	var n uint32
+	if read(&n) != nil {
+		return nil, corrupted
+	}
+
+	producers := make(map[string]producer, n)
+	for i := uint32(0); i < n; i++ {
+		var idLen uint32
+		if read(&idLen) != nil || idLen > maxProducerLen || int64(idLen) > int64(buf.Len()) {
+			return nil, corrupted
+		}
+		id := make([]byte, idLen)
+		_, _ = buf.Read(id)
+
+		var sequence, seen int64
+		if read(&sequence) != nil || read(&seen) != nil {
+			return nil, corrupted
+		}
+		producers[string(id)] = producer{sequence: sequence, seen: time.Unix(0, seen)}
+	}
+
+	return producers, nil
+}
@@ -0,0 +1,78 @@
+// Package store
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_SetIdempotent(t *testing.T) {
+	path := t.TempDir()
+	store, err := New(&Config{Path: path, Quota: Quota{KeyMessages: 2}})
+	require.NoError(t, err)
+
+	set := func(t *testing.T, store *Store, producer string, sequence int64, want bool) {
+		t.Helper()
+		duplicate, err := store.SetIdempotent("key", producer, sequence, []byte(producer))
+		require.NoError(t, err)
+		require.Equal(t, want, duplicate)
+	}
+
+	set(t, store, "producer-1", 1, false)
+	// the retried message is ignored before the quota rejects it
+	set(t, store, "producer-1", 1, true)
+	set(t, store, "producer-2", 1, false)
+	set(t, store, "producer-2", 0, true)
+	_, err = store.SetIdempotent("key", "producer-1", 3, []byte("message"))
+	require.Error(t, err)
+
+	_, err = store.SetIdempotent("key", "", 1, []byte("message"))
+	require.Error(t, err)
+	_, err = store.SetIdempotent("key", "producer-1", -1, []byte("message"))
+	require.Error(t, err)
+
+	require.NoError(t, store.Commit("key", 2))
+	require.NoError(t, store.Unload(context.Background()))
+	require.FileExists(t, path+"/key/"+producersFileName)
+
+	// the sequences survive the unloading and the loading
+	for _, lazy := range []bool{false, true} {
+		loadStore, err := New(&Config{Path: path, LazyLoad: lazy})
+		require.NoError(t, err)
+		require.NoError(t, loadStore.Load(context.Background()))
+
+		set(t, loadStore, "producer-1", 1, true)
+		set(t, loadStore, "producer-2", 1, true)
+		set(t, loadStore, "producer-1", 5, false)
+
+		bb, err := loadStore.Get("key", 10)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("producer-1")}, bb)
+	}
+
+	// the producers not seen within the window are forgotten
+	expiring, err := New(&Config{Path: path, DedupWindow: time.Nanosecond})
+	require.NoError(t, err)
+	require.NoError(t, expiring.Load(context.Background()))
+	set(t, expiring, "producer-1", 1, false)
+
+	// the purged key forgets the producers
+	require.NoError(t, store.Purge("key"))
+	require.NoFileExists(t, path+"/key/"+producersFileName)
+	set(t, store, "producer-2", 1, false)
+}
@@ -0,0 +1,173 @@
+// Package jellystore
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/internal/pkg/jell"
+)
+
+// usage the occupied resources of a key or a namespace
+type usage struct {
+	messages    int64
+	memoryBytes int64
+	diskBytes   int64
+}
+
+func (u *usage) add(o usage) {
+	u.messages += o.messages
+	u.memoryBytes += o.memoryBytes
+	u.diskBytes += o.diskBytes
+}
+
+// metaSize bytes of the meta file of a key
+const metaSize = 2 * messageLen
+
+// usage of the message, disk bytes are the bytes of the files after unloading
+func (m *message) usage() usage {
+	const slot = messageLen + maxMessageSize
+
+	written := m.offset + m.len()*slot
+	if m.writtenOffset > written {
+		written = m.writtenOffset
+	}
+	diskBytes := int64(0)
+	if written > 0 {
+		diskBytes = written + metaSize
+	}
+
+	return usage{
+		messages:    m.uncommitted(),
+		memoryBytes: m.memoryBytes,
+		diskBytes:   diskBytes,
+	}
+}
+
+// checkQuota checks the quotas of the key and the namespace of the store allow to set the values.
+func (s *Store) checkQuota(key string, values ...[]byte) error {
+	q := s.config.quota(s.name)
+	if q == (Quota{}) {
+		return nil
+	}
+
+	// the partitions and the priority levels share the quota of their key
+	name, keys := s.quotaKeys(key)
+	current, own := usage{}, usage{}
+	for _, k := range keys {
+		m, err := s.subject.lock(k)
+		if err != nil {
+			continue
+		}
+		u := m.usage()
+		m.mutex.Unlock()
+
+		current.add(u)
+		if k == key {
+			own = u
+		}
+	}
+
+	// the usage of the key after setting the values
+	after := usage{
+		messages:  int64(len(values)),
+		diskBytes: int64(len(values)) * (messageLen + maxMessageSize),
+	}
+	for _, value := range values {
+		after.memoryBytes += int64(len(value))
+	}
+	if own.diskBytes == 0 {
+		// the meta file is written with the first message
+		after.diskBytes += metaSize
+	}
+	after.add(current)
+
+	err := exceeded("key "+name, after, q.KeyMessages, q.KeyMemoryBytes, q.KeyDiskBytes)
+	if err != nil {
+		return err
+	}
+
+	if q.Messages == 0 && q.MemoryBytes == 0 && q.DiskBytes == 0 {
+		return nil
+	}
+
+	counted := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		counted[k] = true
+	}
+	total := after
+	_ = s.subject.srange(func(k string, m *message) error {
+		if !counted[k] {
+			m.mutex.Lock()
+			total.add(m.usage())
+			m.mutex.Unlock()
+		}
+		return nil
+	})
+
+	space := "namespace"
+	if s.name != "" {
+		space += " " + s.name
+	}
+	return exceeded(space, total, q.Messages, q.MemoryBytes, q.DiskBytes)
+}
+
+// quotaKeys the key of the quota of the key and the keys sharing it: the partitions
+// of the partitioned key, the priority levels of the key having the priorities,
+// the key itself otherwise.
+func (s *Store) quotaKeys(key string) (string, []string) {
+	if partitioned, _, ok := splitPartitionKey(key); ok {
+		n := s.partitionsOf(partitioned)
+		keys := make([]string, 0, n+1)
+		for i := 0; i < n; i++ {
+			keys = append(keys, partitionName(partitioned, i))
+		}
+		if n == 0 {
+			keys = append(keys, key)
+		}
+		return partitioned, keys
+	}
+
+	prioritized := key
+	if k, _, ok := splitPriorityKey(key); ok {
+		prioritized = k
+	}
+	p := s.prioritiesOf(prioritized)
+	if p == nil {
+		return key, []string{key}
+	}
+
+	p.mutex.Lock()
+	levels := p.levels
+	p.mutex.Unlock()
+
+	keys := make([]string, 0, levels+1)
+	for level := 0; level <= levels; level++ {
+		keys = append(keys, priorityName(prioritized, level))
+	}
+	return prioritized, keys
+}
+
+func exceeded(space string, u usage, messages, memoryBytes, diskBytes int64) error {
+	switch {
+	case messages > 0 && u.messages > messages:
+		return errors.Wrapf(jell.ErrQuotaExceeded, "%s messages limit %d", space, messages)
+	case memoryBytes > 0 && u.memoryBytes > memoryBytes:
+		return errors.Wrapf(jell.ErrQuotaExceeded, "%s memory bytes limit %d", space, memoryBytes)
+	case diskBytes > 0 && u.diskBytes > diskBytes:
+		return errors.Wrapf(jell.ErrQuotaExceeded, "%s disk bytes limit %d", space, diskBytes)
+	}
+
+	return nil
+}
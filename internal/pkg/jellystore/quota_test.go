@@ -0,0 +1,176 @@
+// Package store
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/baibikov/jellydb/internal/pkg/jell"
+)
+
+func TestStore_Quota(t *testing.T) {
+	const slot = messageLen + maxMessageSize
+
+	tests := []struct {
+		Name  string
+		Quota Quota
+		Keys  []string
+		Want  int
+	}{
+		{
+			Name:  "key-messages",
+			Quota: Quota{KeyMessages: 2},
+			Keys:  []string{"a", "a", "a", "b"},
+			Want:  3,
+		},
+		{
+			Name:  "key-memory-bytes",
+			Quota: Quota{KeyMemoryBytes: 3 * int64(len("message"))},
+			Keys:  []string{"a", "a", "a", "a", "b"},
+			Want:  4,
+		},
+		{
+			Name:  "key-disk-bytes",
+			Quota: Quota{KeyDiskBytes: metaSize + 2*slot},
+			Keys:  []string{"a", "a", "a", "b"},
+			Want:  3,
+		},
+		{
+			Name:  "messages",
+			Quota: Quota{Messages: 3},
+			Keys:  []string{"a", "b", "c", "d"},
+			Want:  3,
+		},
+		{
+			Name:  "memory-bytes",
+			Quota: Quota{MemoryBytes: 2 * int64(len("message"))},
+			Keys:  []string{"a", "b", "c"},
+			Want:  2,
+		},
+		{
+			Name:  "disk-bytes",
+			Quota: Quota{DiskBytes: 2 * (metaSize + slot)},
+			Keys:  []string{"a", "b", "c"},
+			Want:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			store, err := New(&Config{Path: t.TempDir(), Quota: tt.Quota})
+			require.NoError(t, err)
+
+			set := 0
+			for _, key := range tt.Keys {
+				err = store.Set(key, []byte("message"))
+				if err != nil {
+					require.ErrorIs(t, err, jell.ErrQuotaExceeded)
+					continue
+				}
+				set++
+			}
+			require.Equal(t, tt.Want, set)
+		})
+	}
+}
+
+func TestStore_QuotaCommitted(t *testing.T) {
+	store, err := New(&Config{Path: t.TempDir(), Quota: Quota{KeyMessages: 1}})
+	require.NoError(t, err)
+
+	require.NoError(t, store.Set("key", []byte("message1")))
+	require.ErrorIs(t, store.Set("key", []byte("message2")), jell.ErrQuotaExceeded)
+
+	// committed messages release the quota of the messages
+	require.NoError(t, store.Commit("key", 1))
+	require.NoError(t, store.Set("key", []byte("message2")))
+}
+
+func TestStore_NamespaceQuota(t *testing.T) {
+	path := t.TempDir()
+	store, err := New(&Config{
+		Path:  path,
+		Quota: Quota{KeyMessages: 1},
+		NamespaceQuotas: map[string]Quota{
+			"big": {KeyMessages: 2},
+		},
+	})
+	require.NoError(t, err)
+
+	big, err := store.Namespace("big")
+	require.NoError(t, err)
+	small, err := store.Namespace("small")
+	require.NoError(t, err)
+
+	for _, jelly := range []jell.Jelly{store, big, small} {
+		require.NoError(t, jelly.Set("key", []byte("message1")))
+	}
+	require.ErrorIs(t, store.Set("key", []byte("message2")), jell.ErrQuotaExceeded)
+	require.ErrorIs(t, small.Set("key", []byte("message2")), jell.ErrQuotaExceeded)
+	require.NoError(t, big.Set("key", []byte("message2")))
+
+	// loading doesn't check the quotas
+	require.NoError(t, store.Unload(context.Background()))
+	loadStore, err := New(&Config{Path: path, Quota: Quota{Messages: 1}})
+	require.NoError(t, err)
+	require.NoError(t, loadStore.Load(context.Background()))
+
+	loadBig, err := loadStore.Namespace("big")
+	require.NoError(t, err)
+	bb, err := loadBig.Get("key", 2)
+	require.NoError(t, err)
+	require.Len(t, bb, 2)
+}
+
+func TestStore_PartitionQuota(t *testing.T) {
+	store, err := New(&Config{Path: t.TempDir(), Quota: Quota{KeyMessages: 2}})
+	require.NoError(t, err)
+	require.NoError(t, store.Partition("key", 4))
+
+	// the partitions share the quota of the partitioned key
+	set := 0
+	for _, partitionKey := range []string{"a", "b", "c", "d", "e", "f"} {
+		_, err = store.SetPartitioned("key", partitionKey, []byte("message"))
+		if err != nil {
+			require.ErrorIs(t, err, jell.ErrQuotaExceeded)
+			continue
+		}
+		set++
+	}
+	require.Equal(t, 2, set)
+
+	require.NoError(t, store.Partition("batch", 4))
+	err = store.SetBatch([]jell.Entry{
+		{Key: "batch", PartitionKey: "a", Value: []byte("message")},
+		{Key: "batch", PartitionKey: "b", Value: []byte("message")},
+		{Key: "batch", PartitionKey: "c", Value: []byte("message")},
+		{Key: "batch", PartitionKey: "d", Value: []byte("message")},
+	})
+	require.ErrorIs(t, err, jell.ErrQuotaExceeded)
+}
+
+func TestStore_PriorityQuota(t *testing.T) {
+	store, err := New(&Config{Path: t.TempDir(), Quota: Quota{KeyMessages: 2}})
+	require.NoError(t, err)
+
+	// the priority levels share the quota of the key
+	require.NoError(t, store.Set("tasks", []byte("low")))
+	require.NoError(t, store.SetPriority("tasks", 5, []byte("high")))
+	require.ErrorIs(t, store.SetPriority("tasks", 9, []byte("urgent")), jell.ErrQuotaExceeded)
+	require.ErrorIs(t, store.SetPriority("tasks", 5, []byte("high")), jell.ErrQuotaExceeded)
+	require.ErrorIs(t, store.Set("tasks", []byte("low")), jell.ErrQuotaExceeded)
+}
@@ -0,0 +1,248 @@
+// Package jellystore
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/internal/pkg/jell"
+)
+
+// MemoryBytes bytes of the messages held in memory by all namespaces,
+// every queue message holds about 48 bytes besides its body until it is
+// spilled or committed and written to the log.
+func (s *Store) MemoryBytes() int64 {
+	return s.top().memory.Load()
+}
+
+// top the store of the default namespace keeping the memory budget
+func (s *Store) top() *Store {
+	if s.root != nil {
+		return s.root
+	}
+	return s
+}
+
+// overBudget reports whether the size bytes don't fit the memory budget.
+func (s *Store) overBudget(size int64) bool {
+	top := s.top()
+	budget := top.config.MemoryBudget
+	return budget > 0 && top.memory.Load()+size > budget
+}
+
+// reserve spills the messages to disk to fit the size bytes into the memory budget,
+// jell.ErrMemoryBudget is returned if they don't fit after the spilling.
+// The budget is approximate under concurrency, the concurrent settings may reserve
+// the same bytes.
+func (s *Store) reserve(size int64) error {
+	if !s.overBudget(size) {
+		return nil
+	}
+
+	return s.top().spill(size)
+}
+
+// spilledKey the key of the store spilling candidate
+type spilledKey struct {
+	store   *Store
+	key     string
+	m       *message
+	touched time.Time
+}
+
+// spill evicts the messages from memory until the size bytes fit the budget.
+// Committed messages go first, then the messages of the least recently
+// used keys from the oldest ones; the messages are written to the log before.
+// The spillings are serialized, the messages are locked one by one.
+// Returns jell.ErrMemoryBudget if the size bytes don't fit after all.
+func (s *Store) spill(size int64) error {
+	s.spillMutex.Lock()
+	defer s.spillMutex.Unlock()
+
+	// the memory may be freed by the spilling done meanwhile
+	need := s.memory.Load() + size - s.config.MemoryBudget
+	if need <= 0 {
+		return nil
+	}
+
+	keys := make([]spilledKey, 0)
+	collect := func(st *Store) error {
+		return st.subject.srange(func(key string, m *message) error {
+			m.mutex.Lock()
+			keys = append(keys, spilledKey{store: st, key: key, m: m, touched: m.touched})
+			m.mutex.Unlock()
+			return nil
+		})
+	}
+	_ = collect(s)
+	_ = s.rangeNamespaces(collect)
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].touched.Before(keys[j].touched)
+	})
+
+	freed := int64(0)
+	evict := func(k spilledKey, flush bool) error {
+		k.m.mutex.Lock()
+		defer k.m.mutex.Unlock()
+		if k.m.removed {
+			return nil
+		}
+
+		if flush && k.m.writtenIndex < k.m.len() {
+			err := k.store.flush(k.key, k.m)
+			if err != nil {
+				return errors.Wrapf(err, "spill by key - %s", k.key)
+			}
+		}
+
+		// committed messages are not served anymore, they are dropped
+		// from the queue with their overhead
+		n := k.m.compact(true)
+		index := k.m.batchIndex()
+		if flush {
+			index = k.m.len()
+		}
+
+		n += k.m.evict(index, need-freed-n)
+		freed += n
+		s.memory.Add(-n)
+		return nil
+	}
+
+	for _, flush := range []bool{false, true} {
+		for _, k := range keys {
+			if freed >= need {
+				return nil
+			}
+			if err := evict(k, flush); err != nil {
+				return err
+			}
+		}
+	}
+	// the memory may be freed by the committing meanwhile as well
+	if freed < need && s.overBudget(size) {
+		return errors.Wrapf(jell.ErrMemoryBudget, "spill %d bytes of the budget %d, freed %d",
+			need, s.config.MemoryBudget, freed)
+	}
+
+	return nil
+}
+
+// flush writes the messages of the key to the log to evict them.
+func (s *Store) flush(key string, m *message) error {
+	// the directory of the namespace may be absent before the first unloading
+	err := os.MkdirAll(s.config.Path, os.ModePerm)
+	if err != nil {
+		return errors.Wrapf(err, "mkdir by path - %s", s.config.Path)
+	}
+
+	_, err = s.unloadByFile(key, m)
+	return err
+}
+
+// evict spills the written queue messages before the index until the need bytes
+// are freed, they are dropped from the queue and read from the log by getting.
+// Returns the freed bytes with the overhead of the messages.
+func (m *message) evict(index, need int64) int64 {
+	if index > m.writtenIndex {
+		index = m.writtenIndex
+	}
+	if index > m.len() {
+		index = m.len()
+	}
+
+	freed, n := int64(0), 0
+	for ; m.spilled+int64(n) < index && freed < need; n++ {
+		freed += int64(len(m.queue[n])) + messageOverhead
+		if n < len(m.appended) {
+			m.sample(m.spilled+int64(n), m.appended[n])
+		}
+		m.queue[n] = nil
+	}
+	if n == 0 {
+		return 0
+	}
+
+	m.memoryBytes -= freed - messageOverhead*int64(n)
+	m.spilled += int64(n)
+	m.queue = m.queue[n:]
+	if n < len(m.appended) {
+		m.appended = m.appended[n:]
+	} else {
+		m.appended = nil
+	}
+
+	// the slices are copied once they hold half of their capacity,
+	// so the spilled messages don't keep the memory
+	if 2*len(m.queue) < cap(m.queue) {
+		m.queue = append(make([][]byte, 0, len(m.queue)), m.queue...)
+	}
+	if 2*len(m.appended) < cap(m.appended) {
+		m.appended = append(make([]time.Time, 0, len(m.appended)), m.appended...)
+	}
+	return freed
+}
+
+// closeReader closes the log reader of the spilled messages,
+// the next reading opens the log again.
+func (m *message) closeReader() {
+	if m.reader == nil {
+		return
+	}
+
+	_ = m.reader.Close()
+	m.reader = nil
+}
+
+// read reads the queue messages in the range, spilled ones are read from the log
+// opened once for the reading of the message.
+func (s *Store) read(key string, m *message, from, to int64) ([][]byte, error) {
+	bb := make([][]byte, 0, to-from)
+
+	for i := from; i < to; i++ {
+		if b := m.body(i); b != nil {
+			bb = append(bb, b)
+			continue
+		}
+
+		if m.reader == nil {
+			path := fmt.Sprintf("%s/%s", s.keyPath(key), logFileName)
+			l, err := openSlotReader(path)
+			if err != nil {
+				return nil, err
+			}
+			m.reader = l
+		}
+
+		slot, err := m.reader.slot(m.offset + i*(messageLen+maxMessageSize))
+		if err != nil {
+			return nil, errors.Wrapf(err, "read spilled message %d by key %s", i, key)
+		}
+
+		// the slot may refer to the mapped log, the message is copied
+		b, err := decodeSlot(slot)
+		if err != nil {
+			return nil, err
+		}
+		bb = append(bb, append([]byte(nil), b...))
+	}
+
+	return bb, nil
+}
@@ -0,0 +1,255 @@
+// Package store
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/baibikov/jellydb/internal/pkg/jell"
+)
+
+func spillMessages(n int) [][]byte {
+	bb := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		bb = append(bb, bytes.Repeat([]byte(fmt.Sprint(i)), 100))
+	}
+	return bb
+}
+
+func TestStore_Spill(t *testing.T) {
+	// three messages and the overhead of all of them
+	const budget = 350 + 10*messageOverhead
+
+	path := t.TempDir()
+	store, err := New(&Config{Path: path, MemoryBudget: budget})
+	require.NoError(t, err)
+
+	messages := spillMessages(10)
+	for _, bb := range messages {
+		require.NoError(t, store.Set("key", bb))
+		require.LessOrEqual(t, store.MemoryBytes(), int64(budget))
+	}
+
+	// the evicted messages are read from the log
+	bb, err := store.Get("key", 10)
+	require.NoError(t, err)
+	require.Equal(t, messages, bb)
+
+	require.NoError(t, store.Commit("key", 4))
+	bb, err = store.Get("key", 10)
+	require.NoError(t, err)
+	require.Equal(t, messages[4:], bb)
+
+	require.NoError(t, store.Unload(context.Background()))
+
+	// the loaded messages over the budget stay in the log
+	loadStore, err := New(&Config{Path: path, MemoryBudget: budget})
+	require.NoError(t, err)
+	require.NoError(t, loadStore.Load(context.Background()))
+	require.LessOrEqual(t, loadStore.MemoryBytes(), int64(budget))
+
+	bb, err = loadStore.Get("key", 10)
+	require.NoError(t, err)
+	require.Equal(t, messages[4:], bb)
+
+	stats, err := loadStore.Stats("key")
+	require.NoError(t, err)
+	// the spilled messages don't hold the overhead, the messages are 100 bytes
+	require.Equal(t, loadStore.MemoryBytes(), stats.MemoryBytes+stats.MemoryBytes/100*messageOverhead)
+}
+
+func TestStore_SpillOverhead(t *testing.T) {
+	const budget = 2000
+
+	store, err := New(&Config{Path: t.TempDir(), MemoryBudget: budget})
+	require.NoError(t, err)
+
+	// the overhead of the spilled messages is released as well
+	messages := spillMessages(100)
+	for _, bb := range messages {
+		require.NoError(t, store.Set("key", bb))
+		require.LessOrEqual(t, store.MemoryBytes(), int64(budget))
+	}
+
+	bb, err := store.Get("key", 100)
+	require.NoError(t, err)
+	require.Equal(t, messages, bb)
+
+	stats, err := store.Stats("key")
+	require.NoError(t, err)
+	require.EqualValues(t, 100, stats.Uncommitted)
+	require.Positive(t, stats.OldestAge)
+
+	// the message not fitting the budget is not set
+	store, err = New(&Config{Path: t.TempDir(), MemoryBudget: 100})
+	require.NoError(t, err)
+	require.ErrorIs(t, store.Set("key", messages[0]), jell.ErrMemoryBudget)
+}
+
+func TestStore_SpillColdKeys(t *testing.T) {
+	store, err := New(&Config{Path: t.TempDir(), MemoryBudget: 400 + 5*messageOverhead})
+	require.NoError(t, err)
+	team, err := store.Namespace("team")
+	require.NoError(t, err)
+
+	messages := spillMessages(4)
+	require.NoError(t, store.Set("cold", messages[0]))
+	require.NoError(t, team.Set("hot", messages[1]))
+	require.NoError(t, store.Set("cold", messages[2]))
+	require.NoError(t, team.Set("hot", messages[3]))
+
+	_, err = store.Get("cold", 2)
+	require.NoError(t, err)
+	_, err = team.Get("hot", 2)
+	require.NoError(t, err)
+
+	// the budget is shared by the namespaces, the least recently used key is evicted
+	require.NoError(t, team.Set("hot", messages[0]))
+	require.EqualValues(t, 400+4*messageOverhead, store.MemoryBytes())
+
+	cold, err := store.Stats("cold")
+	require.NoError(t, err)
+	require.EqualValues(t, 100, cold.MemoryBytes)
+
+	hot, err := team.Stats("hot")
+	require.NoError(t, err)
+	require.EqualValues(t, 300, hot.MemoryBytes)
+
+	bb, err := store.Get("cold", 2)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{messages[0], messages[2]}, bb)
+
+	// the removed keys release the memory
+	require.NoError(t, team.Delete("hot"))
+	require.NoError(t, store.Purge("cold"))
+	require.Zero(t, store.MemoryBytes())
+}
+
+func TestStore_SpillReader(t *testing.T) {
+	store, err := New(&Config{Path: t.TempDir(), MemoryBudget: 3 * (100 + messageOverhead)})
+	require.NoError(t, err)
+
+	messages := spillMessages(10)
+	for _, bb := range messages[:5] {
+		require.NoError(t, store.Set("key", bb))
+	}
+
+	reader := func() slotReader {
+		m, err := store.subject.lock("key")
+		require.NoError(t, err)
+		defer m.mutex.Unlock()
+		return m.reader
+	}
+
+	// the log is opened once for the gettings
+	bb, err := store.Get("key", 10)
+	require.NoError(t, err)
+	require.Equal(t, messages[:5], bb)
+	opened := reader()
+	require.NotNil(t, opened)
+
+	_, err = store.Get("key", 10)
+	require.NoError(t, err)
+	require.True(t, opened == reader())
+
+	// the spilled messages written after the opening are read by the log opened again
+	for _, bb := range messages[5:] {
+		require.NoError(t, store.Set("key", bb))
+	}
+	bb, err = store.Get("key", 10)
+	require.NoError(t, err)
+	require.Equal(t, messages, bb)
+
+	// the log is closed once the spilled messages are committed
+	require.NoError(t, store.Commit("key", 10))
+	require.NoError(t, store.Unload(context.Background()))
+	require.Nil(t, reader())
+}
+
+func TestStore_SpillCompaction(t *testing.T) {
+	path := t.TempDir()
+	store, err := New(&Config{Path: path, MemoryBudget: 1 << 20})
+	require.NoError(t, err)
+
+	messages := spillMessages(10)
+	require.NoError(t, store.set("key", messages...))
+	require.EqualValues(t, 10*(100+messageOverhead), store.MemoryBytes())
+
+	// the committed messages not written yet stay in memory
+	require.NoError(t, store.Commit("key", 6))
+	require.EqualValues(t, 10*(100+messageOverhead), store.MemoryBytes())
+
+	// once written they are dropped with their overhead
+	require.NoError(t, store.Unload(context.Background()))
+	require.EqualValues(t, 4*(100+messageOverhead), store.MemoryBytes())
+
+	stats, err := store.Stats("key")
+	require.NoError(t, err)
+	require.EqualValues(t, 10, stats.Total)
+	require.EqualValues(t, 4, stats.Uncommitted)
+	require.EqualValues(t, 6*(messageLen+maxMessageSize), stats.CommittedOffset)
+
+	bb, err := store.Get("key", 10)
+	require.NoError(t, err)
+	require.Equal(t, messages[6:], bb)
+
+	// the compacted queue keeps the offsets of the log
+	require.NoError(t, store.Set("key", messages[0]))
+	require.NoError(t, store.Commit("key", 1))
+	require.NoError(t, store.Unload(context.Background()))
+
+	loadStore, err := New(&Config{Path: path})
+	require.NoError(t, err)
+	require.NoError(t, loadStore.Load(context.Background()))
+	bb, err = loadStore.Get("key", 10)
+	require.NoError(t, err)
+	require.Equal(t, append(messages[7:], messages[0]), bb)
+
+	// the queue committed all is dropped as well
+	require.NoError(t, loadStore.Commit("key", 4))
+	require.NoError(t, loadStore.Unload(context.Background()))
+	require.Zero(t, loadStore.MemoryBytes())
+
+	stats, err = loadStore.Stats("key")
+	require.NoError(t, err)
+	require.EqualValues(t, 11, stats.Total)
+	require.Zero(t, stats.Uncommitted)
+}
+
+func TestStore_LogFull(t *testing.T) {
+	const slot = messageLen + maxMessageSize
+
+	store, err := New(&Config{Path: t.TempDir()})
+	require.NoError(t, err)
+	require.NoError(t, store.Set("key", []byte("message")))
+
+	// the log is one slot short of the limit of the meta offsets
+	m, err := store.subject.lock("key")
+	require.NoError(t, err)
+	m.offset = maxLogSize/slot*slot - 2*slot
+	m.mutex.Unlock()
+
+	require.NoError(t, store.Set("key", []byte("message")))
+	require.Error(t, store.Set("key", []byte("message")))
+	require.Error(t, store.set("key", []byte("message"), []byte("message")))
+
+	// the purged key starts the log again
+	require.NoError(t, store.Purge("key"))
+	require.NoError(t, store.Set("key", []byte("message")))
+}
@@ -0,0 +1,133 @@
+// Package jellystore
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/internal/pkg/jell"
+)
+
+func (s *Store) Stats(key string) (*jell.Stats, error) {
+	if n := s.partitionsOf(key); n > 0 {
+		return s.partitionStats(key, n)
+	}
+	if p := s.prioritiesOf(key); p != nil {
+		return s.priorityStats(key, p)
+	}
+	return s.stats(key)
+}
+
+// stats statistics of the key or the partition
+func (s *Store) stats(key string) (*jell.Stats, error) {
+	// the statistics of the registered key don't load it
+	if _, ok := s.lazy.Load(key); ok {
+		return s.lazyStats(key)
+	}
+
+	m, err := s.subject.lock(key)
+	if err != nil {
+		return nil, err
+	}
+	stats := m.stats(time.Now())
+	m.mutex.Unlock()
+
+	diskBytes, err := s.diskBytes(key)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.DiskBytes = diskBytes
+	return stats, nil
+}
+
+func (m *message) stats(now time.Time) *jell.Stats {
+	const slot = messageLen + maxMessageSize
+
+	committed := m.len() - m.uncommitted()
+	stats := &jell.Stats{
+		// messages before the queue were committed before loading
+		Total:           m.offset/slot + m.len(),
+		Uncommitted:     m.uncommitted(),
+		CommittedOffset: m.offset + committed*slot,
+		WrittenOffset:   m.offset + m.len()*slot,
+		MemoryBytes:     m.memoryBytes,
+	}
+
+	// the log may be ahead of the memory if it was written by another store
+	if m.writtenOffset > stats.WrittenOffset {
+		stats.WrittenOffset = m.writtenOffset
+	}
+	if m.committedOffset > stats.CommittedOffset {
+		stats.CommittedOffset = m.committedOffset
+	}
+
+	if stats.Uncommitted > 0 {
+		if appended, ok := m.appendedAt(committed); ok {
+			stats.OldestAge = now.Sub(appended)
+		}
+	}
+
+	return stats
+}
+
+// Summary the keys of the store and their statistics summed without reading the file storage:
+// the disk bytes are counted by the offsets of the logs and the keys registered by the lazy
+// loading and not loaded yet are counted by the keys only. The offsets are zero.
+func (s *Store) Summary() (int64, *jell.Stats) {
+	keys, _ := s.List("")
+
+	now := time.Now()
+	summary := &jell.Stats{}
+	_ = s.subject.srange(func(_ string, m *message) error {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		if m.removed {
+			return nil
+		}
+
+		stats := m.stats(now)
+		summary.Total += stats.Total
+		summary.Uncommitted += stats.Uncommitted
+		summary.MemoryBytes += stats.MemoryBytes
+		summary.DiskBytes += m.usage().diskBytes
+		if stats.OldestAge > summary.OldestAge {
+			summary.OldestAge = stats.OldestAge
+		}
+		return nil
+	})
+
+	return int64(len(keys)), summary
+}
+
+func (s *Store) diskBytes(key string) (int64, error) {
+	var size int64
+	for _, name := range []string{logFileName, metaFileName} {
+		info, err := os.Stat(fmt.Sprintf("%s/%s", s.keyPath(key), name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return 0, errors.Wrapf(err, "stating file %s by key %s", name, key)
+		}
+
+		size += info.Size()
+	}
+
+	return size, nil
+}
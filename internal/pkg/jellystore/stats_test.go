@@ -0,0 +1,145 @@
+// Package jellystore
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Stats(t *testing.T) {
+	makeTestPath(t)
+
+	tests := []struct {
+		Name            string
+		Key             string
+		Batch           [][]byte
+		Commit          int64
+		Unload          bool
+		WantTotal       int64
+		WantUncommitted int64
+		WantCommitted   int64
+		WantWritten     int64
+		WantMemory      int64
+		WantDisk        int64
+	}{
+		{
+			Name: "empty-commit",
+			Key:  "empty-commit-stats",
+			Batch: [][]byte{
+				[]byte("message1"),
+				[]byte("message2"),
+			},
+			WantTotal:       2,
+			WantUncommitted: 2,
+			WantWritten:     1032,
+			WantMemory:      16,
+		},
+		{
+			Name: "one-committed",
+			Key:  "one-committed-stats",
+			Batch: [][]byte{
+				[]byte("message1"),
+				[]byte("message2"),
+				[]byte("message3"),
+			},
+			Commit:          1,
+			WantTotal:       3,
+			WantUncommitted: 2,
+			WantCommitted:   516,
+			WantWritten:     1548,
+			WantMemory:      24,
+		},
+		{
+			Name: "unloaded",
+			Key:  "unloaded-stats",
+			Batch: [][]byte{
+				[]byte("message1"),
+				[]byte("message2"),
+			},
+			Commit:          2,
+			Unload:          true,
+			WantTotal:       2,
+			WantUncommitted: 0,
+			WantCommitted:   1032,
+			WantWritten:     1032,
+			// the committed messages are dropped once written
+			WantMemory: 0,
+			WantDisk:   1032 + 8,
+		},
+	}
+
+	store, err := New(testConfig)
+	require.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			err := os.RemoveAll(testPath + "/" + tt.Key)
+			require.NoError(t, err)
+
+			for _, bb := range tt.Batch {
+				err := store.Set(tt.Key, bb)
+				require.NoError(t, err)
+			}
+
+			err = store.Commit(tt.Key, tt.Commit)
+			require.NoError(t, err)
+
+			if tt.Unload {
+				err = store.Unload(context.Background())
+				require.NoError(t, err)
+			}
+
+			stats, err := store.Stats(tt.Key)
+			require.NoError(t, err)
+			require.Equal(t, tt.WantTotal, stats.Total)
+			require.Equal(t, tt.WantUncommitted, stats.Uncommitted)
+			require.Equal(t, tt.WantCommitted, stats.CommittedOffset)
+			require.Equal(t, tt.WantWritten, stats.WrittenOffset)
+			require.Equal(t, tt.WantMemory, stats.MemoryBytes)
+			require.Equal(t, tt.WantDisk, stats.DiskBytes)
+			if tt.WantUncommitted > 0 {
+				require.Positive(t, stats.OldestAge)
+			} else {
+				require.Zero(t, stats.OldestAge)
+			}
+		})
+	}
+
+	_, err = store.Stats("undefined-stats")
+	require.Error(t, err)
+}
+
+func TestStore_Summary(t *testing.T) {
+	store, err := New(&Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	require.NoError(t, store.Partition("orders", 2))
+	_, err = store.SetPartitioned("orders", "a", []byte("message1"))
+	require.NoError(t, err)
+	require.NoError(t, store.Set("key", []byte("message1")))
+	require.NoError(t, store.Set("key", []byte("message2")))
+	require.NoError(t, store.Commit("key", 1))
+
+	keys, stats := store.Summary()
+	require.EqualValues(t, 2, keys)
+	require.EqualValues(t, 3, stats.Total)
+	require.EqualValues(t, 2, stats.Uncommitted)
+	require.Positive(t, stats.MemoryBytes)
+	// the logs of two keys of the messages not unloaded yet with their meta files
+	require.EqualValues(t, 3*(messageLen+maxMessageSize)+2*metaSize, stats.DiskBytes)
+}
@@ -14,16 +14,60 @@
 package jellystore
 
 import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
 type Store struct {
-	mutex  sync.RWMutex
+	// mutex serializes the settings checked by the quotas
+	mutex  sync.Mutex
 	config *Config
 
 	subject *subject
+
+	// namespaces stores of the namespaces by name, root the store of the
+	// default namespace for them. Namespaces are registered only in the root.
+	namespaces sync.Map
+	root       *Store
+	// name of the namespace, empty for the default one
+	name string
+	// memory bytes of the messages held in memory by all namespaces,
+	// counted by the default namespace store
+	memory atomic.Int64
+	// spillMutex serializes the spillings of all namespaces
+	spillMutex sync.Mutex
+	// progress of the loading of all namespaces
+	progress loadProgress
+	// broken keys failed to load by the errors
+	broken sync.Map
+	// lazy keys registered by the lazy loading and not loaded yet
+	lazy sync.Map
+	// partitions numbers of the partitions of the partitioned keys
+	partitions sync.Map
+	// priorities priority levels of the keys having the priorities
+	priorities sync.Map
+	// dedups dedup windows of the keys deduplicating by content
+	dedups sync.Map
+	// digests digests seen by the keys deduplicating by content
+	digests sync.Map
+	// transactions open transactions by the ids
+	transactions sync.Map
+	// applied records of the committed transactions applied in memory,
+	// the records are removed by the unloading writing them
+	applied sync.Map
+	// lastUnload report of the last unloading of all namespaces
+	lastUnload atomic.Value
+
+	// bytes read and written through the file storage
+	loadedBytes   atomic.Int64
+	unloadedBytes atomic.Int64
 }
 
 func New(config *Config) (*Store, error) {
@@ -36,50 +80,409 @@ func New(config *Config) (*Store, error) {
 
 	return &Store{
 		config:  config,
-		subject: new(subject),
+		subject: newSubject(config.shards()),
 	}, nil
 }
 
+// LoadedBytes bytes read from the file storage by loading, including the namespaces.
+func (s *Store) LoadedBytes() int64 {
+	n := s.loadedBytes.Load()
+	_ = s.rangeNamespaces(func(ns *Store) error {
+		n += ns.loadedBytes.Load()
+		return nil
+	})
+	return n
+}
+
+// UnloadedBytes bytes written to the file storage by unloading, including the namespaces.
+func (s *Store) UnloadedBytes() int64 {
+	n := s.unloadedBytes.Load()
+	_ = s.rangeNamespaces(func(ns *Store) error {
+		n += ns.unloadedBytes.Load()
+		return nil
+	})
+	return n
+}
+
 func (s *Store) Get(key string, n int64) ([][]byte, error) {
-	m, err := s.subject.load(key)
+	if err := s.checkPartitioned(key); err != nil {
+		return nil, err
+	}
+	if p := s.prioritiesOf(key); p != nil {
+		bb, _, err := s.getPriorities(key, p, nil, n)
+		return bb, err
+	}
+	return s.get(key, n)
+}
+
+func (s *Store) get(key string, n int64) ([][]byte, error) {
+	if err := s.ensureLoaded(key); err != nil {
+		return nil, err
+	}
+
+	m, err := s.subject.lock(key)
 	if err != nil {
 		return nil, err
 	}
+	defer m.mutex.Unlock()
 
-	return m.batch(n), nil
+	from, to, ok := m.batchBounds(n)
+	if !ok {
+		m.delivered = 0
+		return nil, nil
+	}
+	m.touched = time.Now()
+
+	bb, err := s.read(key, m, from, to)
+	if err != nil {
+		return nil, err
+	}
+	m.delivered = int64(len(bb))
+	return bb, nil
 }
 
 func (s *Store) Commit(key string, n int64) error {
-	m, err := s.subject.load(key)
+	if err := s.checkPartitioned(key); err != nil {
+		return err
+	}
+	if p := s.prioritiesOf(key); p != nil {
+		return s.commitPriorities(key, p, n)
+	}
+	return s.commit(key, n)
+}
+
+func (s *Store) commit(key string, n int64) error {
+	if err := s.ensureLoaded(key); err != nil {
+		return err
+	}
+
+	m, err := s.subject.lock(key)
 	if err != nil {
 		return err
 	}
+	defer m.mutex.Unlock()
 
 	m.commit(n)
+	s.top().memory.Add(-m.compact(false))
+	s.markDirty(key)
 	return nil
 }
 
 func (s *Store) Set(key string, value []byte) error {
-	if len(value) == 0 {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	if err := s.checkPartitioned(key); err != nil {
+		return err
+	}
+	return s.set(key, value)
+}
+
+// set sets the values of the key or the partition after the checks of the quotas,
+// the values are appended together or none of them. The empty values are skipped.
+func (s *Store) set(key string, values ...[]byte) error {
+	_, err := s.produce(key, nil, values)
+	return err
+}
+
+// produce sets the values of the key by the idempotent producer, the nil producer
+// sets the values as is. Reports whether the values are ignored as set already.
+func (s *Store) produce(key string, p *produced, values [][]byte) (bool, error) {
+	if err := s.ensureLoaded(key); err != nil {
+		return false, err
+	}
+	if err := s.checkBroken(key); err != nil {
+		return false, err
+	}
+
+	size := int64(0)
+	nonEmpty := make([][]byte, 0, len(values))
+	for _, value := range values {
+		if len(value) > maxMessageSize {
+			return false, errors.Errorf("transmitted message is larger than allowed")
+		}
+		if len(value) > 0 {
+			nonEmpty = append(nonEmpty, value)
+			size += int64(len(value))
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return false, nil
+	}
+
+	// the retried values are ignored before the quotas reject them
+	if s.duplicate(key, p) {
+		return true, nil
+	}
+
+	// the settings checked by the quotas are serialized,
+	// so the concurrent settings don't exceed the quotas together
+	if s.config.quota(s.name) != (Quota{}) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+	}
+	if err := s.checkQuota(key, nonEmpty...); err != nil {
+		return false, err
+	}
+
+	return s.append(key, p, size, nonEmpty)
+}
+
+// append appends the values of the size bytes without the checks of the quotas,
+// the messages are spilled to disk to fit the memory budget.
+func (s *Store) append(key string, p *produced, size int64, values [][]byte) (bool, error) {
+	// the spilling locks the messages of the other keys,
+	// so it is done before locking the message of the key
+	err := s.reserve(size + messageOverhead*int64(len(values)))
+	if err != nil {
+		return false, err
+	}
+
+	m, loaded := s.subject.lockStore(key)
+	defer m.mutex.Unlock()
+
+	// the key may be partitioned or deleted meanwhile
+	err = s.checkPartitionKey(key)
+	if err != nil {
+		if !loaded {
+			s.subject.remove(key, m)
+		}
+		return false, err
+	}
+
+	// the producer may have retried the values meanwhile
+	now := time.Now()
+	if m.duplicate(p, now, s.config.dedupWindow()) {
+		return true, nil
+	}
+
+	// the sizes are checked by the setting, so the values are appended all,
+	// except the values seen within the dedup window of the key
+	err = m.fits(int64(len(values)))
+	if err != nil {
+		return false, err
+	}
+	// the partitions and the priority levels share the digests of the key
+	var d *digests
+	window := s.dedupWindowOf(key)
+	if window > 0 {
+		d = s.digestsOf(key)
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+	}
+
+	held := m.held()
+	appended := int64(0)
+	for _, value := range values {
+		if d != nil {
+			sum := dedupSum(p, value)
+			if d.seen(sum, now, window) {
+				continue
+			}
+			d.see(sum, now, window)
+		}
+
+		err = m.append(value)
+		if err != nil {
+			return false, err
+		}
+		appended += int64(len(value))
+	}
+	if appended == 0 {
+		return true, nil
+	}
+	m.produce(p, now)
+
+	s.top().memory.Add(m.held() - held)
+	s.markDirty(key)
+	return false, nil
+}
+
+// release releases the memory and the log reader of the locked message being removed.
+func (s *Store) release(m *message) {
+	s.top().memory.Add(-m.held())
+	m.closeReader()
+}
+
+func (s *Store) List(prefix string) ([]string, error) {
+	found := make(map[string]struct{})
+	add := func(key string) {
+		// the partitions and the priority levels are listed by their key
+		if partitioned, _, ok := splitPartitionKey(key); ok {
+			key = partitioned
+		}
+		if prioritized, _, ok := splitPriorityKey(key); ok {
+			key = prioritized
+		}
+		if strings.HasPrefix(key, prefix) {
+			found[key] = struct{}{}
+		}
+	}
+
+	err := s.subject.srange(func(key string, _ *message) error {
+		add(key)
 		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return s.subject.store(key).append(value)
+
+	// the key registered by the lazy loading may be loaded meanwhile
+	s.lazy.Range(func(key, _ any) bool {
+		k, _ := key.(string)
+		if !s.subject.has(k) {
+			add(k)
+		}
+		return true
+	})
+
+	// the partitioned key is listed before its partitions are set
+	s.partitions.Range(func(key, _ any) bool {
+		k, _ := key.(string)
+		add(k)
+		return true
+	})
+
+	keys := make([]string, 0, len(found))
+	for key := range found {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
 }
 
-func (s *Store) setWrittenOffset(key string, wo, co int64) {
-	m := s.subject.store(key)
-	m.writtenOffset = wo
-	m.committedOffset = co
+func (s *Store) Delete(key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	if err := s.forgetDigests(key); err != nil {
+		return err
+	}
+	if n := s.partitionsOf(key); n > 0 {
+		return s.deletePartitions(key, n)
+	}
+	if p := s.prioritiesOf(key); p != nil {
+		return s.deletePriorities(key, p)
+	}
+
+	// the key failed to load is removed as well
+	_ = s.ensureLoaded(key)
 
-	if wo == co {
-		m.writtenIndex = 0
-		return
+	// the message stays locked until its directory is removed,
+	// so the key set again is not written to the removed directory
+	m, err := s.subject.lock(key)
+	loaded := err == nil
+	if loaded {
+		defer m.mutex.Unlock()
+		// the log is not removed while it is opened on some systems
+		m.closeReader()
 	}
 
-	if wo < co {
-		return
+	removed, err := s.removeKeyPath(key)
+	if err != nil {
+		return err
+	}
+	if loaded {
+		s.release(m)
+		s.subject.remove(key, m)
+	}
+	if !loaded && !removed {
+		return errors.Errorf("value by %s not found", key)
 	}
+	s.broken.Delete(key)
+	s.dedups.Delete(key)
+
+	return nil
+}
 
-	// offset shift by commented offset with written offset
-	m.writtenIndex = (wo - co) / (messageLen + maxMessageSize)
+func (s *Store) Purge(key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	// the purged key forgets the digests
+	if err := s.forgetDigests(key); err != nil {
+		return err
+	}
+	if n := s.partitionsOf(key); n > 0 {
+		return s.purgePartitions(key, n)
+	}
+
+	if p := s.prioritiesOf(key); p != nil {
+		err := s.purgePriorities(key, p)
+		if err != nil {
+			return err
+		}
+	} else {
+		found, err := s.purge(key)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return errors.Errorf("value by %s not found", key)
+		}
+	}
+
+	// the dedup window of the key stays
+	if window, _ := s.DedupWindow(key); window > 0 {
+		return s.writeDedup(key, window)
+	}
+	return nil
+}
+
+// purge drops the messages of the key or the partition,
+// reports whether the key has been found.
+func (s *Store) purge(key string) (bool, error) {
+	// the key failed to load is removed as well
+	_ = s.ensureLoaded(key)
+
+	m, loaded := s.subject.lockStore(key)
+	defer m.mutex.Unlock()
+	m.closeReader()
+
+	removed, err := s.removeKeyPath(key)
+	if err != nil || (!loaded && !removed) {
+		if !loaded {
+			s.subject.remove(key, m)
+		}
+		return false, err
+	}
+	s.release(m)
+	s.broken.Delete(key)
+
+	// the key stays registered, only its messages and offsets are dropped
+	m.reset()
+	return true, nil
+}
+
+// removeKeyPath removes the key directory with the log and meta files,
+// reports whether the directory existed.
+func (s *Store) removeKeyPath(key string) (bool, error) {
+	path := s.keyPath(key)
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "stating dir by path - %s", path)
+	}
+
+	return true, errors.Wrapf(os.RemoveAll(path), "remove dir by path - %s", path)
+}
+
+func (s *Store) keyPath(key string) string {
+	return fmt.Sprintf("%s/%s", s.config.Path, key)
+}
+
+// validateKey the key is used as a directory name
+// of the file storage, so it must be a single path element
+// other than the namespaces and transactions directories.
+func validateKey(key string) error {
+	if key == "" {
+		return errors.New("key has not be empty")
+	}
+	if key == "." || key == ".." || key == namespacesDir || key == transactionsDir || strings.ContainsAny(key, `/\`) {
+		return errors.Errorf("key %s is not allowed", key)
+	}
+
+	return nil
 }
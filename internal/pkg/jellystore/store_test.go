@@ -14,6 +14,8 @@
 package jellystore
 
 import (
+	"context"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -27,6 +29,13 @@ var testConfig = &Config{
 	Path: "test_path",
 }
 
+// TestMain removes the files written by the tests sharing the test path
+func TestMain(m *testing.M) {
+	code := m.Run()
+	_ = os.RemoveAll(testPath)
+	os.Exit(code)
+}
+
 func TestStore_Commit(t *testing.T) {
 	makeTestPath(t)
 
@@ -473,6 +482,114 @@ func TestStore_Get(t *testing.T) {
 
 }
 
+func TestStore_List(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Keys   []string
+		Prefix string
+		Want   []string
+	}{
+		{
+			Name: "all",
+			Keys: []string{"b-list", "a-list", "c-list"},
+			Want: []string{"a-list", "b-list", "c-list"},
+		},
+		{
+			Name:   "prefix",
+			Keys:   []string{"orders-1", "users-1", "orders-2"},
+			Prefix: "orders-",
+			Want:   []string{"orders-1", "orders-2"},
+		},
+		{
+			Name:   "no-matches",
+			Keys:   []string{"orders-1"},
+			Prefix: "users-",
+			Want:   []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			store, err := New(testConfig)
+			require.NoError(t, err)
+
+			for _, key := range tt.Keys {
+				err := store.Set(key, []byte("message"))
+				require.NoError(t, err)
+			}
+
+			keys, err := store.List(tt.Prefix)
+			require.NoError(t, err)
+			require.Equal(t, tt.Want, keys)
+		})
+	}
+}
+
+func TestStore_Delete(t *testing.T) {
+	makeTestPath(t)
+
+	const key = "delete-key"
+	store, err := New(testConfig)
+	require.NoError(t, err)
+
+	err = store.Set(key, []byte("message1"))
+	require.NoError(t, err)
+
+	err = store.Unload(context.Background())
+	require.NoError(t, err)
+
+	err = store.Delete(key)
+	require.NoError(t, err)
+
+	_, err = os.Stat(testPath + "/" + key)
+	require.True(t, os.IsNotExist(err))
+
+	_, err = store.Get(key, 1)
+	require.Error(t, err)
+
+	err = store.Delete(key)
+	require.Error(t, err)
+
+	err = store.Delete("../" + key)
+	require.Error(t, err)
+}
+
+func TestStore_Purge(t *testing.T) {
+	makeTestPath(t)
+
+	const key = "purge-key"
+	store, err := New(testConfig)
+	require.NoError(t, err)
+
+	for _, bb := range [][]byte{[]byte("message1"), []byte("message2")} {
+		err = store.Set(key, bb)
+		require.NoError(t, err)
+	}
+
+	err = store.Unload(context.Background())
+	require.NoError(t, err)
+
+	err = store.Purge(key)
+	require.NoError(t, err)
+
+	_, err = os.Stat(testPath + "/" + key)
+	require.True(t, os.IsNotExist(err))
+
+	bb, err := store.Get(key, 2)
+	require.NoError(t, err)
+	require.Empty(t, bb)
+
+	err = store.Set(key, []byte("message3"))
+	require.NoError(t, err)
+
+	bb, err = store.Get(key, 2)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("message3")}, bb)
+
+	err = store.Purge("undefined-purge-key")
+	require.Error(t, err)
+}
+
 func makeTestPath(t *testing.T) {
 	err := utils.CreateFileIfNotExists("test_path")
 	require.NoError(t, err)
@@ -6,46 +6,190 @@ import (
 	"github.com/pkg/errors"
 )
 
+// subject the keys of the store split between the shards by the hash of the key,
+// every shard has its own lock and its dirty keys are unloaded by its own worker.
 type subject struct {
-	sync.Map
+	shards []*shard
+}
+
+type shard struct {
+	mutex sync.RWMutex
+	keys  map[string]*message
+	// dirty keys changed since the last unloading
+	dirty map[string]struct{}
+}
+
+func newSubject(shards int) *subject {
+	s := &subject{shards: make([]*shard, shards)}
+	for i := range s.shards {
+		s.shards[i] = &shard{
+			keys:  make(map[string]*message),
+			dirty: make(map[string]struct{}),
+		}
+	}
+	return s
+}
+
+// shard the shard of the key by the hash of the key
+func (s *subject) shard(key string) *shard {
+	return s.shards[hashKey(key)%uint32(len(s.shards))]
+}
+
+// hashKey FNV-1a hash of the key
+func hashKey(key string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return h
 }
 
 func (s *subject) load(key string) (*message, error) {
-	val, ok := s.Load(key)
+	sh := s.shard(key)
+	sh.mutex.RLock()
+	m, ok := sh.keys[key]
+	sh.mutex.RUnlock()
 	if !ok {
 		return nil, errors.Errorf("value by %s not found", key)
 	}
 
-	m, ok := val.(*message)
-	if !ok {
-		return nil, errors.Errorf("fatal type assertion to message %[1]T %+[1]v", val)
+	return m, nil
+}
+
+// has reports whether the key is in memory.
+func (s *subject) has(key string) bool {
+	_, err := s.load(key)
+	return err == nil
+}
+
+// srange calls f for the keys of all shards, f is called without the locks
+// of the shards, so it may lock the messages.
+func (s *subject) srange(f func(key string, value *message) error) error {
+	for _, sh := range s.shards {
+		sh.mutex.RLock()
+		keys := make(map[string]*message, len(sh.keys))
+		for key, m := range sh.keys {
+			keys[key] = m
+		}
+		sh.mutex.RUnlock()
+
+		for key, m := range keys {
+			if err := f(key, m); err != nil {
+				return err
+			}
+		}
 	}
 
-	return m, nil
+	return nil
+}
+
+// store getting the message of the key, the message is created if absent.
+// Reports whether the message existed.
+func (s *subject) store(key string) (*message, bool) {
+	sh := s.shard(key)
+	sh.mutex.RLock()
+	m, ok := sh.keys[key]
+	sh.mutex.RUnlock()
+	if ok {
+		return m, true
+	}
+
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+	if m, ok = sh.keys[key]; ok {
+		return m, true
+	}
+	m = newMessage()
+	sh.keys[key] = m
+	return m, false
 }
 
-func (s *subject) srange(f func(key string, value *message) error) (err error) {
-	s.Range(func(key, value any) bool {
-		kk, _ := key.(string)
-		vv, _ := value.(*message)
-		if err = f(kk, vv); err != nil {
-			return false
+// lock getting the locked message of the key,
+// the message removed while waiting for the lock is looked up again.
+func (s *subject) lock(key string) (*message, error) {
+	for {
+		m, err := s.load(key)
+		if err != nil {
+			return nil, err
+		}
+
+		m.mutex.Lock()
+		if !m.removed {
+			return m, nil
 		}
+		m.mutex.Unlock()
+	}
+}
 
-		return true
-	})
+// lockStore getting the locked message of the key, the message is created if absent.
+// Reports whether the message existed.
+func (s *subject) lockStore(key string) (*message, bool) {
+	for {
+		m, ok := s.store(key)
 
-	return err
+		m.mutex.Lock()
+		if !m.removed {
+			return m, ok
+		}
+		m.mutex.Unlock()
+	}
 }
 
-func (s *subject) store(key string) *message {
-	val, ok := s.Load(key)
+// remove removes the locked message of the key, the message
+// is marked removed for the accesses waiting for its lock.
+func (s *subject) remove(key string, m *message) {
+	m.removed = true
+
+	sh := s.shard(key)
+	sh.mutex.Lock()
+	delete(sh.keys, key)
+	delete(sh.dirty, key)
+	sh.mutex.Unlock()
+}
+
+// markDirty marks the key changed since the last unloading.
+func (sh *shard) markDirty(key string) {
+	sh.mutex.RLock()
+	_, ok := sh.dirty[key]
+	sh.mutex.RUnlock()
 	if ok {
-		m, _ := val.(*message)
-		return m
+		return
 	}
 
-	mm := newMessage()
-	s.Store(key, mm)
-	return mm
+	sh.mutex.Lock()
+	sh.dirty[key] = struct{}{}
+	sh.mutex.Unlock()
+}
+
+// clean drops the dirty mark of the key.
+func (sh *shard) clean(key string) {
+	sh.mutex.Lock()
+	delete(sh.dirty, key)
+	sh.mutex.Unlock()
+}
+
+// dirtyKeys the keys changed since the last unloading.
+func (sh *shard) dirtyKeys() []string {
+	sh.mutex.RLock()
+	defer sh.mutex.RUnlock()
+
+	keys := make([]string, 0, len(sh.dirty))
+	for key := range sh.dirty {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// dirty reports whether any key of the shards has been changed since the last unloading.
+func (s *subject) dirty() bool {
+	for _, sh := range s.shards {
+		sh.mutex.RLock()
+		n := len(sh.dirty)
+		sh.mutex.RUnlock()
+		if n > 0 {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,111 @@
+// Package store
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Shards(t *testing.T) {
+	const keys = 100
+
+	path := t.TempDir()
+	store, err := New(&Config{Path: path, Shards: 4})
+	require.NoError(t, err)
+	for i := 0; i < keys; i++ {
+		require.NoError(t, store.Set(fmt.Sprint("key-", i), []byte(fmt.Sprint("message-", i))))
+	}
+
+	// the keys are split between the shards
+	for _, sh := range store.subject.shards {
+		require.NotEmpty(t, sh.keys)
+		require.Len(t, sh.dirtyKeys(), len(sh.keys))
+	}
+
+	require.NoError(t, store.Unload(context.Background()))
+	require.EqualValues(t, keys, store.LastUnload().Keys)
+	require.False(t, store.subject.dirty())
+
+	// the files don't depend on the shards
+	loadStore, err := New(&Config{Path: path, Shards: 1})
+	require.NoError(t, err)
+	require.NoError(t, loadStore.Load(context.Background()))
+
+	list, err := loadStore.List("")
+	require.NoError(t, err)
+	require.Len(t, list, keys)
+	for i := 0; i < keys; i++ {
+		bb, err := loadStore.Get(fmt.Sprint("key-", i), 1)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte(fmt.Sprint("message-", i))}, bb)
+	}
+}
+
+// benchmarkKeys keys set and got by the parallel benchmarks
+const benchmarkKeys = 1024
+
+// benchmarkShards runs the benchmark by the store with one shard and many shards,
+// run it with -cpu 1,2,4,8 to compare the throughput by GOMAXPROCS.
+func benchmarkShards(b *testing.B, f func(b *testing.B, store *Store)) {
+	for _, shards := range []int{1, 16} {
+		b.Run(fmt.Sprint("shards-", shards), func(b *testing.B) {
+			store, err := New(&Config{Path: b.TempDir(), Shards: shards})
+			require.NoError(b, err)
+			f(b, store)
+		})
+	}
+}
+
+func BenchmarkStore_Set(b *testing.B) {
+	value := []byte("message")
+	benchmarkShards(b, func(b *testing.B, store *Store) {
+		var next atomic.Int64
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				key := fmt.Sprint("key-", next.Add(1)%benchmarkKeys)
+				if err := store.Set(key, value); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+}
+
+func BenchmarkStore_Get(b *testing.B) {
+	benchmarkShards(b, func(b *testing.B, store *Store) {
+		for i := 0; i < benchmarkKeys; i++ {
+			for j := 0; j < 10; j++ {
+				require.NoError(b, store.Set(fmt.Sprint("key-", i), []byte("message")))
+			}
+		}
+
+		var next atomic.Int64
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				key := fmt.Sprint("key-", next.Add(1)%benchmarkKeys)
+				if _, err := store.Get(key, 10); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+}
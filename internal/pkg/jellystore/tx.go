@@ -0,0 +1,590 @@
+// Package jellystore
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+// transactionsDir directory of the records of the committed transactions under the store path
+const transactionsDir = ".transactions"
+
+// recordExt extension of the record files of the committed transactions
+const recordExt = ".jelly.tx"
+
+// transaction the messages set and committed by the transaction kept until its end,
+// the fields are guarded by the mutex
+type transaction struct {
+	mutex sync.Mutex
+	// done the transaction has been ended or aborted
+	done bool
+
+	// values set by the transaction by the keys
+	values map[string][][]byte
+	// commits messages committed by the transaction by the keys
+	commits map[string]int64
+}
+
+// keys set or committed by the transaction in order
+func (tx *transaction) keys() []string {
+	keys := make([]string, 0, len(tx.values)+len(tx.commits))
+	for key := range tx.values {
+		keys = append(keys, key)
+	}
+	for key := range tx.commits {
+		if _, ok := tx.values[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Begin beginning the transaction, returns the id of the transaction.
+func (s *Store) Begin() (string, error) {
+	bb := make([]byte, 8)
+	_, err := rand.Read(bb)
+	if err != nil {
+		return "", errors.Wrap(err, "generate transaction id")
+	}
+
+	id := hex.EncodeToString(bb)
+	s.transactions.Store(id, &transaction{
+		values:  make(map[string][][]byte),
+		commits: make(map[string]int64),
+	})
+	return id, nil
+}
+
+// TxSet setting the value by the transaction, the value is not got until the transaction ends.
+func (s *Store) TxSet(id, key string, value []byte) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	if err := s.checkPartitioned(key); err != nil {
+		return err
+	}
+	if len(value) > maxMessageSize {
+		return errors.Errorf("transmitted message is larger than allowed")
+	}
+
+	tx, err := s.transaction(id)
+	if err != nil {
+		return err
+	}
+	defer tx.mutex.Unlock()
+
+	if len(value) > 0 {
+		tx.values[key] = append(tx.values[key], value)
+	}
+	return nil
+}
+
+// TxCommit committing the batch of messages by the transaction, the messages
+// are committed when the transaction ends.
+func (s *Store) TxCommit(id, key string, n int64) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	if err := s.checkPartitioned(key); err != nil {
+		return err
+	}
+	// the messages of the priority levels are committed by the last getting
+	if err := s.checkPrioritized(key); err != nil {
+		return err
+	}
+
+	tx, err := s.transaction(id)
+	if err != nil {
+		return err
+	}
+	defer tx.mutex.Unlock()
+
+	if n > 0 {
+		tx.commits[key] += n
+	}
+	return nil
+}
+
+// End ending the transaction, the values and the commits of the transaction
+// are applied together. The failed transaction is ended as well.
+func (s *Store) End(id string) error {
+	tx, err := s.transaction(id)
+	if err != nil {
+		return err
+	}
+	defer tx.mutex.Unlock()
+
+	tx.done = true
+	s.transactions.Delete(id)
+	return s.commitTransaction(id, tx)
+}
+
+// Abort aborting the transaction, the values and the commits of the transaction are dropped.
+func (s *Store) Abort(id string) error {
+	tx, err := s.transaction(id)
+	if err != nil {
+		return err
+	}
+	defer tx.mutex.Unlock()
+
+	tx.done = true
+	s.transactions.Delete(id)
+	return nil
+}
+
+// transaction the open transaction by the id, the transaction is locked.
+func (s *Store) transaction(id string) (*transaction, error) {
+	val, ok := s.transactions.Load(id)
+	if !ok {
+		return nil, errors.Errorf("transaction %s not found", id)
+	}
+
+	tx, ok := val.(*transaction)
+	if !ok {
+		return nil, errors.Errorf("fatal type assertion to transaction %[1]T %+[1]v", val)
+	}
+
+	tx.mutex.Lock()
+	if tx.done {
+		tx.mutex.Unlock()
+		return nil, errors.Errorf("transaction %s not found", id)
+	}
+	return tx, nil
+}
+
+// commitTransaction applies the transaction to the keys locked together,
+// the transaction is committed by writing its record before applying.
+func (s *Store) commitTransaction(id string, tx *transaction) (err error) {
+	keys := tx.keys()
+	if len(keys) == 0 {
+		return nil
+	}
+
+	size := int64(0)
+	for _, key := range keys {
+		if err = s.ensureLoaded(key); err != nil {
+			return err
+		}
+		if err = s.checkBroken(key); err != nil {
+			return err
+		}
+		for _, value := range tx.values[key] {
+			size += int64(len(value))
+		}
+	}
+
+	if s.config.quota(s.name) != (Quota{}) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+	}
+	for _, key := range keys {
+		if values := tx.values[key]; len(values) > 0 {
+			if err = s.checkQuota(key, values...); err != nil {
+				return err
+			}
+		}
+	}
+
+	// the spilling locks the messages of the keys,
+	// so it is done before locking the messages
+	err = s.reserve(size)
+	if err != nil {
+		return err
+	}
+
+	// the keys are locked in order, so the transactions don't lock each other
+	locked := make([]*message, 0, len(keys))
+	created := make(map[string]*message)
+	defer func() {
+		for key, m := range created {
+			if err != nil {
+				s.subject.remove(key, m)
+			}
+		}
+		for _, m := range locked {
+			m.mutex.Unlock()
+		}
+	}()
+
+	r := &record{}
+	now := time.Now()
+	for _, key := range keys {
+		m, lerr := s.lockTransactionKey(key, len(tx.values[key]) > 0, created)
+		if lerr != nil {
+			return lerr
+		}
+		locked = append(locked, m)
+
+		e := recordEntry{
+			key:       key,
+			base:      m.total(),
+			values:    s.unseen(key, tx.values[key], now),
+			committed: -1,
+		}
+		if n := tx.commits[key]; n > 0 {
+			if n > m.uncommitted() {
+				n = m.uncommitted()
+			}
+			e.committed = m.committed() + n
+		}
+		r.entries = append(r.entries, e)
+	}
+
+	name, err := s.writeRecord(id, r)
+	if err != nil {
+		return err
+	}
+
+	for i, e := range r.entries {
+		if err = s.apply(e, locked[i]); err != nil {
+			return err
+		}
+	}
+	s.applied.Store(name, struct{}{})
+
+	return nil
+}
+
+// lockTransactionKey locks the message of the key, the key set by the transaction
+// is created if absent, the created messages are collected to remove them on failure.
+func (s *Store) lockTransactionKey(key string, set bool, created map[string]*message) (*message, error) {
+	if !set {
+		return s.subject.lock(key)
+	}
+
+	m, loaded := s.subject.lockStore(key)
+	if !loaded {
+		created[key] = m
+	}
+
+	// the key may be partitioned meanwhile
+	err := s.checkPartitioned(key)
+	if err != nil {
+		if !loaded {
+			delete(created, key)
+			s.subject.remove(key, m)
+		}
+		m.mutex.Unlock()
+		return nil, err
+	}
+	return m, nil
+}
+
+// unseen the values of the key not seen within the dedup window of the key,
+// the values are seen by applying the transaction. The producers are not checked,
+// the values of the transaction are set without the producer.
+func (s *Store) unseen(key string, values [][]byte, now time.Time) [][]byte {
+	window := s.dedupWindowOf(key)
+	if window == 0 {
+		return values
+	}
+
+	d := s.digestsOf(key)
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	unseen := make([][]byte, 0, len(values))
+	sums := make(map[digestSum]struct{}, len(values))
+	for _, value := range values {
+		sum := dedupSum(nil, value)
+		if _, ok := sums[sum]; ok || d.seen(sum, now, window) {
+			continue
+		}
+		sums[sum] = struct{}{}
+		unseen = append(unseen, value)
+	}
+	return unseen
+}
+
+// record the changes of the committed transaction by the keys, the records
+// are replayed by loading, so the keys get the changes not unloaded before
+type record struct {
+	entries []recordEntry
+}
+
+type recordEntry struct {
+	key string
+	// base total messages of the key before the transaction
+	base int64
+	// values set by the transaction
+	values [][]byte
+	// committed messages of the key after the transaction, -1 if there are no commits
+	committed int64
+}
+
+// apply applies the entry to the locked message of the key,
+// the changes the key has got already are skipped.
+func (s *Store) apply(e recordEntry, m *message) error {
+	skip := m.total() - e.base
+	if skip < 0 {
+		skip = 0
+	}
+
+	held := m.held()
+	defer func() {
+		s.top().memory.Add(m.held() - held)
+	}()
+
+	var d *digests
+	now := time.Now()
+	window := s.dedupWindowOf(e.key)
+	if window > 0 {
+		d = s.digestsOf(e.key)
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+	}
+
+	for i := skip; i < int64(len(e.values)); i++ {
+		err := m.append(e.values[i])
+		if err != nil {
+			return err
+		}
+		if d != nil {
+			d.see(dedupSum(nil, e.values[i]), now, window)
+		}
+	}
+
+	if n := e.committed - m.committed(); n > 0 {
+		m.commit(n)
+		m.compact(false)
+	}
+	s.markDirty(e.key)
+	return nil
+}
+
+// writeRecord writes the record of the transaction, returns the name of the record file.
+// The record file is renamed after writing, so the record is written whole or not at all.
+func (s *Store) writeRecord(id string, r *record) (string, error) {
+	path := fmt.Sprintf("%s/%s", s.config.Path, transactionsDir)
+	err := os.MkdirAll(path, os.ModePerm)
+	if err != nil {
+		return "", errors.Wrapf(err, "mkdir by path - %s", path)
+	}
+
+	// the records are replayed in the order of the names
+	name := fmt.Sprintf("%020d-%s%s", time.Now().UnixNano(), id, recordExt)
+	tmp := fmt.Sprintf("%s/%s.tmp", path, name)
+	err = os.WriteFile(tmp, r.encode(), os.ModePerm)
+	if err != nil {
+		return "", errors.Wrapf(err, "write record by path - %s", tmp)
+	}
+
+	err = os.Rename(tmp, fmt.Sprintf("%s/%s", path, name))
+	return name, errors.Wrapf(err, "rename record by path - %s", tmp)
+}
+
+// encode the record: the number of the entries and every entry by the key,
+// the base, the values and the committed messages, little endian
+func (r *record) encode() []byte {
+	buf := &bytes.Buffer{}
+	write := func(v any) {
+		_ = binary.Write(buf, binary.LittleEndian, v)
+	}
+
+	write(uint32(len(r.entries)))
+	for _, e := range r.entries {
+		write(uint32(len(e.key)))
+		buf.WriteString(e.key)
+		write(e.base)
+		write(uint32(len(e.values)))
+		for _, value := range e.values {
+			write(uint32(len(value)))
+			buf.Write(value)
+		}
+		write(e.committed)
+	}
+
+	return buf.Bytes()
+}
+
+func decodeRecord(bb []byte) (*record, error) {
+	buf := bytes.NewReader(bb)
+	read := func(v any) error {
+		return binary.Read(buf, binary.LittleEndian, v)
+	}
+	readBytes := func(max uint32) ([]byte, error) {
+		var n uint32
+		if err := read(&n); err != nil {
+			return nil, err
+		}
+		if n > max || int64(n) > int64(buf.Len()) {
+			return nil, errors.Errorf("length %d out of range", n)
+		}
+		b := make([]byte, n)
+		_, err := buf.Read(b)
+		return b, err
+	}
+
+	var n uint32
+	if err := read(&n); err != nil {
+		return nil, err
+	}
+
+	r := &record{}
+	for i := uint32(0); i < n; i++ {
+		key, err := readBytes(uint32(buf.Len()))
+		if err != nil {
+			return nil, err
+		}
+		e := recordEntry{key: string(key)}
+		if err = read(&e.base); err != nil {
+			return nil, err
+		}
+
+		var values uint32
+		if err = read(&values); err != nil {
+			return nil, err
+		}
+		for j := uint32(0); j < values; j++ {
+			value, err := readBytes(maxMessageSize)
+			if err != nil {
+				return nil, err
+			}
+			e.values = append(e.values, value)
+		}
+
+		if err = read(&e.committed); err != nil {
+			return nil, err
+		}
+		r.entries = append(r.entries, e)
+	}
+
+	return r, nil
+}
+
+// replayTransactions replays the records of the committed transactions
+// of the store and its namespaces.
+func (s *Store) replayTransactions() error {
+	err := s.replayRecords()
+	if err != nil {
+		return err
+	}
+
+	return s.rangeNamespaces(func(ns *Store) error {
+		return errors.Wrapf(ns.replayRecords(), "replay namespace %s", ns.name)
+	})
+}
+
+// replayRecords replays the records of the store in the order of the commits,
+// the records having the keys failed to load stay to be replayed later.
+func (s *Store) replayRecords() error {
+	path := fmt.Sprintf("%s/%s", s.config.Path, transactionsDir)
+	entities, err := os.ReadDir(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "read dir by path - %s", path)
+	}
+
+	for _, e := range entities {
+		if !strings.HasSuffix(e.Name(), recordExt) {
+			// the record has not been written whole
+			continue
+		}
+
+		recordPath := fmt.Sprintf("%s/%s", path, e.Name())
+		bb, err := os.ReadFile(recordPath)
+		if err != nil {
+			return errors.Wrapf(err, "read record by path - %s", recordPath)
+		}
+		r, err := decodeRecord(bb)
+		if err != nil {
+			return errors.Wrapf(err, "record %s is corrupted", recordPath)
+		}
+
+		replayed, err := s.replay(r)
+		if err != nil {
+			return errors.Wrapf(err, "replay record by path - %s", recordPath)
+		}
+		if replayed {
+			s.applied.Store(e.Name(), struct{}{})
+		}
+	}
+
+	return nil
+}
+
+// replay applies the record to the keys, reports whether all keys are replayed.
+func (s *Store) replay(r *record) (bool, error) {
+	replayed := true
+	for _, e := range r.entries {
+		err := s.ensureLoaded(e.key)
+		if err == nil {
+			err = s.checkBroken(e.key)
+		}
+		if err != nil {
+			// the key failed to load is reported by the loading
+			replayed = false
+			continue
+		}
+
+		size := int64(0)
+		for _, value := range e.values {
+			size += int64(len(value))
+		}
+		err = s.reserve(size)
+		if err != nil {
+			return false, err
+		}
+
+		m, _ := s.subject.lockStore(e.key)
+		err = s.apply(e, m)
+		m.mutex.Unlock()
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return replayed, nil
+}
+
+// appliedRecords the names of the records applied in memory.
+func (s *Store) appliedRecords() []string {
+	names := make([]string, 0)
+	s.applied.Range(func(name, _ any) bool {
+		n, _ := name.(string)
+		names = append(names, n)
+		return true
+	})
+	return names
+}
+
+// removeRecords removes the records written by the unloading.
+func (s *Store) removeRecords(names []string) (err error) {
+	for _, name := range names {
+		path := fmt.Sprintf("%s/%s/%s", s.config.Path, transactionsDir, name)
+		rerr := os.Remove(path)
+		if rerr != nil && !os.IsNotExist(rerr) {
+			multierr.AppendInto(&err, errors.Wrapf(rerr, "remove record by path - %s", path))
+			continue
+		}
+		s.applied.Delete(name)
+	}
+
+	return err
+}
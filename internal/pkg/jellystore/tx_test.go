@@ -0,0 +1,179 @@
+// Package store
+/*
+   Copyright 2022 Jellydb in-memory database
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+       http://www.apache.org/licenses/LICENSE-2.0
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package jellystore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Transaction(t *testing.T) {
+	path := t.TempDir()
+	store, err := New(&Config{Path: path})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, store.Set("input", []byte(fmt.Sprint("input-", i))))
+	}
+	require.NoError(t, store.Unload(context.Background()))
+
+	tx, err := store.Begin()
+	require.NoError(t, err)
+	require.NoError(t, store.TxSet(tx, "output", []byte("output-1")))
+	require.NoError(t, store.TxSet(tx, "other", []byte("other-1")))
+	require.NoError(t, store.TxSet(tx, "output", []byte("output-2")))
+	require.NoError(t, store.TxCommit(tx, "input", 2))
+	require.Error(t, store.TxSet(tx, transactionsDir, []byte("reserved")))
+
+	// the transaction is not seen until it ends
+	_, err = store.Get("output", 10)
+	require.Error(t, err)
+	bb, err := store.Get("input", 10)
+	require.NoError(t, err)
+	require.Len(t, bb, 3)
+
+	aborted, err := store.Begin()
+	require.NoError(t, err)
+	require.NoError(t, store.TxSet(aborted, "output", []byte("aborted")))
+	require.NoError(t, store.TxCommit(aborted, "input", 1))
+	require.NoError(t, store.Abort(aborted))
+	require.Error(t, store.End(aborted))
+
+	require.NoError(t, store.End(tx))
+	require.Error(t, store.TxSet(tx, "output", []byte("ended")))
+
+	check := func(t *testing.T, store *Store) {
+		bb, err := store.Get("output", 10)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("output-1"), []byte("output-2")}, bb)
+
+		bb, err = store.Get("other", 10)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("other-1")}, bb)
+
+		bb, err = store.Get("input", 10)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("input-2")}, bb)
+	}
+	check(t, store)
+
+	// the transaction ended after the unloading is recovered by its record
+	record := func() string {
+		entities, err := os.ReadDir(path + "/" + transactionsDir)
+		require.NoError(t, err)
+		require.Len(t, entities, 1)
+		return path + "/" + transactionsDir + "/" + entities[0].Name()
+	}()
+	for _, lazy := range []bool{false, true} {
+		loadStore, err := New(&Config{Path: path, LazyLoad: lazy})
+		require.NoError(t, err)
+		require.NoError(t, loadStore.Load(context.Background()))
+		check(t, loadStore)
+	}
+
+	// the record replayed after the unloading doesn't duplicate the changes
+	recorded, err := os.ReadFile(record)
+	require.NoError(t, err)
+	require.NoError(t, store.Unload(context.Background()))
+	require.NoFileExists(t, record)
+	require.NoError(t, os.WriteFile(record, recorded, os.ModePerm))
+
+	loadStore, err := New(&Config{Path: path})
+	require.NoError(t, err)
+	require.NoError(t, loadStore.Load(context.Background()))
+	check(t, loadStore)
+
+	require.NoError(t, loadStore.Unload(context.Background()))
+	require.NoFileExists(t, record)
+}
+
+func TestStore_TransactionFailed(t *testing.T) {
+	store, err := New(&Config{Path: t.TempDir(), Quota: Quota{KeyMessages: 1}})
+	require.NoError(t, err)
+	require.NoError(t, store.Partition("orders", 2))
+
+	_, err = store.Begin()
+	require.NoError(t, err)
+	require.Error(t, store.TxSet("unknown", "key", []byte("message")))
+
+	tx, err := store.Begin()
+	require.NoError(t, err)
+	require.Error(t, store.TxSet(tx, "orders", []byte("message")))
+
+	// the transaction exceeding the quota applies nothing
+	require.NoError(t, store.TxSet(tx, "a", []byte("message")))
+	require.NoError(t, store.TxSet(tx, "b", []byte("message-1")))
+	require.NoError(t, store.TxSet(tx, "b", []byte("message-2")))
+	require.Error(t, store.End(tx))
+
+	keys, err := store.List("")
+	require.NoError(t, err)
+	require.Equal(t, []string{"orders"}, keys)
+
+	// the transaction committing the key absent applies nothing
+	tx, err = store.Begin()
+	require.NoError(t, err)
+	require.NoError(t, store.TxSet(tx, "a", []byte("message")))
+	require.NoError(t, store.TxCommit(tx, "absent", 1))
+	require.Error(t, store.End(tx))
+
+	keys, err = store.List("")
+	require.NoError(t, err)
+	require.Equal(t, []string{"orders"}, keys)
+}
+
+func TestStore_TransactionDedup(t *testing.T) {
+	path := t.TempDir()
+	store, err := New(&Config{Path: path})
+	require.NoError(t, err)
+	require.NoError(t, store.Dedup("key", time.Hour))
+	require.NoError(t, store.Set("key", []byte("message-1")))
+
+	// the values of the transaction seen within the window are dropped
+	tx, err := store.Begin()
+	require.NoError(t, err)
+	require.NoError(t, store.TxSet(tx, "key", []byte("message-1")))
+	require.NoError(t, store.TxSet(tx, "key", []byte("message-2")))
+	require.NoError(t, store.TxSet(tx, "key", []byte("message-2")))
+	require.NoError(t, store.End(tx))
+
+	// and the values of the transaction are seen by the settings
+	require.NoError(t, store.Set("key", []byte("message-2")))
+
+	want := [][]byte{[]byte("message-1"), []byte("message-2")}
+	bb, err := store.Get("key", 10)
+	require.NoError(t, err)
+	require.Equal(t, want, bb)
+
+	// the record replayed after the unloading doesn't bring the duplicates back
+	entities, err := os.ReadDir(path + "/" + transactionsDir)
+	require.NoError(t, err)
+	require.Len(t, entities, 1)
+	record := path + "/" + transactionsDir + "/" + entities[0].Name()
+	recorded, err := os.ReadFile(record)
+	require.NoError(t, err)
+	require.NoError(t, store.Unload(context.Background()))
+	require.NoError(t, os.WriteFile(record, recorded, os.ModePerm))
+
+	loadStore, err := New(&Config{Path: path})
+	require.NoError(t, err)
+	require.NoError(t, loadStore.Load(context.Background()))
+	bb, err = loadStore.Get("key", 10)
+	require.NoError(t, err)
+	require.Equal(t, want, bb)
+}
@@ -16,65 +16,165 @@ package jellystore
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"go.uber.org/multierr"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/baibikov/jellydb/pkg/utils"
 )
 
 func (s *Store) Unload(ctx context.Context) error {
-	return s.unload(ctx)
+	if s.root != nil {
+		return s.root.Unload(ctx)
+	}
+
+	report := &UnloadReport{}
+	defer func(start time.Time) {
+		report.Duration = time.Since(start)
+		s.lastUnload.Store(report)
+	}(time.Now())
+
+	err := s.unload(ctx, report)
+	if err != nil {
+		return err
+	}
+
+	return s.unloadNamespaces(ctx, report)
+}
+
+// UnloadReport the result of the unloading.
+type UnloadReport struct {
+	// Keys written to the file storage, only the keys
+	// changed by setting or committing are written.
+	Keys int64
+	// Bytes written to the file storage.
+	Bytes int64
+	// Duration of the unloading.
+	Duration time.Duration
+}
+
+// LastUnload getting the report of the last unloading, nil before the first one.
+func (s *Store) LastUnload() *UnloadReport {
+	report, _ := s.top().lastUnload.Load().(*UnloadReport)
+	return report
+}
+
+// markDirty marks the key changed since the last unloading.
+func (s *Store) markDirty(key string) {
+	s.subject.shard(key).markDirty(key)
+}
+
+// unload writes the keys changed since the last unloading,
+// the shards are written in parallel by their own workers.
+// The records of the transactions applied before are removed after writing.
+func (s *Store) unload(ctx context.Context, report *UnloadReport) error {
+	applied := s.appliedRecords()
+
+	var mutex sync.Mutex
+	eg := errgroup.Group{}
+	for _, sh := range s.subject.shards {
+		sh := sh
+		eg.Go(func() error {
+			keys, bytes, err := s.unloadShard(ctx, sh)
+
+			mutex.Lock()
+			report.Keys += keys
+			report.Bytes += bytes
+			mutex.Unlock()
+			return err
+		})
+	}
+
+	err := eg.Wait()
+	if err != nil {
+		return err
+	}
+
+	// the digests are written after the messages they have seen
+	n, err := s.unloadDigests()
+	report.Bytes += n
+	if err != nil {
+		return err
+	}
+
+	return s.removeRecords(applied)
 }
 
-func (s *Store) unload(ctx context.Context) error {
-	// iterate over all message values that
-	// are present and load only unloaded messages
-	return s.subject.srange(func(key string, value *message) error {
+// unloadShard writes the dirty keys of the shard, returns the written keys and bytes.
+func (s *Store) unloadShard(ctx context.Context, sh *shard) (keys, bytes int64, err error) {
+	for _, key := range sh.dirtyKeys() {
 		select {
 		case <-ctx.Done():
-			return errors.New("failed to upload all file data")
+			return keys, bytes, errors.New("failed to upload all file data")
 		default:
-			err := s.unloadByFile(key, value)
-			if err != nil {
-				return errors.Wrapf(err, "unload by key - %s", key)
-			}
 		}
 
-		return nil
-	})
+		m, err := s.subject.lock(key)
+		if err != nil {
+			// the key has been deleted
+			sh.clean(key)
+			continue
+		}
+
+		n, err := s.unloadByFile(key, m)
+		m.mutex.Unlock()
+		if err != nil {
+			return keys, bytes, errors.Wrapf(err, "unload by key - %s", key)
+		}
+
+		if n > 0 {
+			keys++
+			bytes += n
+		}
+	}
+
+	return keys, bytes, nil
 }
 
-func (s *Store) unloadByFile(key string, m *message) (err error) {
-	if len(m.queue) == 0 {
-		return nil
+// unloadByFile writes the messages of the locked message of the key not written yet
+// and the offsets, returns the written bytes. The key is clean after the writing.
+func (s *Store) unloadByFile(key string, m *message) (n int64, err error) {
+	// the key changed during the writing is marked dirty again
+	s.subject.shard(key).clean(key)
+	defer func() {
+		if err != nil {
+			s.markDirty(key)
+		}
+	}()
+
+	// the compacted queue may be empty with the commits not written yet
+	if m.len() == 0 && m.lastCommitIndex == m.committedIndex {
+		return 0, nil
 	}
 
-	dirPath := fmt.Sprintf("%s/%s", s.config.Path, key)
+	dirPath := s.keyPath(key)
 	err = utils.CreateFileIfNotExists(dirPath)
 	if err != nil {
-		return errors.Wrap(err, "creating file")
+		return 0, errors.Wrap(err, "creating file")
 	}
 
 	metaInfo, err := openMeta(fmt.Sprintf("%s/%s", dirPath, metaFileName))
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer multierr.AppendInvoke(&err, multierr.Close(metaInfo))
 
 	committedOffset, err := metaInfo.committed.offset()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	writtenOffset, err := metaInfo.written.offset()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	logInfo, err := openLog(fmt.Sprintf("%s/%s", dirPath, logFileName))
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer multierr.AppendInvoke(&err, multierr.Close(logInfo))
 
@@ -84,25 +184,58 @@ func (s *Store) unloadByFile(key string, m *message) (err error) {
 		newCommittedOffset += (maxMessageSize + messageLen) * (m.lastCommitIndex - m.committedIndex)
 	}
 
-	// convert the written messages to the final offset
+	// convert the written messages to the final offset,
+	// the messages are written by the batches of the slots
 	newWrittenOffset := m.writtenOffset
-	for i := m.writtenIndex; i < m.len(); i++ {
-		newWrittenOffset += maxMessageSize + messageLen
+	for i := m.writtenIndex; i < m.len(); i += unloadBatchSlots {
+		to := i + unloadBatchSlots
+		if to > m.len() {
+			to = m.len()
+		}
 
-		err = logInfo.write(m.queue[i])
+		// the spilled messages are written before
+		err = logInfo.writeBatch(m.queue[i-m.spilled : to-m.spilled])
 		if err != nil {
-			return errors.Wrapf(err, "write message by offset %d", newWrittenOffset)
+			return n, errors.Wrapf(err, "write messages by offset %d", newWrittenOffset)
 		}
+
+		written := (to - i) * (maxMessageSize + messageLen)
+		newWrittenOffset += written
+		n += written
+		s.unloadedBytes.Add(written)
 	}
 
+	// the log reader doesn't see the written messages
+	if n > 0 {
+		m.closeReader()
+	}
+
+	// the appending keeps the log within the 4 bytes offsets
 	err = metaInfo.written.write(uint32(newWrittenOffset))
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	err = metaInfo.committed.write(uint32(newCommittedOffset))
 	if err != nil {
-		return err
+		return 0, err
+	}
+	s.unloadedBytes.Add(metaSize)
+	n += metaSize
+
+	// the sequences of the producers are written with the messages they set,
+	// the file of the expired producers is removed
+	if m.producers != nil {
+		m.expireProducers(time.Now(), s.config.dedupWindow())
+		written, err := writeProducers(dirPath, m.producers)
+		if err != nil {
+			return n, err
+		}
+		if len(m.producers) == 0 {
+			m.producers = nil
+		}
+		s.unloadedBytes.Add(written)
+		n += written
 	}
 
 	m.writtenOffset = newWrittenOffset
@@ -115,8 +248,15 @@ func (s *Store) unloadByFile(key string, m *message) (err error) {
 		m.committedOffset = committedOffset.int64()
 	}
 
-	m.committedIndex = m.committedOffset / (messageLen + maxMessageSize)
-	m.writtenIndex = m.writtenOffset / (messageLen + maxMessageSize)
+	// indexes are relative to the first queue message
+	m.committedIndex = (m.committedOffset - m.offset) / (messageLen + maxMessageSize)
+	m.writtenIndex = (m.writtenOffset - m.offset) / (messageLen + maxMessageSize)
+
+	// the written committed messages are dropped from memory
+	s.top().memory.Add(-m.compact(false))
 
-	return nil
+	return n, nil
 }
+
+// unloadBatchSlots slots written to the log at once, about 1MB
+const unloadBatchSlots = 2048
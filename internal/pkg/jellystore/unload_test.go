@@ -257,3 +257,55 @@ func TestStore_Unload(t *testing.T) {
 		})
 	}
 }
+
+func TestStore_UnloadDirty(t *testing.T) {
+	const slot = messageLen + maxMessageSize
+
+	store, err := New(&Config{Path: t.TempDir()})
+	require.NoError(t, err)
+	team, err := store.Namespace("team")
+	require.NoError(t, err)
+	require.Nil(t, store.LastUnload())
+
+	require.NoError(t, store.Set("a", []byte("message1")))
+	require.NoError(t, store.Set("a", []byte("message2")))
+	require.NoError(t, team.Set("b", []byte("message1")))
+
+	tests := []struct {
+		Name   string
+		Change func()
+		Keys   int64
+		Bytes  int64
+	}{
+		{Name: "set", Change: func() {}, Keys: 2, Bytes: 3*slot + 2*metaSize},
+		{Name: "unchanged", Change: func() {}},
+		{
+			Name:   "commit",
+			Change: func() { require.NoError(t, store.Commit("a", 1)) },
+			Keys:   1,
+			Bytes:  metaSize,
+		},
+		{
+			Name:   "namespace-set",
+			Change: func() { require.NoError(t, team.Set("b", []byte("message2"))) },
+			Keys:   1,
+			Bytes:  slot + metaSize,
+		},
+		{
+			Name:   "deleted",
+			Change: func() { require.NoError(t, team.Delete("b")) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			tt.Change()
+			require.NoError(t, team.Unload(context.Background()))
+
+			report := store.LastUnload()
+			require.NotNil(t, report)
+			require.Equal(t, tt.Keys, report.Keys)
+			require.Equal(t, tt.Bytes, report.Bytes)
+		})
+	}
+}
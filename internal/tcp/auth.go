@@ -0,0 +1,87 @@
+package tcp
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/internal/auth"
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+// auth authenticates the connection, the failed attempt
+// leaves the connection unauthenticated.
+func (h *handler) auth() (err error) {
+	req := &messages.AuthRequest{}
+	err = protomarshal.NewEncoder(h.request, authMessageSize).Encode(req)
+	if err != nil {
+		return errors.Wrap(err, "get 'auth' state")
+	}
+	h.key = req.GetUsername()
+
+	if h.server.config.Auth == nil {
+		return errors.Wrap(h.response(nil), "send response message")
+	}
+
+	principal, err := h.server.config.Auth.Authenticate(req.GetToken(), req.GetUsername(), req.GetPassword())
+	if err != nil {
+		h.principal = nil
+		h.log.Warn(err)
+		return errors.Wrap(h.response(err), "send response message")
+	}
+	h.principal = principal
+	h.log = h.log.WithField("user", principal.Name)
+
+	err = h.response(nil)
+	return errors.Wrap(err, "send response message")
+}
+
+// authenticated checks the connection is authenticated
+// before the request of the type is dispatched, the auth and hello requests are not checked.
+func (h *handler) authenticated(typ int) error {
+	if h.server.config.Auth == nil || typ == protomarshal.AuthMessageType ||
+		typ == protomarshal.HelloMessageType || h.principal != nil {
+		return nil
+	}
+	return errors.Wrap(auth.ErrUnauthenticated, "authenticate the connection first")
+}
+
+// authorize checks the permission to the key of the request namespace,
+// all keys are allowed without auth.
+func (h *handler) authorize(perm auth.Permission, key string) error {
+	if h.server.config.Auth == nil {
+		return nil
+	}
+	if h.principal == nil {
+		return errors.Wrap(auth.ErrUnauthenticated, "authenticate the connection first")
+	}
+	return h.principal.Authorize(perm, h.ns, key)
+}
+
+// authorizeNamespace checks the permission to any key of the request namespace.
+func (h *handler) authorizeNamespace(perm auth.Permission) error {
+	if h.server.config.Auth == nil {
+		return nil
+	}
+	if h.principal == nil {
+		return errors.Wrap(auth.ErrUnauthenticated, "authenticate the connection first")
+	}
+	if !h.principal.Granted(perm, h.ns) {
+		return errors.Wrapf(auth.ErrForbidden, "user %s has no %s permission in namespace %q", h.principal.Name, perm, h.ns)
+	}
+	return nil
+}
+
+// readable filters the keys of the request namespace the connection has the read permission to.
+func (h *handler) readable(keys []string) []string {
+	if h.server.config.Auth == nil {
+		return keys
+	}
+
+	allowed := keys[:0]
+	for _, key := range keys {
+		if h.principal != nil && h.principal.Allowed(auth.PermissionRead, h.ns, key) {
+			allowed = append(allowed, key)
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,67 @@
+package tcp
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/baibikov/jellydb/internal/auth"
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+func testAuth(t *testing.T, conn net.Conn, req *messages.AuthRequest) int32 {
+	t.Helper()
+
+	_, err := conn.Write([]byte("8"))
+	require.NoError(t, err)
+	err = protomarshal.NewDecoder(conn).Decode(req)
+	require.NoError(t, err)
+
+	resp := &messages.Response{}
+	err = protomarshal.NewEncoder(conn, 1024).Encode(resp)
+	require.NoError(t, err)
+	return resp.GetCode()
+}
+
+func TestServer_Auth(t *testing.T) {
+	authenticator, err := auth.New(&auth.Config{Users: []auth.User{
+		{
+			Name:  "reader",
+			Token: "reader-token",
+			Rules: []auth.Rule{{Permissions: []auth.Permission{auth.PermissionRead}}},
+		},
+		{
+			Name:     "writer",
+			Password: "secret",
+			Rules:    []auth.Rule{{Prefix: "ke", Permissions: []auth.Permission{auth.PermissionWrite}}},
+		},
+	}})
+	require.NoError(t, err)
+
+	server := runTestServerWithConfig(t, &Config{
+		Addr: "127.0.0.1:0",
+		Auth: authenticator,
+	}, &slowJelly{})
+	defer server.Close()
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the connection stays usable after the rejections
+	require.EqualValues(t, StatusCodeUnauthorized, testSet(t, conn))
+	require.EqualValues(t, StatusCodeUnauthorized, testAuth(t, conn, &messages.AuthRequest{Token: "wrong"}))
+	require.EqualValues(t, StatusCodeUnauthorized, testSet(t, conn))
+
+	require.EqualValues(t, statusCodeOK, testAuth(t, conn, &messages.AuthRequest{Token: "reader-token"}))
+	require.EqualValues(t, StatusCodeForbidden, testSet(t, conn))
+
+	require.EqualValues(t, statusCodeOK, testAuth(t, conn, &messages.AuthRequest{Username: "writer", Password: "secret"}))
+	require.EqualValues(t, statusCodeOK, testSet(t, conn))
+
+	// the failed attempt drops the authentication
+	require.EqualValues(t, StatusCodeUnauthorized, testAuth(t, conn, &messages.AuthRequest{Username: "writer"}))
+	require.EqualValues(t, StatusCodeUnauthorized, testSet(t, conn))
+}
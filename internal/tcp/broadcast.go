@@ -1,15 +1,21 @@
 package tcp
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"net"
-	"strconv"
+	"os"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"go.uber.org/multierr"
 
+	"github.com/baibikov/jellydb/internal/auth"
+	"github.com/baibikov/jellydb/internal/metrics"
 	"github.com/baibikov/jellydb/internal/pkg/jell"
 	"github.com/baibikov/jellydb/pkg/protomarshal"
 	"github.com/baibikov/jellydb/pkg/routing"
@@ -23,7 +29,7 @@ func (s *Server) Broadcast(ctx context.Context) error {
 			return nil
 		default:
 			conn, err := s.listener.Accept()
-			if s.closed {
+			if s.closed.Load() {
 				// not error because client has closed
 				return nil
 			}
@@ -31,98 +37,461 @@ func (s *Server) Broadcast(ctx context.Context) error {
 				return errors.Wrap(err, "accepting client connection")
 			}
 
-			go func() {
-				err := newhandler(conn, s.jelly).do(ctx)
-				if isSysError(err) {
-					logrus.Info("close connection")
-					return
-				}
-				if err != nil {
-					logrus.Error(err)
-				}
-			}()
+			h := s.newhandler(conn)
+			err = s.track(h)
+			if errors.Is(err, ErrTooManyConnections) {
+				h.log.Warn(err)
+				go h.reject(err)
+				continue
+			}
+			if err != nil {
+				tryClose(h.log, conn, "broadcast")
+				return nil
+			}
+
+			go s.serve(ctx, h)
 		}
 	}
 }
 
+func (s *Server) serve(ctx context.Context, h *handler) {
+	s.metrics.ConnOpened()
+	defer s.metrics.ConnClosed()
+	defer s.untrack(h)
+
+	h.log.Info("open connection")
+	defer h.abort()
+
+	err := h.do(ctx)
+	if isSysError(err) || isClosedError(err) {
+		h.log.Info("close connection")
+		return
+	}
+	if err != nil {
+		h.log.Error(err)
+	}
+}
+
 type handler struct {
-	conn  net.Conn
-	jelly jell.Jelly
+	server  *Server
+	conn    net.Conn
+	jelly   jell.Jelly
+	metrics *metrics.Metrics
+	// log connection-scoped logger
+	log *logrus.Entry
+	// addr address of the client for the limits
+	addr string
+	// principal the authenticated user of the connection
+	principal *auth.Principal
+	// namespace selected for the connection, empty is the default one
+	namespace string
+	// tx transaction open by the connection, nil if there is none
+	tx *transaction
+
+	// version of the protocol of the connection, switched by the hello request
+	// before it is answered
+	version int
+	// started the first request of the connection has been read
+	started bool
+
+	// request frame of the current request
+	request io.Reader
+	// admitted the current request holds an in-flight place
+	admitted bool
+	// inFlight requests read and not answered yet, without the rejected ones
+	inFlight atomic.Int32
+	// pending requests read and not answered yet, guarded by the server mutex
+	pending int
+	// readErr the error of reading the requests
+	readErr error
+
+	// namespace, key and code of the response to the current request
+	ns   string
+	key  string
+	code int32
 }
 
-func newhandler(conn net.Conn, jelly jell.Jelly) *handler {
-	return &handler{conn: conn, jelly: jelly}
+func (s *Server) newhandler(conn net.Conn) *handler {
+	return &handler{
+		server:  s,
+		conn:    conn,
+		jelly:   s.jelly,
+		metrics: s.metrics,
+		log: logrus.WithFields(logrus.Fields{
+			"remote_addr": conn.RemoteAddr().String(),
+			"conn_id":     s.connID.Add(1),
+		}),
+		addr:    clientAddr(conn),
+		version: protomarshal.VersionLegacy,
+	}
 }
 
 const (
 	pingMessageSize = 1
+	// the version of the protocol
+	helloMessageSize = 1
 
-	setMessageSize    = 256
-	getMessageSize    = 256
-	commitMessageSize = 256
+	// the message up to 512 bytes and the key
+	setMessageSize = 1024
+	// the filter up to the message size and the key
+	getMessageSize       = 1024
+	commitMessageSize    = 256
+	listMessageSize      = 256
+	deleteMessageSize    = 256
+	purgeMessageSize     = 256
+	statMessageSize      = 256
+	authMessageSize      = 256
+	useMessageSize       = 256
+	partitionMessageSize = 256
+	// the messages up to 512 bytes with the keys, about sixty of them
+	setBatchMessageSize = 32 * 1024
+	beginMessageSize    = 256
+	endMessageSize      = 256
+	dedupMessageSize    = 256
 )
 
-const (
-	setMessageType = iota + 1
-	getMessageType
-	commitMessageType
-)
+// messageSizes max sizes of the request messages by type
+var messageSizes = map[int]int{
+	protomarshal.HelloMessageType:     helloMessageSize,
+	protomarshal.SetMessageType:       setMessageSize,
+	protomarshal.GetMessageType:       getMessageSize,
+	protomarshal.CommitMessageType:    commitMessageSize,
+	protomarshal.ListMessageType:      listMessageSize,
+	protomarshal.DeleteMessageType:    deleteMessageSize,
+	protomarshal.PurgeMessageType:     purgeMessageSize,
+	protomarshal.StatMessageType:      statMessageSize,
+	protomarshal.AuthMessageType:      authMessageSize,
+	protomarshal.UseMessageType:       useMessageSize,
+	protomarshal.PartitionMessageType: partitionMessageSize,
+	protomarshal.SetBatchMessageType:  setBatchMessageSize,
+	protomarshal.BeginMessageType:     beginMessageSize,
+	protomarshal.EndMessageType:       endMessageSize,
+	protomarshal.DedupMessageType:     dedupMessageSize,
+}
+
+// maxMessageSize the largest request frame
+func maxMessageSize() int {
+	size := 0
+	for _, s := range messageSizes {
+		if s > size {
+			size = s
+		}
+	}
+	return size + protomarshal.FrameHeaderSize
+}
+
+var commandNames = map[int]string{
+	protomarshal.HelloMessageType:     "hello",
+	protomarshal.SetMessageType:       "set",
+	protomarshal.GetMessageType:       "get",
+	protomarshal.CommitMessageType:    "commit",
+	protomarshal.ListMessageType:      "list",
+	protomarshal.DeleteMessageType:    "delete",
+	protomarshal.PurgeMessageType:     "purge",
+	protomarshal.StatMessageType:      "stat",
+	protomarshal.AuthMessageType:      "auth",
+	protomarshal.UseMessageType:       "use",
+	protomarshal.PartitionMessageType: "partition",
+	protomarshal.SetBatchMessageType:  "mset",
+	protomarshal.BeginMessageType:     "begin",
+	protomarshal.EndMessageType:       "end",
+	protomarshal.DedupMessageType:     "dedup",
+}
+
+// request read from the connection and waiting for its response
+type request struct {
+	typ   int
+	start time.Time
+	frame []byte
+	// err the request is failed to be read, the connection is closed after its response
+	err error
+	// rejected the request is over the in-flight limit of the connection
+	rejected bool
+}
 
+// do serves the requests of the connection: the requests are read ahead by
+// the reader (the client may send them without waiting for the responses)
+// and processed one by one, the responses are written in the order of the requests.
 func (h *handler) do(ctx context.Context) (err error) {
+	requests := make(chan *request, h.server.config.MaxInFlight)
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		h.readErr = h.read(ctx, requests, done)
+	}()
 	defer func() {
-		tryClose(h.conn, "do")
+		close(done)
+		tryClose(h.log, h.conn, "do")
+		<-stopped
 	}()
 
+	for r := range requests {
+		// the connection is closed after a failed request,
+		// the rest of the request may be left unread
+		err = h.distribute(r)
+		h.server.setAnswered(h)
+		if err != nil {
+			return err
+		}
+	}
+
+	<-stopped
+	return h.readErr
+}
+
+// read reads the requests until the connection fails, is idle for too long
+// or the server is shutting down, the requests channel is closed then.
+func (h *handler) read(ctx context.Context, requests chan<- *request, done <-chan struct{}) error {
+	defer close(requests)
+
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
 		default:
-			if err := h.distribute(); err != nil {
-				logrus.Error(err)
+		}
+
+		typ, err := h.ping()
+		if isTimeoutError(err) {
+			// the connection waiting for the responses is not idle
+			if h.server.pending(h) {
+				continue
 			}
+			// the idle handler is woken up by the shutdown
+			if !h.server.closed.Load() {
+				h.log.Info("idle timeout")
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		r := h.readRequest(typ)
+		select {
+		case requests <- r:
+		case <-done:
+			return nil
+		}
+		if r.err != nil {
+			return nil
 		}
 	}
 }
 
-func (h *handler) distribute() error {
+// ping waiting for the next request, reads the type of the request.
+func (h *handler) ping() (int, error) {
+	err := h.conn.SetReadDeadline(deadline(h.server.config.IdleTimeout))
+	if err != nil {
+		return 0, errors.Wrap(err, "set idle deadline")
+	}
+
 	bb := make([]byte, pingMessageSize)
 	n, err := h.conn.Read(bb)
 	if err != nil {
-		return errors.Wrap(err, "read ping message")
+		return 0, errors.Wrap(err, "read ping message")
 	}
 	if n == 0 {
-		return errors.New("ping message empty")
+		return 0, errors.New("ping message empty")
+	}
+	// the busy handler is not woken up by the shutdown,
+	// the request deadline is set by reading the request
+	h.server.setBusy(h)
+
+	return protomarshal.ParseType(bb)
+}
+
+// readRequest reads the frame of the request by the type,
+// the request over the in-flight limit of the connection is rejected.
+func (h *handler) readRequest(typ int) *request {
+	r := &request{typ: typ, start: time.Now()}
+
+	size, ok := messageSizes[typ]
+	if !ok {
+		r.err = errors.Errorf("undefined message type - %d", typ)
+		return r
+	}
+
+	err := h.conn.SetReadDeadline(deadline(h.server.config.ReadTimeout))
+	if err != nil {
+		r.err = errors.Wrap(err, "set read deadline")
+		return r
+	}
+	switch {
+	case typ == protomarshal.HelloMessageType:
+		r.err = h.readHello()
+	case h.version == protomarshal.VersionFramed:
+		r.frame, r.err = protomarshal.ReadFrame(h.conn, size)
+	default:
+		r.frame, r.err = protomarshal.ReadBare(h.conn, size)
+	}
+	h.started = true
+	if r.err != nil {
+		return r
+	}
+
+	if limit := h.server.config.MaxInFlight; limit > 0 && h.inFlight.Load() >= int32(limit) {
+		r.rejected = true
+		return r
+	}
+	h.inFlight.Add(1)
+	return r
+}
+
+// Write writes the response to the current request, the in-flight place of the
+// request is released before, so the next request sent after the response fits the limit.
+func (h *handler) Write(bb []byte) (int, error) {
+	h.settle()
+
+	// the legacy protocol writes the bare messages without the length prefix
+	if h.version != protomarshal.VersionFramed {
+		n, err := h.conn.Write(bb[protomarshal.FrameHeaderSize:])
+		return n + protomarshal.FrameHeaderSize, err
+	}
+	return h.conn.Write(bb)
+}
+
+// settle releases the in-flight place of the current request.
+func (h *handler) settle() {
+	if h.admitted {
+		h.admitted = false
+		h.inFlight.Add(-1)
 	}
+}
+
+func (h *handler) distribute(r *request) (err error) {
+	h.log.Debugf("processing message by type - %d", r.typ)
+
+	h.ns, h.key, h.code = "", "", 0
+	h.admitted = r.err == nil && !r.rejected
+	defer h.settle()
+	defer func() {
+		command, ok := commandNames[r.typ]
+		if !ok {
+			command = "undefined"
+		}
+		latency := time.Since(r.start)
 
-	typ, err := strconv.Atoi(string(bb))
+		h.metrics.ObserveRequest(command, h.code, latency, h.code != statusCodeOK)
+		h.log.WithFields(logrus.Fields{
+			"command":    command,
+			"namespace":  h.ns,
+			"key":        h.key,
+			"latency_ms": float64(latency.Microseconds()) / 1000,
+			"code":       h.code,
+		}).Info("request")
+	}()
+
+	err = h.conn.SetWriteDeadline(deadline(h.server.config.WriteTimeout))
 	if err != nil {
-		return errors.Wrapf(err, "string unfolding - %s", bb)
+		return errors.Wrap(err, "set write deadline")
+	}
+	if r.err != nil {
+		return h.answer(r.err)
+	}
+	if r.rejected {
+		err = errors.Wrap(ErrTooManyRequests, "in-flight requests limit")
+		return errors.Wrap(h.response(err), "send response message")
+	}
+	h.request = bytes.NewReader(r.frame)
+
+	// the rejected request is read, so the connection stays usable,
+	// the hello request switching the protocol is not limited
+	if r.typ != protomarshal.HelloMessageType {
+		err = h.server.limits.acquire(h.addr, pingMessageSize+len(r.frame))
+		if err != nil {
+			return errors.Wrap(h.response(err), "send response message")
+		}
 	}
 
-	logrus.Debugf("processing message by type - %d", typ)
+	err = h.authenticated(r.typ)
+	if err != nil {
+		return errors.Wrap(h.response(err), "send response message")
+	}
 
 	route := routing.New(map[interface{}]routing.HandlerFunc{
-		setMessageType:    h.set,
-		getMessageType:    h.get,
-		commitMessageType: h.commit,
+		protomarshal.HelloMessageType:     h.hello,
+		protomarshal.SetMessageType:       h.set,
+		protomarshal.GetMessageType:       h.get,
+		protomarshal.CommitMessageType:    h.commit,
+		protomarshal.ListMessageType:      h.list,
+		protomarshal.DeleteMessageType:    h.delete,
+		protomarshal.PurgeMessageType:     h.purge,
+		protomarshal.StatMessageType:      h.stat,
+		protomarshal.AuthMessageType:      h.auth,
+		protomarshal.UseMessageType:       h.use,
+		protomarshal.PartitionMessageType: h.partition,
+		protomarshal.SetBatchMessageType:  h.setBatch,
+		protomarshal.BeginMessageType:     h.begin,
+		protomarshal.EndMessageType:       h.end,
+		protomarshal.DedupMessageType:     h.dedup,
 	})
 
-	return route.Distribute(typ)
+	return h.answer(route.Distribute(r.typ))
+}
+
+// answer answers the failed request if it has not been answered yet
+// (e.g. the client stalled mid-frame), the error is returned to close the connection.
+func (h *handler) answer(err error) error {
+	if err == nil || h.code != 0 || isSysError(err) || isClosedError(err) {
+		return err
+	}
+
+	return multierr.Append(err, errors.Wrap(h.response(err), "send error response"))
+}
+
+// rejectTimeout the rejected connection waits for its first request so long,
+// the rejection is answered by the protocol of the request
+const rejectTimeout = time.Second
+
+// reject answers the connection rejected before serving and closes it.
+func (h *handler) reject(err error) {
+	defer tryClose(h.log, h.conn, "reject")
+
+	// the framed clients send the hello request first
+	bb := make([]byte, pingMessageSize+helloMessageSize)
+	werr := h.conn.SetReadDeadline(time.Now().Add(rejectTimeout))
+	if werr == nil {
+		_, werr = io.ReadFull(h.conn, bb)
+	}
+	typ, perr := protomarshal.ParseType(bb[:pingMessageSize])
+	if werr == nil && perr == nil && typ == protomarshal.HelloMessageType && int(bb[pingMessageSize]) == protomarshal.VersionFramed {
+		h.version = protomarshal.VersionFramed
+	}
+
+	werr = h.conn.SetWriteDeadline(deadline(h.server.config.WriteTimeout))
+	if werr == nil {
+		werr = h.response(err)
+	}
+	if werr != nil {
+		h.log.Error(errors.Wrap(werr, "send reject response"))
+	}
+}
+
+func deadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
 }
 
 const (
-	statusCodeOK  = 20
-	StatusCodeBad = 50
+	statusCodeOK           = 20
+	StatusCodeTimeout      = 40
+	StatusCodeUnauthorized = 41
+	StatusCodeTooMany      = 42
+	StatusCodeForbidden    = 43
+	StatusCodeQuota        = 44
+	StatusCodeBad          = 50
 )
 
-func tryClose(conn net.Conn, space string) {
+func tryClose(log *logrus.Entry, conn net.Conn, space string) {
 	if v := recover(); v != any(nil) {
-		logrus.Errorf("space %s rec error: %+v", space, v)
+		log.Errorf("space %s rec error: %+v", space, v)
 	}
 
-	if err := conn.Close(); err != nil {
-		logrus.Error(err)
+	if err := conn.Close(); err != nil && !isClosedError(err) {
+		log.Error(err)
 	}
 }
 
@@ -130,15 +499,44 @@ func isSysError(err error) bool {
 	return errors.Is(err, io.EOF) || errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)
 }
 
-func wrapMessageResponse(conn net.Conn, err error) error {
+// isClosedError the connection has been closed by the server
+func isClosedError(err error) bool {
+	return errors.Is(err, net.ErrClosed)
+}
+
+func isTimeoutError(err error) bool {
+	return errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+// statusCode code of the response to the request ended with the error
+func statusCode(err error) int {
+	switch {
+	case err == nil:
+		return statusCodeOK
+	case isTimeoutError(err):
+		return StatusCodeTimeout
+	case errors.Is(err, ErrTooManyConnections), errors.Is(err, ErrTooManyRequests):
+		return StatusCodeTooMany
+	case errors.Is(err, auth.ErrUnauthenticated):
+		return StatusCodeUnauthorized
+	case errors.Is(err, auth.ErrForbidden):
+		return StatusCodeForbidden
+	case errors.Is(err, jell.ErrQuotaExceeded), errors.Is(err, jell.ErrMemoryBudget):
+		return StatusCodeQuota
+	default:
+		return StatusCodeBad
+	}
+}
+
+func (h *handler) response(err error) error {
 	message := ""
-	code := statusCodeOK
 	if err != nil {
 		message = err.Error()
-		code = StatusCodeBad
 	}
 
-	return protomarshal.NewDecoder(conn).Decode(&messages.Response{
+	code := statusCode(err)
+	h.code = int32(code)
+	return protomarshal.NewDecoder(h).Decode(&messages.Response{
 		Error: message,
 		Code:  int32(code),
 	})
@@ -0,0 +1,140 @@
+package tcp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/baibikov/jellydb/internal/pkg/jellystore"
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+func TestServer_AccessLog(t *testing.T) {
+	hook := test.NewGlobal()
+	defer logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks))
+
+	store, err := jellystore.New(&jellystore.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	server := runTestServer(t, store)
+	defer server.Close()
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "1", &messages.SetRequest{
+		Key:       "key",
+		Message:   []byte("message"),
+		Namespace: "orders",
+	}))
+	require.EqualValues(t, StatusCodeBad, testRequest(t, conn, "2", &messages.GetRequest{Key: "absent", N: 1}))
+
+	// the request is logged after its response is sent
+	requests := func() []logrus.Entry {
+		entries := make([]logrus.Entry, 0)
+		for _, e := range hook.AllEntries() {
+			if e.Message == "request" {
+				entries = append(entries, *e)
+			}
+		}
+		return entries
+	}
+	require.Eventually(t, func() bool {
+		return len(requests()) == 3
+	}, time.Second, 10*time.Millisecond)
+
+	entries := requests()
+	for i, want := range []logrus.Fields{
+		{"command": "hello", "namespace": "", "key": "", "code": statusCodeOK},
+		{"command": "set", "namespace": "orders", "key": "key", "code": statusCodeOK},
+		{"command": "get", "namespace": "", "key": "absent", "code": StatusCodeBad},
+	} {
+		e := entries[i]
+		require.Equal(t, logrus.InfoLevel, e.Level)
+		for field, value := range want {
+			require.EqualValues(t, value, e.Data[field], field)
+		}
+		require.IsType(t, float64(0), e.Data["latency_ms"])
+		require.GreaterOrEqual(t, e.Data["latency_ms"].(float64), float64(0))
+		require.Contains(t, e.Data, "remote_addr")
+	}
+}
+
+func TestServer_LegacyProtocol(t *testing.T) {
+	store, err := jellystore.New(&jellystore.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	server := runTestServer(t, store)
+	defer server.Close()
+
+	conn, err := net.Dial(tcpNetwork, server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the connection without the hello reads and writes the bare messages
+	request := func(typ int, req proto.Message, resp proto.Message) {
+		t.Helper()
+
+		bb, err := proto.Marshal(req)
+		require.NoError(t, err)
+		_, err = conn.Write(protomarshal.FormatType(typ))
+		require.NoError(t, err)
+		_, err = conn.Write(bb)
+		require.NoError(t, err)
+
+		buf := make([]byte, 1024)
+		n, err := conn.Read(buf)
+		require.NoError(t, err)
+		require.NoError(t, proto.Unmarshal(buf[:n], resp))
+	}
+
+	resp := &messages.Response{}
+	request(protomarshal.SetMessageType, &messages.SetRequest{Key: "key", Message: []byte("message")}, resp)
+	require.EqualValues(t, statusCodeOK, resp.GetCode())
+
+	bb, err := store.Get("key", 1)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("message")}, bb)
+
+	// the hello is accepted only as the first request, the error is bare
+	_, err = conn.Write(append(protomarshal.FormatType(protomarshal.HelloMessageType), protomarshal.VersionFramed))
+	require.NoError(t, err)
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	require.NoError(t, proto.Unmarshal(buf[:n], resp))
+	require.EqualValues(t, StatusCodeBad, resp.GetCode())
+	require.Contains(t, resp.GetError(), "hello must be the first request")
+}
+
+func TestServer_Hello(t *testing.T) {
+	server := runTestServer(t, &slowJelly{})
+	defer server.Close()
+
+	conn, err := net.Dial(tcpNetwork, server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the unsupported version is rejected, the connection is closed
+	_, err = conn.Write(append(protomarshal.FormatType(protomarshal.HelloMessageType), 9))
+	require.NoError(t, err)
+	resp := &messages.Response{}
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	require.NoError(t, proto.Unmarshal(buf[:n], resp))
+	require.EqualValues(t, StatusCodeBad, resp.GetCode())
+	require.Contains(t, resp.GetError(), "unsupported protocol version 9")
+
+	framed, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer framed.Close()
+	require.EqualValues(t, statusCodeOK, testSet(t, framed))
+}
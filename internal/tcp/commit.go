@@ -3,17 +3,40 @@ package tcp
 import (
 	"github.com/pkg/errors"
 
+	"github.com/baibikov/jellydb/internal/auth"
 	"github.com/baibikov/jellydb/pkg/protomarshal"
 	"github.com/baibikov/jellydb/protogenerated/messages"
 )
 
 func (h *handler) commit() (err error) {
 	req := &messages.CommitRequest{}
-	err = protomarshal.NewEncoder(h.conn, commitMessageSize).Encode(req)
+	err = protomarshal.NewEncoder(h.request, commitMessageSize).Encode(req)
 	if err != nil {
 		return errors.Wrap(err, "get state")
 	}
+	h.key = req.GetKey()
 
-	err = wrapMessageResponse(h.conn, h.jelly.Commit(req.GetKey(), req.GetN()))
+	jelly, err := h.resolve(req.GetNamespace(), auth.PermissionCommit, req.GetKey())
+	if err != nil {
+		return errors.Wrap(h.response(err), "send response message")
+	}
+
+	tx, err := h.transaction()
+	if err != nil {
+		return errors.Wrap(h.response(err), "send response message")
+	}
+
+	switch {
+	case tx != nil && req.Partition != nil:
+		err = errors.New("partitions are not committed by transactions")
+	case tx != nil:
+		err = jelly.TxCommit(tx.id, req.GetKey(), req.GetN())
+	case req.Partition != nil:
+		err = jelly.CommitPartition(req.GetKey(), int(req.GetPartition()), req.GetN())
+	default:
+		err = jelly.Commit(req.GetKey(), req.GetN())
+	}
+
+	err = h.response(err)
 	return errors.Wrap(err, "send response message")
 }
@@ -0,0 +1,52 @@
+package tcp
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/internal/auth"
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+// dedup sets the dedup window of the key, no window only gets the dedup window of the key.
+func (h *handler) dedup() (err error) {
+	req := &messages.DedupRequest{}
+	err = protomarshal.NewEncoder(h.request, dedupMessageSize).Encode(req)
+	if err != nil {
+		return errors.Wrap(err, "get 'dedup' state")
+	}
+	h.key = req.GetKey()
+
+	perm := auth.PermissionRead
+	if req.WindowMs != nil {
+		perm = auth.PermissionWrite
+	}
+	jelly, err := h.resolve(req.GetNamespace(), perm, req.GetKey())
+	if err != nil {
+		return errors.Wrap(h.response(err), "send response message")
+	}
+
+	if req.WindowMs != nil {
+		err = jelly.Dedup(req.GetKey(), time.Duration(req.GetWindowMs())*time.Millisecond)
+		if err != nil {
+			return errors.Wrap(h.response(err), "send response message")
+		}
+	}
+
+	window, err := jelly.DedupWindow(req.GetKey())
+	if err != nil {
+		return errors.Wrap(h.response(err), "send response message")
+	}
+
+	err = h.response(nil)
+	if err != nil {
+		return errors.Wrap(err, "send response message")
+	}
+
+	err = protomarshal.NewDecoder(h).Decode(&messages.DedupResponse{
+		WindowMs: window.Milliseconds(),
+	})
+	return errors.Wrap(err, "write dedup response")
+}
@@ -0,0 +1,58 @@
+package tcp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/baibikov/jellydb/internal/pkg/jellystore"
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+func TestServer_Dedup(t *testing.T) {
+	store, err := jellystore.New(&jellystore.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	server := runTestServerWithConfig(t, &Config{Addr: "127.0.0.1:0"}, store)
+	defer server.Close()
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the dedup request type is sent as the base 36 digit
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "e", &messages.DedupRequest{
+		Key:      "key",
+		WindowMs: proto.Int64(time.Minute.Milliseconds()),
+	}))
+	resp := &messages.DedupResponse{}
+	require.NoError(t, protomarshal.NewEncoder(conn, 1024).Encode(resp))
+	require.Equal(t, time.Minute.Milliseconds(), resp.GetWindowMs())
+
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "e", &messages.DedupRequest{Key: "key"}))
+	require.NoError(t, protomarshal.NewEncoder(conn, 1024).Encode(resp))
+	require.Equal(t, time.Minute.Milliseconds(), resp.GetWindowMs())
+
+	// the dropped duplicates are answered as set
+	for i := 0; i < 2; i++ {
+		require.EqualValues(t, statusCodeOK, testRequest(t, conn, "1", &messages.SetRequest{Key: "key", Message: []byte("message-1")}))
+		require.EqualValues(t, statusCodeOK, testRequest(t, conn, "1", &messages.SetRequest{
+			Key:     "key",
+			Message: []byte("message-2"),
+			DedupId: "id",
+		}))
+	}
+	require.EqualValues(t, StatusCodeBad, testRequest(t, conn, "1", &messages.SetRequest{
+		Key:      "key",
+		Message:  []byte("message-3"),
+		DedupId:  "other-id",
+		Producer: "producer",
+	}))
+
+	bb, err := store.Get("key", 10)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("message-1"), []byte("message-2")}, bb)
+}
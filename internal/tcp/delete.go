@@ -0,0 +1,26 @@
+package tcp
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/internal/auth"
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+func (h *handler) delete() (err error) {
+	req := &messages.DeleteRequest{}
+	err = protomarshal.NewEncoder(h.request, deleteMessageSize).Encode(req)
+	if err != nil {
+		return errors.Wrap(err, "get 'delete' state")
+	}
+	h.key = req.GetKey()
+
+	jelly, err := h.resolve(req.GetNamespace(), auth.PermissionWrite, req.GetKey())
+	if err != nil {
+		return errors.Wrap(h.response(err), "send response message")
+	}
+
+	err = h.response(jelly.Delete(req.GetKey()))
+	return errors.Wrap(err, "send response message")
+}
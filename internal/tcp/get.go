@@ -3,25 +3,71 @@ package tcp
 import (
 	"github.com/pkg/errors"
 
+	"github.com/baibikov/jellydb/internal/auth"
+	"github.com/baibikov/jellydb/internal/pkg/jell"
 	"github.com/baibikov/jellydb/pkg/protomarshal"
 	"github.com/baibikov/jellydb/protogenerated/messages"
 )
 
+// maxGetN max messages of a getting, the messages of the largest size (512 bytes
+// with 4 bytes of the proto field) fit the response read by the clients, the rest
+// of the messages is got by the next getting.
+const maxGetN = (protomarshal.MaxResponseSize - 16) / (512 + 4)
+
 func (h *handler) get() (err error) {
 	req := &messages.GetRequest{}
-	err = protomarshal.NewEncoder(h.conn, getMessageSize).Encode(req)
+	err = protomarshal.NewEncoder(h.request, getMessageSize).Encode(req)
 	if err != nil {
 		return errors.Wrap(err, "get state")
 	}
+	h.key = req.GetKey()
+	if req.GetN() > maxGetN {
+		req.N = maxGetN
+	}
+
+	jelly, err := h.resolve(req.GetNamespace(), auth.PermissionRead, req.GetKey())
+	if err != nil {
+		return errors.Wrap(h.response(err), "send response message")
+	}
+
+	var (
+		bytes   [][]byte
+		scanned int64
+	)
+	filter := req.GetFilter()
+	switch {
+	case filter != nil && req.Partition != nil:
+		bytes, scanned, err = jelly.GetPartitionFiltered(req.GetKey(), int(req.GetPartition()), jellFilter(filter), req.GetN())
+	case filter != nil:
+		bytes, scanned, err = jelly.GetFiltered(req.GetKey(), jellFilter(filter), req.GetN())
+	case req.Partition != nil:
+		bytes, err = jelly.GetPartition(req.GetKey(), int(req.GetPartition()), req.GetN())
+		scanned = int64(len(bytes))
+	default:
+		bytes, err = jelly.Get(req.GetKey(), req.GetN())
+		scanned = int64(len(bytes))
+	}
+	if err != nil {
+		return errors.Wrap(h.response(err), "send response message")
+	}
 
-	bytes, err := h.jelly.Get(req.GetKey(), req.GetN())
-	err = wrapMessageResponse(h.conn, err)
+	err = h.response(nil)
 	if err != nil {
 		return errors.Wrap(err, "send response message")
 	}
 
-	err = protomarshal.NewDecoder(h.conn).Decode(&messages.GetResponse{
+	err = protomarshal.NewDecoder(h).Decode(&messages.GetResponse{
 		Messages: bytes,
+		Scanned:  scanned,
 	})
 	return errors.Wrap(err, "write bytes response")
 }
+
+func jellFilter(f *messages.Filter) jell.Filter {
+	return jell.Filter{
+		Prefix:   f.GetPrefix(),
+		Contains: f.GetContains(),
+		Field:    f.GetField(),
+		Value:    f.GetValue(),
+	}
+}
@@ -0,0 +1,78 @@
+package tcp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/baibikov/jellydb/internal/pkg/jellystore"
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+func TestServer_GetFiltered(t *testing.T) {
+	store, err := jellystore.New(&jellystore.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	server := runTestServerWithConfig(t, &Config{Addr: "127.0.0.1:0"}, store)
+	defer server.Close()
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	for _, value := range []string{`{"type": "payment"}`, `{"type": "order"}`, `{"type": "payment"}`} {
+		require.NoError(t, store.Set("events", []byte(value)))
+	}
+
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "2", &messages.GetRequest{
+		Key:    "events",
+		N:      1,
+		Filter: &messages.Filter{Field: "type", Value: "order"},
+	}))
+	resp := &messages.GetResponse{}
+	require.NoError(t, protomarshal.NewEncoder(conn, 1024).Encode(resp))
+	require.Equal(t, [][]byte{[]byte(`{"type": "order"}`)}, resp.GetMessages())
+	require.EqualValues(t, 2, resp.GetScanned())
+
+	// the value without the field is rejected
+	require.EqualValues(t, StatusCodeBad, testRequest(t, conn, "2", &messages.GetRequest{
+		Key:    "events",
+		N:      1,
+		Filter: &messages.Filter{Value: "order"},
+	}))
+
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "3", &messages.CommitRequest{Key: "events", N: resp.GetScanned()}))
+	bb, err := store.Get("events", 10)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte(`{"type": "payment"}`)}, bb)
+}
+
+func TestServer_GetMax(t *testing.T) {
+	store, err := jellystore.New(&jellystore.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	server := runTestServerWithConfig(t, &Config{Addr: "127.0.0.1:0"}, store)
+	defer server.Close()
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	message := bytes.Repeat([]byte("m"), 512)
+	for i := 0; i < maxGetN+10; i++ {
+		require.NoError(t, store.Set("key", message))
+	}
+
+	// the messages over the max fit the response of the next getting
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "2", &messages.GetRequest{Key: "key", N: maxGetN + 10}))
+	resp := &messages.GetResponse{}
+	require.NoError(t, protomarshal.NewEncoder(conn, protomarshal.MaxResponseSize).Encode(resp))
+	require.Len(t, resp.GetMessages(), maxGetN)
+
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "3", &messages.CommitRequest{Key: "key", N: maxGetN}))
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "2", &messages.GetRequest{Key: "key", N: maxGetN + 10}))
+	require.NoError(t, protomarshal.NewEncoder(conn, protomarshal.MaxResponseSize).Encode(resp))
+	require.Len(t, resp.GetMessages(), 10)
+}
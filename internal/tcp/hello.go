@@ -0,0 +1,35 @@
+package tcp
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+)
+
+// readHello reads the version of the hello request, the version of the connection
+// is switched before the request is answered. Only the first request of the
+// connection switches it.
+func (h *handler) readHello() error {
+	bb := make([]byte, helloMessageSize)
+	_, err := io.ReadFull(h.conn, bb)
+	if err != nil {
+		return errors.Wrap(err, "read hello version")
+	}
+	if h.started {
+		return errors.New("hello must be the first request of the connection")
+	}
+
+	version := int(bb[0])
+	if version != protomarshal.VersionLegacy && version != protomarshal.VersionFramed {
+		return errors.Errorf("unsupported protocol version %d", version)
+	}
+	h.version = version
+	return nil
+}
+
+// hello answers the hello request by the switched version.
+func (h *handler) hello() error {
+	return errors.Wrap(h.response(nil), "send response message")
+}
@@ -0,0 +1,131 @@
+package tcp
+
+import (
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+)
+
+var (
+	ErrTooManyConnections = errors.New("too many connections")
+	ErrTooManyRequests    = errors.New("too many requests")
+)
+
+// limits of the requests rates by the client, nil fields mean no limits.
+// The in-flight requests are limited by the connection handler.
+type limits struct {
+	requests *clientLimiters
+	bytes    *clientLimiters
+}
+
+func newLimits(config *Config) *limits {
+	l := &limits{}
+	if config.RequestsPerSecond > 0 {
+		l.requests = newClientLimiters(config.RequestsPerSecond, burst(config.RequestsPerSecond, 1))
+	}
+	if config.BytesPerSecond > 0 {
+		// the largest request must fit into the bucket, otherwise it is never allowed
+		l.bytes = newClientLimiters(config.BytesPerSecond, burst(config.BytesPerSecond, maxMessageSize()+pingMessageSize))
+	}
+
+	return l
+}
+
+// acquire takes the place of the request of the client with n bytes.
+func (l *limits) acquire(addr string, n int) error {
+	if !l.requests.allow(addr, 1) {
+		return errors.Wrap(ErrTooManyRequests, "requests rate limit")
+	}
+	if !l.bytes.allow(addr, n) {
+		return errors.Wrap(ErrTooManyRequests, "bytes rate limit")
+	}
+
+	return nil
+}
+
+func burst(r float64, least int) int {
+	b := int(math.Ceil(r))
+	if b < least {
+		return least
+	}
+	return b
+}
+
+// clientIdleTTL limiters of the clients not seen for this time are dropped,
+// their buckets are full by then anyway.
+const clientIdleTTL = time.Minute
+
+// clientLimiters token buckets by the client address.
+type clientLimiters struct {
+	limit rate.Limit
+	burst int
+
+	mutex     sync.Mutex
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+type clientLimiter struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+func newClientLimiters(r float64, b int) *clientLimiters {
+	return &clientLimiters{
+		limit:     rate.Limit(r),
+		burst:     b,
+		clients:   make(map[string]*clientLimiter),
+		lastSweep: time.Now(),
+	}
+}
+
+func (c *clientLimiters) allow(addr string, n int) bool {
+	if c == nil {
+		return true
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	c.sweep(now)
+
+	cl, ok := c.clients[addr]
+	if !ok {
+		cl = &clientLimiter{limiter: rate.NewLimiter(c.limit, c.burst)}
+		c.clients[addr] = cl
+	}
+	cl.seen = now
+
+	return cl.limiter.AllowN(now, n)
+}
+
+func (c *clientLimiters) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < clientIdleTTL {
+		return
+	}
+	c.lastSweep = now
+
+	for addr, cl := range c.clients {
+		// the bucket is full again if the client is idle long enough
+		idle := now.Sub(cl.seen)
+		if idle > clientIdleTTL && idle.Seconds()*float64(c.limit) >= float64(c.burst) {
+			delete(c.clients, addr)
+		}
+	}
+}
+
+// clientAddr the address of the client without the port,
+// the limits are shared by all connections of the client.
+func clientAddr(conn net.Conn) string {
+	addr := conn.RemoteAddr().String()
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
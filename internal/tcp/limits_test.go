@@ -0,0 +1,150 @@
+package tcp
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+func testSet(t *testing.T, conn net.Conn) int32 {
+	t.Helper()
+
+	_, err := conn.Write([]byte("1"))
+	require.NoError(t, err)
+	err = protomarshal.NewDecoder(conn).Decode(&messages.SetRequest{Key: "key", Message: []byte("message")})
+	require.NoError(t, err)
+
+	resp := &messages.Response{}
+	err = protomarshal.NewEncoder(conn, 1024).Encode(resp)
+	require.NoError(t, err)
+	return resp.GetCode()
+}
+
+func TestServer_MaxConnections(t *testing.T) {
+	server := runTestServerWithConfig(t, &Config{
+		Addr:           "127.0.0.1:0",
+		MaxConnections: 1,
+	}, &slowJelly{})
+	defer server.Close()
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.EqualValues(t, statusCodeOK, testSet(t, conn))
+
+	excess, err := net.Dial(tcpNetwork, server.listener.Addr().String())
+	require.NoError(t, err)
+	defer excess.Close()
+
+	// the rejection is answered by the protocol of the first request
+	code, err := testHello(excess, protomarshal.VersionFramed)
+	require.NoError(t, err)
+	require.EqualValues(t, StatusCodeTooMany, code)
+}
+
+func TestServer_RequestsPerSecond(t *testing.T) {
+	server := runTestServerWithConfig(t, &Config{
+		Addr:              "127.0.0.1:0",
+		RequestsPerSecond: 1,
+	}, &slowJelly{})
+	defer server.Close()
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.EqualValues(t, statusCodeOK, testSet(t, conn))
+	// the connection stays usable after the rejection
+	require.EqualValues(t, StatusCodeTooMany, testSet(t, conn))
+	require.EqualValues(t, StatusCodeTooMany, testSet(t, conn))
+
+	// the limit is shared by the connections of the client
+	other, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer other.Close()
+	require.EqualValues(t, StatusCodeTooMany, testSet(t, other))
+}
+
+func TestServer_BytesPerSecond(t *testing.T) {
+	server := runTestServerWithConfig(t, &Config{
+		Addr:           "127.0.0.1:0",
+		BytesPerSecond: 1,
+	}, &slowJelly{})
+	defer server.Close()
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the burst fits the largest request, small requests exhaust it after a while
+	require.EqualValues(t, statusCodeOK, testSet(t, conn))
+	code := int32(statusCodeOK)
+	for i := 0; i < maxMessageSize() && code == statusCodeOK; i++ {
+		code = testSet(t, conn)
+	}
+	require.EqualValues(t, StatusCodeTooMany, code)
+}
+
+func TestServer_MaxInFlight(t *testing.T) {
+	server := runTestServerWithConfig(t, &Config{
+		Addr:        "127.0.0.1:0",
+		MaxInFlight: 2,
+	}, &slowJelly{delay: 200 * time.Millisecond})
+	defer server.Close()
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the requests are sent without waiting for the responses
+	for i := 0; i < 3; i++ {
+		_, err = conn.Write([]byte("1"))
+		require.NoError(t, err)
+		err = protomarshal.NewDecoder(conn).Decode(&messages.SetRequest{Key: "key", Message: []byte("message")})
+		require.NoError(t, err)
+	}
+
+	// the responses are in the order of the requests, the third one is over the limit
+	codes := make([]int32, 3)
+	for i := range codes {
+		resp := &messages.Response{}
+		require.NoError(t, protomarshal.NewEncoder(conn, 1024).Encode(resp))
+		codes[i] = resp.GetCode()
+	}
+	require.Equal(t, []int32{statusCodeOK, statusCodeOK, StatusCodeTooMany}, codes)
+
+	// the answered requests release their places
+	require.EqualValues(t, statusCodeOK, testSet(t, conn))
+}
+
+func TestServer_MaxInFlightPerConnection(t *testing.T) {
+	server := runTestServerWithConfig(t, &Config{
+		Addr:        "127.0.0.1:0",
+		MaxInFlight: 1,
+	}, &slowJelly{delay: 300 * time.Millisecond})
+	defer server.Close()
+
+	// the slow request of one connection doesn't block the others
+	codes := make([]int32, 2)
+	wg := sync.WaitGroup{}
+	for i := range codes {
+		conn, err := dialFramed(server.listener.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+
+		wg.Add(1)
+		go func(i int, conn net.Conn) {
+			defer wg.Done()
+			codes[i] = testSet(t, conn)
+		}(i, conn)
+	}
+	wg.Wait()
+
+	require.Equal(t, []int32{statusCodeOK, statusCodeOK}, codes)
+}
@@ -0,0 +1,41 @@
+package tcp
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/internal/pkg/jell"
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+func (h *handler) list() (err error) {
+	req := &messages.ListRequest{}
+	err = protomarshal.NewEncoder(h.request, listMessageSize).Encode(req)
+	if err != nil {
+		return errors.Wrap(err, "get 'list' state")
+	}
+	h.key = req.GetPrefix()
+
+	// the absent namespace has no keys, it is not created by listing
+	h.scope(req.GetNamespace())
+	keys := make([]string, 0)
+	jelly, err := h.jelly.LookupNamespace(h.ns)
+	if err == nil {
+		keys, err = jelly.List(req.GetPrefix())
+	}
+	if err != nil && !errors.Is(err, jell.ErrNamespaceNotFound) {
+		return errors.Wrap(h.response(err), "send response message")
+	}
+
+	keys = h.readable(keys)
+
+	err = h.response(nil)
+	if err != nil {
+		return errors.Wrap(err, "send response message")
+	}
+
+	err = protomarshal.NewDecoder(h).Decode(&messages.ListResponse{
+		Keys: keys,
+	})
+	return errors.Wrap(err, "write keys response")
+}
@@ -0,0 +1,40 @@
+package tcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/baibikov/jellydb/internal/pkg/jellystore"
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+// TestServer_ErrorResponse the failed request is answered by the response only,
+// so the next request of the connection reads its own response.
+func TestServer_ErrorResponse(t *testing.T) {
+	store, err := jellystore.New(&jellystore.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+	require.NoError(t, store.Set("key", []byte("message")))
+
+	server := runTestServerWithConfig(t, &Config{Addr: "127.0.0.1:0"}, store)
+	defer server.Close()
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.EqualValues(t, StatusCodeBad, testRequest(t, conn, "2", &messages.GetRequest{Key: "absent", N: 1}))
+	require.EqualValues(t, StatusCodeBad, testRequest(t, conn, "4", &messages.ListRequest{Namespace: ".."}))
+
+	// the empty prefix lists all keys
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "4", &messages.ListRequest{}))
+	keys := &messages.ListResponse{}
+	require.NoError(t, protomarshal.NewEncoder(conn, 1024).Encode(keys))
+	require.Equal(t, []string{"key"}, keys.GetKeys())
+
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "2", &messages.GetRequest{Key: "key", N: 1}))
+	bb := &messages.GetResponse{}
+	require.NoError(t, protomarshal.NewEncoder(conn, 1024).Encode(bb))
+	require.Equal(t, [][]byte{[]byte("message")}, bb.GetMessages())
+}
@@ -0,0 +1,51 @@
+package tcp
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/baibikov/jellydb/internal/metrics"
+	"github.com/baibikov/jellydb/internal/pkg/jellystore"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+func TestServer_Metrics(t *testing.T) {
+	store, err := jellystore.New(&jellystore.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	m := metrics.New(metrics.Config{})
+	server := runTestServerWithConfig(t, &Config{Addr: "127.0.0.1:0", Metrics: m}, m.Instrument(store))
+	defer server.Close()
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "1", &messages.SetRequest{Key: "key", Message: []byte("message")}))
+	require.EqualValues(t, StatusCodeBad, testRequest(t, conn, "2", &messages.GetRequest{Key: "absent", N: 1}))
+
+	scrape := func() string {
+		rec := httptest.NewRecorder()
+		m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		body, err := io.ReadAll(rec.Result().Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	// the request is observed after its response is sent
+	require.Eventually(t, func() bool {
+		return strings.Contains(scrape(), `jellydb_requests_total{code="50",command="get"} 1`)
+	}, time.Second, 10*time.Millisecond)
+
+	body := scrape()
+	require.Contains(t, body, `jellydb_requests_total{code="20",command="set"} 1`)
+	require.Contains(t, body, `jellydb_errors_total{code="50"} 1`)
+	require.NotContains(t, body, `jellydb_errors_total{code="20"}`)
+	require.Contains(t, body, "jellydb_active_connections 1")
+	require.Contains(t, body, `jellydb_namespace_depth{namespace=""} 1`)
+}
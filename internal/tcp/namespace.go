@@ -0,0 +1,55 @@
+package tcp
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/internal/auth"
+	"github.com/baibikov/jellydb/internal/pkg/jell"
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+// use selects the namespace of the connection for the next requests,
+// the namespace is not created until the first setting to it.
+func (h *handler) use() (err error) {
+	req := &messages.UseRequest{}
+	err = protomarshal.NewEncoder(h.request, useMessageSize).Encode(req)
+	if err != nil {
+		return errors.Wrap(err, "get 'use' state")
+	}
+	h.ns = req.GetNamespace()
+
+	_, err = h.jelly.LookupNamespace(req.GetNamespace())
+	if err != nil && !errors.Is(err, jell.ErrNamespaceNotFound) {
+		return errors.Wrap(h.response(err), "send response message")
+	}
+	h.namespace = req.GetNamespace()
+
+	err = h.response(nil)
+	return errors.Wrap(err, "send response message")
+}
+
+// scope sets the namespace of the request, an empty namespace is the namespace of the connection.
+func (h *handler) scope(namespace string) {
+	if namespace == "" {
+		namespace = h.namespace
+	}
+	h.ns = namespace
+}
+
+// resolve resolves the store of the request namespace once the permission
+// to the key is checked. Only writing creates the absent namespace,
+// the other requests fail on it.
+func (h *handler) resolve(namespace string, perm auth.Permission, key string) (jell.Jelly, error) {
+	h.scope(namespace)
+
+	err := h.authorize(perm, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if perm == auth.PermissionWrite {
+		return h.jelly.Namespace(h.ns)
+	}
+	return h.jelly.LookupNamespace(h.ns)
+}
@@ -0,0 +1,131 @@
+package tcp
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/baibikov/jellydb/internal/auth"
+	"github.com/baibikov/jellydb/internal/pkg/jellystore"
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+func testRequest(t *testing.T, conn net.Conn, typ string, req proto.Message) int32 {
+	t.Helper()
+
+	_, err := conn.Write([]byte(typ))
+	require.NoError(t, err)
+	err = protomarshal.NewDecoder(conn).Decode(req)
+	require.NoError(t, err)
+
+	resp := &messages.Response{}
+	err = protomarshal.NewEncoder(conn, 1024).Encode(resp)
+	require.NoError(t, err)
+	return resp.GetCode()
+}
+
+func TestServer_Namespace(t *testing.T) {
+	store, err := jellystore.New(&jellystore.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	authenticator, err := auth.New(&auth.Config{Users: []auth.User{{
+		Name:  "team1",
+		Token: "team1-token",
+		Rules: []auth.Rule{{Namespace: "team1", Permissions: []auth.Permission{auth.PermissionWrite}}},
+	}}})
+	require.NoError(t, err)
+
+	server := runTestServerWithConfig(t, &Config{
+		Addr: "127.0.0.1:0",
+		Auth: authenticator,
+	}, store)
+	defer server.Close()
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.EqualValues(t, statusCodeOK, testAuth(t, conn, &messages.AuthRequest{Token: "team1-token"}))
+
+	// the default namespace is not granted
+	require.EqualValues(t, StatusCodeForbidden, testSet(t, conn))
+	// the namespace of the request
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "1", &messages.SetRequest{
+		Key:       "key",
+		Message:   []byte("request"),
+		Namespace: "team1",
+	}))
+	// the namespace of the connection
+	require.EqualValues(t, StatusCodeBad, testRequest(t, conn, "9", &messages.UseRequest{Namespace: "../team1"}))
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "9", &messages.UseRequest{Namespace: "team1"}))
+	require.EqualValues(t, statusCodeOK, testSet(t, conn))
+
+	team1, err := store.Namespace("team1")
+	require.NoError(t, err)
+	bb, err := team1.Get("key", 2)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("request"), []byte("message")}, bb)
+
+	_, err = store.Get("key", 1)
+	require.Error(t, err)
+}
+
+func TestServer_NamespaceNotCreated(t *testing.T) {
+	store, err := jellystore.New(&jellystore.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	authenticator, err := auth.New(&auth.Config{Users: []auth.User{{
+		Name:  "writer",
+		Token: "writer-token",
+		Rules: []auth.Rule{{Namespace: "team1", Permissions: []auth.Permission{auth.PermissionWrite}}},
+	}, {
+		Name:  "reader",
+		Token: "reader-token",
+		Rules: []auth.Rule{{Namespace: auth.AnyNamespace, Permissions: []auth.Permission{auth.PermissionRead, auth.PermissionCommit}}},
+	}}})
+	require.NoError(t, err)
+
+	server := runTestServerWithConfig(t, &Config{
+		Addr: "127.0.0.1:0",
+		Auth: authenticator,
+	}, store)
+	defer server.Close()
+
+	writer, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer writer.Close()
+	reader, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer reader.Close()
+
+	require.EqualValues(t, statusCodeOK, testAuth(t, writer, &messages.AuthRequest{Token: "writer-token"}))
+	require.EqualValues(t, statusCodeOK, testAuth(t, reader, &messages.AuthRequest{Token: "reader-token"}))
+
+	// the denied requests don't create the namespace
+	require.EqualValues(t, StatusCodeForbidden, testRequest(t, writer, "1", &messages.SetRequest{Key: "key", Message: []byte("message"), Namespace: "junk"}))
+	require.EqualValues(t, StatusCodeForbidden, testRequest(t, writer, "b", &messages.SetBatchRequest{Namespace: "junk"}))
+	require.EqualValues(t, StatusCodeForbidden, testRequest(t, writer, "c", &messages.BeginRequest{Namespace: "junk"}))
+
+	// neither do the reading ones
+	require.EqualValues(t, StatusCodeBad, testRequest(t, reader, "2", &messages.GetRequest{Key: "key", N: 1, Namespace: "other"}))
+	require.EqualValues(t, StatusCodeBad, testRequest(t, reader, "7", &messages.StatRequest{Key: "key", Namespace: "other"}))
+	require.EqualValues(t, StatusCodeBad, testRequest(t, reader, "3", &messages.CommitRequest{Key: "key", N: 1, Namespace: "other"}))
+	require.EqualValues(t, statusCodeOK, testRequest(t, reader, "9", &messages.UseRequest{Namespace: "other"}))
+	require.EqualValues(t, statusCodeOK, testRequest(t, reader, "4", &messages.ListRequest{}))
+	resp := &messages.ListResponse{}
+	require.NoError(t, protomarshal.NewEncoder(reader, 1024).Encode(resp))
+	require.Empty(t, resp.GetKeys())
+
+	names, err := store.Namespaces()
+	require.NoError(t, err)
+	require.Empty(t, names)
+
+	// the allowed setting creates it
+	require.EqualValues(t, statusCodeOK, testRequest(t, writer, "1", &messages.SetRequest{Key: "key", Message: []byte("message"), Namespace: "team1"}))
+	names, err = store.Namespaces()
+	require.NoError(t, err)
+	require.Equal(t, []string{"team1"}, names)
+}
@@ -0,0 +1,50 @@
+package tcp
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/internal/auth"
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+// partition splits the key into the partitions, zero partitions only gets the partitions of the key.
+func (h *handler) partition() (err error) {
+	req := &messages.PartitionRequest{}
+	err = protomarshal.NewEncoder(h.request, partitionMessageSize).Encode(req)
+	if err != nil {
+		return errors.Wrap(err, "get 'partition' state")
+	}
+	h.key = req.GetKey()
+
+	perm := auth.PermissionRead
+	if req.GetPartitions() > 0 {
+		perm = auth.PermissionWrite
+	}
+	jelly, err := h.resolve(req.GetNamespace(), perm, req.GetKey())
+	if err != nil {
+		return errors.Wrap(h.response(err), "send response message")
+	}
+
+	if req.GetPartitions() > 0 {
+		err = jelly.Partition(req.GetKey(), int(req.GetPartitions()))
+		if err != nil {
+			return errors.Wrap(h.response(err), "send response message")
+		}
+	}
+
+	n, err := jelly.Partitions(req.GetKey())
+	if err != nil {
+		return errors.Wrap(h.response(err), "send response message")
+	}
+
+	err = h.response(nil)
+	if err != nil {
+		return errors.Wrap(err, "send response message")
+	}
+
+	err = protomarshal.NewDecoder(h).Decode(&messages.PartitionResponse{
+		Partitions: int32(n),
+	})
+	return errors.Wrap(err, "write partition response")
+}
@@ -0,0 +1,62 @@
+package tcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/baibikov/jellydb/internal/pkg/jellystore"
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+func TestServer_Partition(t *testing.T) {
+	store, err := jellystore.New(&jellystore.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	server := runTestServerWithConfig(t, &Config{Addr: "127.0.0.1:0"}, store)
+	defer server.Close()
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the partition request type is sent as the base 36 digit
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "a", &messages.PartitionRequest{Key: "orders", Partitions: 2}))
+	resp := &messages.PartitionResponse{}
+	require.NoError(t, protomarshal.NewEncoder(conn, 1024).Encode(resp))
+	require.EqualValues(t, 2, resp.GetPartitions())
+
+	// the partitioned key is set by the partition key only
+	require.EqualValues(t, StatusCodeBad, testRequest(t, conn, "1", &messages.SetRequest{Key: "orders", Message: []byte("message")}))
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "1", &messages.SetRequest{
+		Key:          "orders",
+		Message:      []byte("message"),
+		PartitionKey: "customer",
+	}))
+
+	partition, err := store.SetPartitioned("orders", "customer", []byte("next"))
+	require.NoError(t, err)
+
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "2", &messages.GetRequest{
+		Key:       "orders",
+		N:         2,
+		Partition: proto.Int32(int32(partition)),
+	}))
+	bb := &messages.GetResponse{}
+	require.NoError(t, protomarshal.NewEncoder(conn, 1024).Encode(bb))
+	require.Equal(t, [][]byte{[]byte("message"), []byte("next")}, bb.GetMessages())
+
+	require.EqualValues(t, StatusCodeBad, testRequest(t, conn, "3", &messages.CommitRequest{Key: "orders", N: 1}))
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "3", &messages.CommitRequest{
+		Key:       "orders",
+		N:         1,
+		Partition: proto.Int32(int32(partition)),
+	}))
+
+	stats, err := store.Stats("orders")
+	require.NoError(t, err)
+	require.Equal(t, 2, stats.Partitions)
+	require.EqualValues(t, 1, stats.Uncommitted)
+}
@@ -1,21 +1,47 @@
 package tcp
 
 import (
+	"crypto/tls"
 	"net"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 
+	"github.com/baibikov/jellydb/internal/auth"
+	"github.com/baibikov/jellydb/internal/metrics"
 	"github.com/baibikov/jellydb/internal/pkg/jell"
 )
 
 type Server struct {
+	config   *Config
 	listener net.Listener
 	jelly    jell.Jelly
-	closed   bool
+	metrics  *metrics.Metrics
+	limits   *limits
+	closed   atomic.Bool
+
+	// connID sequence of the accepted connections identifiers
+	connID atomic.Uint64
+
+	mutex sync.Mutex
+	// handlers of the served connections with the idle state
+	handlers map[*handler]bool
 }
 
+// Close closes the listener and all connections immediately,
+// in-flight requests are cut. Use Shutdown for the graceful shutdown.
 func (s *Server) Close() error {
-	s.closed = true
+	err := s.closeListener()
+	s.closeHandlers()
+	return err
+}
+
+func (s *Server) closeListener() error {
+	if s.closed.Swap(true) {
+		return nil
+	}
 	return s.listener.Close()
 }
 
@@ -25,6 +51,41 @@ const (
 
 type Config struct {
 	Addr string
+	// TLS config of the listener, nil serves the plain tcp.
+	// ClientAuth of the config enables the verification of the clients (mTLS).
+	TLS *tls.Config
+	// Auth authenticator of the clients and the permissions to the keys,
+	// nil serves all clients without authentication.
+	Auth *auth.Authenticator
+	// Metrics collectors of the requests and connections, nil switches them off
+	Metrics *metrics.Metrics
+
+	// ReadTimeout time to read a request after its type is received,
+	// a client stalled mid-request gets an error response and is disconnected.
+	// Zero means no timeout.
+	ReadTimeout time.Duration
+	// WriteTimeout time to write the response to a request. Zero means no timeout.
+	WriteTimeout time.Duration
+	// IdleTimeout time to wait for the next request,
+	// the idle connection is closed after it. Zero means no timeout.
+	IdleTimeout time.Duration
+
+	// MaxConnections connections served at the same time,
+	// the excess connection gets the too many response code and is closed.
+	// Zero means no limit.
+	MaxConnections int
+	// MaxInFlight requests of a connection read and not answered yet: the client
+	// may send the requests without waiting for the responses, they are answered
+	// in order. The limit is per connection, the connections don't wait for each other.
+	// The excess request gets the too many response code. Zero means no limit.
+	MaxInFlight int
+	// RequestsPerSecond rate of the requests by the client address (all its
+	// connections), the burst is one second of the rate. Zero means no limit.
+	RequestsPerSecond float64
+	// BytesPerSecond rate of the requests bytes by the client address,
+	// the burst is one second of the rate but not less than the largest request.
+	// Zero means no limit.
+	BytesPerSecond float64
 }
 
 func New(config *Config, jelly jell.Jelly) (*Server, error) {
@@ -40,9 +101,16 @@ func New(config *Config, jelly jell.Jelly) (*Server, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "listen connection")
 	}
+	if config.TLS != nil {
+		listener = tls.NewListener(listener, config.TLS)
+	}
 
 	return &Server{
+		config:   config,
 		listener: listener,
 		jelly:    jelly,
+		metrics:  config.Metrics,
+		limits:   newLimits(config),
+		handlers: make(map[*handler]bool),
 	}, nil
 }
@@ -3,17 +3,54 @@ package tcp
 import (
 	"github.com/pkg/errors"
 
+	"github.com/baibikov/jellydb/internal/auth"
 	"github.com/baibikov/jellydb/pkg/protomarshal"
 	"github.com/baibikov/jellydb/protogenerated/messages"
 )
 
 func (h *handler) set() (err error) {
 	req := &messages.SetRequest{}
-	err = protomarshal.NewEncoder(h.conn, setMessageSize).Encode(req)
+	err = protomarshal.NewEncoder(h.request, setMessageSize).Encode(req)
 	if err != nil {
 		return errors.Wrap(err, "get 'set' state")
 	}
+	h.key = req.GetKey()
 
-	err = wrapMessageResponse(h.conn, h.jelly.Set(req.GetKey(), req.GetMessage()))
+	jelly, err := h.resolve(req.GetNamespace(), auth.PermissionWrite, req.GetKey())
+	if err != nil {
+		return errors.Wrap(h.response(err), "send response message")
+	}
+
+	tx, err := h.transaction()
+	if err != nil {
+		return errors.Wrap(h.response(err), "send response message")
+	}
+
+	switch {
+	case tx != nil && (req.GetPartitionKey() != "" || req.GetProducer() != "" || req.GetDedupId() != "" || req.GetPriority() != 0):
+		err = errors.New("partitioned keys, idempotent producers, dedup ids and priorities are not set by transactions")
+	case req.GetPriority() != 0 && (req.GetPartitionKey() != "" || req.GetProducer() != "" || req.GetDedupId() != ""):
+		err = errors.New("the message of the priority is not set by partition keys, idempotent producers and dedup ids")
+	case req.GetPartitionKey() != "" && (req.GetProducer() != "" || req.GetDedupId() != ""):
+		err = errors.New("partitioned keys are not set by idempotent producers and dedup ids")
+	case req.GetProducer() != "" && req.GetDedupId() != "":
+		err = errors.New("the message is set either by the idempotent producer or by the dedup id")
+	case tx != nil:
+		err = jelly.TxSet(tx.id, req.GetKey(), req.GetMessage())
+	case req.GetPriority() != 0:
+		err = jelly.SetPriority(req.GetKey(), int(req.GetPriority()), req.GetMessage())
+	case req.GetPartitionKey() != "":
+		_, err = jelly.SetPartitioned(req.GetKey(), req.GetPartitionKey(), req.GetMessage())
+	case req.GetDedupId() != "":
+		// the dropped duplicate is answered as set
+		_, err = jelly.SetDedupID(req.GetKey(), req.GetDedupId(), req.GetMessage())
+	case req.GetProducer() != "":
+		// the duplicate of the retried message is answered as set
+		_, err = jelly.SetIdempotent(req.GetKey(), req.GetProducer(), req.GetSequence(), req.GetMessage())
+	default:
+		err = jelly.Set(req.GetKey(), req.GetMessage())
+	}
+
+	err = h.response(err)
 	return errors.Wrap(err, "send response message")
 }
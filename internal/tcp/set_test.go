@@ -0,0 +1,87 @@
+package tcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/baibikov/jellydb/internal/pkg/jellystore"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+func TestServer_SetIdempotent(t *testing.T) {
+	store, err := jellystore.New(&jellystore.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	server := runTestServerWithConfig(t, &Config{Addr: "127.0.0.1:0"}, store)
+	defer server.Close()
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the retried message is answered as set and set once
+	for i := 0; i < 2; i++ {
+		require.EqualValues(t, statusCodeOK, testRequest(t, conn, "1", &messages.SetRequest{
+			Key:      "key",
+			Message:  []byte("message-1"),
+			Producer: "producer",
+			Sequence: 1,
+		}))
+	}
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "1", &messages.SetRequest{
+		Key:      "key",
+		Message:  []byte("message-2"),
+		Producer: "producer",
+		Sequence: 2,
+	}))
+	require.EqualValues(t, StatusCodeBad, testRequest(t, conn, "1", &messages.SetRequest{
+		Key:          "key",
+		Message:      []byte("message-3"),
+		Producer:     "producer",
+		Sequence:     3,
+		PartitionKey: "partition",
+	}))
+
+	bb, err := store.Get("key", 10)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("message-1"), []byte("message-2")}, bb)
+}
+
+func TestServer_SetPriority(t *testing.T) {
+	store, err := jellystore.New(&jellystore.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	server := runTestServerWithConfig(t, &Config{Addr: "127.0.0.1:0"}, store)
+	defer server.Close()
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "1", &messages.SetRequest{
+		Key:     "key",
+		Message: []byte("low"),
+	}))
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "1", &messages.SetRequest{
+		Key:      "key",
+		Message:  []byte("high"),
+		Priority: 3,
+	}))
+	require.EqualValues(t, StatusCodeBad, testRequest(t, conn, "1", &messages.SetRequest{
+		Key:      "key",
+		Message:  []byte("out-of-range"),
+		Priority: 10,
+	}))
+	require.EqualValues(t, StatusCodeBad, testRequest(t, conn, "1", &messages.SetRequest{
+		Key:      "key",
+		Message:  []byte("produced"),
+		Priority: 3,
+		Producer: "producer",
+		Sequence: 1,
+	}))
+
+	bb, err := store.Get("key", 10)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("high"), []byte("low")}, bb)
+}
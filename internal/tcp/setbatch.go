@@ -0,0 +1,63 @@
+package tcp
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/internal/auth"
+	"github.com/baibikov/jellydb/internal/pkg/jell"
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+// setBatch sets the entries of the batch acknowledged by one response,
+// the batch having a key without the write permission sets nothing.
+func (h *handler) setBatch() (err error) {
+	req := &messages.SetBatchRequest{}
+	err = protomarshal.NewEncoder(h.request, setBatchMessageSize).Encode(req)
+	if err != nil {
+		return errors.Wrap(err, "get 'set batch' state")
+	}
+	if len(req.GetEntries()) > 0 {
+		h.key = req.GetEntries()[0].GetKey()
+	}
+
+	h.scope(req.GetNamespace())
+
+	tx, err := h.transaction()
+	if err == nil && tx != nil {
+		err = errors.New("batches are not set by transactions")
+	}
+	if err != nil {
+		return errors.Wrap(h.response(err), "send response message")
+	}
+
+	entries := make([]jell.Entry, 0, len(req.GetEntries()))
+	for _, e := range req.GetEntries() {
+		err = h.authorize(auth.PermissionWrite, e.GetKey())
+		if err != nil {
+			return errors.Wrap(h.response(err), "send response message")
+		}
+
+		entries = append(entries, jell.Entry{
+			Key:          e.GetKey(),
+			PartitionKey: e.GetPartitionKey(),
+			Value:        e.GetMessage(),
+		})
+	}
+	// the empty batch authorizes no key
+	if len(entries) == 0 {
+		err = h.authorizeNamespace(auth.PermissionWrite)
+		if err != nil {
+			return errors.Wrap(h.response(err), "send response message")
+		}
+	}
+
+	// the namespace is created once all keys are authorized
+	jelly, err := h.jelly.Namespace(h.ns)
+	if err != nil {
+		return errors.Wrap(h.response(err), "send response message")
+	}
+
+	err = h.response(jelly.SetBatch(entries))
+	return errors.Wrap(err, "send response message")
+}
@@ -0,0 +1,57 @@
+package tcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/baibikov/jellydb/internal/auth"
+	"github.com/baibikov/jellydb/internal/pkg/jellystore"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+func TestServer_SetBatch(t *testing.T) {
+	store, err := jellystore.New(&jellystore.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	authenticator, err := auth.New(&auth.Config{Users: []auth.User{{
+		Name:  "writer",
+		Token: "writer-token",
+		Rules: []auth.Rule{{Prefix: "ke", Permissions: []auth.Permission{auth.PermissionWrite}}},
+	}}})
+	require.NoError(t, err)
+
+	server := runTestServerWithConfig(t, &Config{Addr: "127.0.0.1:0", Auth: authenticator}, store)
+	defer server.Close()
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.EqualValues(t, statusCodeOK, testAuth(t, conn, &messages.AuthRequest{Token: "writer-token"}))
+
+	// the batch having a forbidden key sets nothing, the set batch type is the base 36 digit
+	require.EqualValues(t, StatusCodeForbidden, testRequest(t, conn, "b", &messages.SetBatchRequest{
+		Entries: []*messages.SetBatchEntry{
+			{Key: "key-1", Message: []byte("message-1")},
+			{Key: "other", Message: []byte("message-2")},
+		},
+	}))
+	keys, err := store.List("")
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "b", &messages.SetBatchRequest{
+		Entries: []*messages.SetBatchEntry{
+			{Key: "key-1", Message: []byte("message-1")},
+			{Key: "key-2", Message: []byte("message-2")},
+			{Key: "key-1", Message: []byte("message-3")},
+		},
+	}))
+
+	bb, err := store.Get("key-1", 10)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("message-1"), []byte("message-3")}, bb)
+	bb, err = store.Get("key-2", 10)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("message-2")}, bb)
+}
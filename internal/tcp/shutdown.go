@@ -0,0 +1,128 @@
+package tcp
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+const shutdownPollInterval = 50 * time.Millisecond
+
+// Shutdown gracefully shuts down the server: stops accepting
+// new connections, stops idle connections and waits for in-flight
+// requests to finish. Connections still active when the context ends
+// are closed and the context error is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.closeListener()
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if s.drainHandlers() {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			s.closeHandlers()
+			return multierr.Append(err, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// drainHandlers wakes the idle handlers waiting for the next request up,
+// they stop without reading it. The handler which has read the type of
+// the request is busy and finishes it. Reports whether there are no handlers left.
+func (s *Server) drainHandlers() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for h, idle := range s.handlers {
+		if !idle {
+			continue
+		}
+		if err := h.conn.SetReadDeadline(time.Now()); err != nil && !isClosedError(err) {
+			h.log.Error(err)
+		}
+	}
+
+	return len(s.handlers) == 0
+}
+
+// closeHandlers closes connections of all the handlers.
+func (s *Server) closeHandlers() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for h := range s.handlers {
+		if err := h.conn.Close(); err != nil && !isClosedError(err) {
+			h.log.Error(err)
+		}
+	}
+}
+
+// errServerClosed the connection is accepted after closing the server
+var errServerClosed = errors.New("server closed")
+
+// track registers the handler as idle, the connection
+// must not be served if the server is closed or on the connections limit.
+func (s *Server) track(h *handler) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed.Load() {
+		return errServerClosed
+	}
+	if limit := s.config.MaxConnections; limit > 0 && len(s.handlers) >= limit {
+		return errors.Wrapf(ErrTooManyConnections, "limit %d", limit)
+	}
+	s.handlers[h] = true
+	return nil
+}
+
+func (s *Server) untrack(h *handler) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.handlers, h)
+}
+
+// setBusy marks the handler as processing the request which type is read.
+func (s *Server) setBusy(h *handler) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	h.pending++
+	s.handlers[h] = false
+}
+
+// setAnswered marks the request of the handler as answered, the handler without
+// the pending requests is idle, it is woken up if the server is shutting down.
+func (s *Server) setAnswered(h *handler) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	h.pending--
+	if h.pending > 0 {
+		return
+	}
+	s.handlers[h] = true
+	if !s.closed.Load() {
+		return
+	}
+	if err := h.conn.SetReadDeadline(time.Now()); err != nil && !isClosedError(err) {
+		h.log.Error(err)
+	}
+}
+
+// pending reports whether the handler has the requests not answered yet.
+func (s *Server) pending(h *handler) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return h.pending > 0
+}
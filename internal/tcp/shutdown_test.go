@@ -0,0 +1,194 @@
+package tcp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/baibikov/jellydb/internal/pkg/jell"
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+// slowJelly delays setting to keep the request in-flight
+type slowJelly struct {
+	jell.Jelly
+	delay time.Duration
+}
+
+func (s *slowJelly) Set(string, []byte) error {
+	time.Sleep(s.delay)
+	return nil
+}
+
+func (s *slowJelly) Namespace(string) (jell.Jelly, error) {
+	return s, nil
+}
+
+func runTestServer(t *testing.T, jelly jell.Jelly) *Server {
+	t.Helper()
+	return runTestServerWithConfig(t, &Config{Addr: "127.0.0.1:0"}, jelly)
+}
+
+func runTestServerWithConfig(t *testing.T, config *Config, jelly jell.Jelly) *Server {
+	t.Helper()
+
+	server, err := New(config, jelly)
+	require.NoError(t, err)
+
+	go func() {
+		_ = server.Broadcast(context.Background())
+	}()
+	return server
+}
+
+// dialFramed connects to the server and switches the connection to the framed protocol.
+func dialFramed(addr string) (net.Conn, error) {
+	conn, err := net.Dial(tcpNetwork, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := testHello(conn, protomarshal.VersionFramed)
+	if err == nil && code != statusCodeOK {
+		err = errors.Errorf("hello response code %d", code)
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// testHello sends the hello request of the version, returns the response code.
+func testHello(conn net.Conn, version int) (int32, error) {
+	_, err := conn.Write(append(protomarshal.FormatType(protomarshal.HelloMessageType), byte(version)))
+	if err != nil {
+		return 0, err
+	}
+
+	resp := &messages.Response{}
+	err = protomarshal.NewEncoder(conn, 1024).Encode(resp)
+	return resp.GetCode(), err
+}
+
+func TestServer_ShutdownIdle(t *testing.T) {
+	server := runTestServer(t, &slowJelly{})
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// wait for the connection to be served
+	require.Eventually(t, func() bool {
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		return len(server.handlers) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, server.Shutdown(ctx))
+
+	_, err = conn.Read(make([]byte, 1))
+	require.Error(t, err)
+
+	_, err = net.Dial(tcpNetwork, server.listener.Addr().String())
+	require.Error(t, err)
+}
+
+func TestServer_ShutdownInFlight(t *testing.T) {
+	server := runTestServer(t, &slowJelly{delay: 200 * time.Millisecond})
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("1"))
+	require.NoError(t, err)
+	err = protomarshal.NewDecoder(conn).Decode(&messages.SetRequest{Key: "key", Message: []byte("message")})
+	require.NoError(t, err)
+
+	// wait for the request to be in-flight
+	require.Eventually(t, func() bool {
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		for _, idle := range server.handlers {
+			return !idle
+		}
+		return false
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, server.Shutdown(ctx))
+
+	// the in-flight request is answered before the connection is closed
+	resp := &messages.Response{}
+	err = protomarshal.NewEncoder(conn, 256).Encode(resp)
+	require.NoError(t, err)
+	require.EqualValues(t, statusCodeOK, resp.GetCode())
+}
+
+func TestServer_ShutdownDeadline(t *testing.T) {
+	server := runTestServer(t, &slowJelly{delay: time.Second})
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("1"))
+	require.NoError(t, err)
+	err = protomarshal.NewDecoder(conn).Decode(&messages.SetRequest{Key: "key", Message: []byte("message")})
+	require.NoError(t, err)
+
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, server.Shutdown(ctx), context.DeadlineExceeded)
+}
+
+func TestServer_ShutdownStartedRequest(t *testing.T) {
+	server := runTestServer(t, &slowJelly{})
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the type of the request is read, the rest is not sent yet
+	_, err = conn.Write([]byte("1"))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		for _, idle := range server.handlers {
+			return !idle
+		}
+		return false
+	}, time.Second, time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		done <- server.Shutdown(ctx)
+	}()
+	time.Sleep(2 * shutdownPollInterval)
+
+	// the started request is served before the connection is closed
+	err = protomarshal.NewDecoder(conn).Decode(&messages.SetRequest{Key: "key", Message: []byte("message")})
+	require.NoError(t, err)
+	resp := &messages.Response{}
+	err = protomarshal.NewEncoder(conn, 256).Encode(resp)
+	require.NoError(t, err)
+	require.EqualValues(t, statusCodeOK, resp.GetCode())
+	require.NoError(t, <-done)
+
+	_, err = conn.Read(make([]byte, 1))
+	require.Error(t, err)
+}
@@ -0,0 +1,46 @@
+package tcp
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/internal/auth"
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+func (h *handler) stat() (err error) {
+	req := &messages.StatRequest{}
+	err = protomarshal.NewEncoder(h.request, statMessageSize).Encode(req)
+	if err != nil {
+		return errors.Wrap(err, "get 'stat' state")
+	}
+	h.key = req.GetKey()
+
+	jelly, err := h.resolve(req.GetNamespace(), auth.PermissionRead, req.GetKey())
+	if err != nil {
+		return errors.Wrap(h.response(err), "send response message")
+	}
+
+	stats, err := jelly.Stats(req.GetKey())
+	if err != nil {
+		return errors.Wrap(h.response(err), "send response message")
+	}
+
+	err = h.response(nil)
+	if err != nil {
+		return errors.Wrap(err, "send response message")
+	}
+
+	err = protomarshal.NewDecoder(h).Decode(&messages.StatResponse{
+		Total:           stats.Total,
+		Uncommitted:     stats.Uncommitted,
+		CommittedOffset: stats.CommittedOffset,
+		WrittenOffset:   stats.WrittenOffset,
+		MemoryBytes:     stats.MemoryBytes,
+		DiskBytes:       stats.DiskBytes,
+		OldestAgeMs:     stats.OldestAge.Milliseconds(),
+		Partitions:      int32(stats.Partitions),
+		Priorities:      int32(stats.Priorities),
+	})
+	return errors.Wrap(err, "write stats response")
+}
@@ -0,0 +1,85 @@
+package tcp
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+func TestServer_ReadTimeout(t *testing.T) {
+	server := runTestServerWithConfig(t, &Config{
+		Addr:        "127.0.0.1:0",
+		ReadTimeout: 100 * time.Millisecond,
+	}, &slowJelly{})
+	defer server.Close()
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the frame header promises 16 bytes, but only the ping and the header are sent
+	header := make([]byte, 4)
+	binary.LittleEndian.PutUint32(header, 16)
+	_, err = conn.Write(append([]byte("1"), header...))
+	require.NoError(t, err)
+
+	resp := &messages.Response{}
+	err = protomarshal.NewEncoder(conn, 1024).Encode(resp)
+	require.NoError(t, err)
+	require.EqualValues(t, StatusCodeTimeout, resp.GetCode())
+
+	// the stalled connection is closed
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err = conn.Read(make([]byte, 1))
+	require.Error(t, err)
+	require.NotErrorIs(t, err, os.ErrDeadlineExceeded)
+}
+
+func TestServer_IdleTimeout(t *testing.T) {
+	server := runTestServerWithConfig(t, &Config{
+		Addr:        "127.0.0.1:0",
+		IdleTimeout: 100 * time.Millisecond,
+	}, &slowJelly{})
+	defer server.Close()
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err = conn.Read(make([]byte, 1))
+	require.Error(t, err)
+	require.NotErrorIs(t, err, os.ErrDeadlineExceeded)
+}
+
+func TestServer_Requests(t *testing.T) {
+	server := runTestServerWithConfig(t, &Config{
+		Addr:        "127.0.0.1:0",
+		ReadTimeout: time.Second,
+		IdleTimeout: time.Second,
+	}, &slowJelly{})
+	defer server.Close()
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// requests keep the connection alive
+	for i := 0; i < 3; i++ {
+		_, err = conn.Write([]byte("1"))
+		require.NoError(t, err)
+		err = protomarshal.NewDecoder(conn).Decode(&messages.SetRequest{Key: "key", Message: []byte("message")})
+		require.NoError(t, err)
+
+		resp := &messages.Response{}
+		err = protomarshal.NewEncoder(conn, 1024).Encode(resp)
+		require.NoError(t, err)
+		require.EqualValues(t, statusCodeOK, resp.GetCode())
+	}
+}
@@ -0,0 +1,103 @@
+package tcp
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/baibikov/jellydb/internal/auth"
+	"github.com/baibikov/jellydb/internal/pkg/jell"
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+// transaction the transaction open by the connection, the sets and the commits
+// of its namespace join it until the end
+type transaction struct {
+	id        string
+	namespace string
+	jelly     jell.Jelly
+}
+
+// begin begins the transaction of the connection.
+func (h *handler) begin() (err error) {
+	req := &messages.BeginRequest{}
+	err = protomarshal.NewEncoder(h.request, beginMessageSize).Encode(req)
+	if err != nil {
+		return errors.Wrap(err, "get 'begin' state")
+	}
+
+	h.scope(req.GetNamespace())
+	err = h.authorizeNamespace(auth.PermissionWrite)
+	if err != nil {
+		return errors.Wrap(h.response(err), "send response message")
+	}
+	jelly, err := h.jelly.Namespace(h.ns)
+	if err != nil {
+		return errors.Wrap(h.response(err), "send response message")
+	}
+	if h.tx != nil {
+		return errors.Wrap(h.response(errors.Errorf("transaction %s is open", h.tx.id)), "send response message")
+	}
+
+	id, err := jelly.Begin()
+	if err != nil {
+		return errors.Wrap(h.response(err), "send response message")
+	}
+	h.tx = &transaction{id: id, namespace: h.ns, jelly: jelly}
+
+	err = h.response(nil)
+	if err != nil {
+		return errors.Wrap(err, "send response message")
+	}
+
+	err = protomarshal.NewDecoder(h).Decode(&messages.BeginResponse{
+		Transaction: id,
+	})
+	return errors.Wrap(err, "write begin response")
+}
+
+// end ends or aborts the transaction of the connection.
+func (h *handler) end() (err error) {
+	req := &messages.EndRequest{}
+	err = protomarshal.NewEncoder(h.request, endMessageSize).Encode(req)
+	if err != nil {
+		return errors.Wrap(err, "get 'end' state")
+	}
+	if h.tx == nil {
+		return errors.Wrap(h.response(errors.New("no open transaction")), "send response message")
+	}
+
+	tx := h.tx
+	h.tx, h.ns = nil, tx.namespace
+	if req.GetAbort() {
+		err = tx.jelly.Abort(tx.id)
+	} else {
+		err = tx.jelly.End(tx.id)
+	}
+
+	err = h.response(err)
+	return errors.Wrap(err, "send response message")
+}
+
+// transaction the transaction the request of the namespace joins, nil if there is none.
+func (h *handler) transaction() (*transaction, error) {
+	if h.tx == nil {
+		return nil, nil
+	}
+	if h.tx.namespace != h.ns {
+		return nil, errors.Errorf("transaction %s is open in namespace %q", h.tx.id, h.tx.namespace)
+	}
+	return h.tx, nil
+}
+
+// abort aborts the transaction left open by the closed connection.
+func (h *handler) abort() {
+	if h.tx == nil {
+		return
+	}
+
+	err := h.tx.jelly.Abort(h.tx.id)
+	if err != nil {
+		h.log.Error(errors.Wrap(err, "abort transaction"))
+	}
+	h.tx = nil
+}
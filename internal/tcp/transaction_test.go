@@ -0,0 +1,68 @@
+package tcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/baibikov/jellydb/internal/pkg/jellystore"
+	"github.com/baibikov/jellydb/pkg/protomarshal"
+	"github.com/baibikov/jellydb/protogenerated/messages"
+)
+
+func TestServer_Transaction(t *testing.T) {
+	store, err := jellystore.New(&jellystore.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+	require.NoError(t, store.Set("input", []byte("input-1")))
+	require.NoError(t, store.Set("input", []byte("input-2")))
+
+	server := runTestServerWithConfig(t, &Config{Addr: "127.0.0.1:0"}, store)
+	defer server.Close()
+
+	conn, err := dialFramed(server.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.EqualValues(t, StatusCodeBad, testRequest(t, conn, "d", &messages.EndRequest{}))
+
+	// the begin and end request types are sent as the base 36 digits
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "c", &messages.BeginRequest{}))
+	resp := &messages.BeginResponse{}
+	require.NoError(t, protomarshal.NewEncoder(conn, 1024).Encode(resp))
+	require.NotEmpty(t, resp.GetTransaction())
+	require.EqualValues(t, StatusCodeBad, testRequest(t, conn, "c", &messages.BeginRequest{}))
+
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "1", &messages.SetRequest{Key: "output", Message: []byte("output-1")}))
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "3", &messages.CommitRequest{Key: "input", N: 1}))
+	require.EqualValues(t, StatusCodeBad, testRequest(t, conn, "1", &messages.SetRequest{
+		Key:       "output",
+		Message:   []byte("output-2"),
+		Namespace: "other",
+	}))
+
+	// the transaction is not seen by the others until it ends
+	stats, err := store.Stats("input")
+	require.NoError(t, err)
+	require.EqualValues(t, 2, stats.Uncommitted)
+	_, err = store.Get("output", 1)
+	require.Error(t, err)
+
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "d", &messages.EndRequest{}))
+	stats, err = store.Stats("input")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, stats.Uncommitted)
+	bb, err := store.Get("output", 10)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("output-1")}, bb)
+
+	// the aborted transaction is dropped, the requests don't join the ended one
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "c", &messages.BeginRequest{}))
+	require.NoError(t, protomarshal.NewEncoder(conn, 1024).Encode(resp))
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "1", &messages.SetRequest{Key: "output", Message: []byte("aborted")}))
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "d", &messages.EndRequest{Abort: true}))
+	require.EqualValues(t, statusCodeOK, testRequest(t, conn, "1", &messages.SetRequest{Key: "output", Message: []byte("output-2")}))
+
+	bb, err = store.Get("output", 10)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("output-1"), []byte("output-2")}, bb)
+}
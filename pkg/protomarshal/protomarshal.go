@@ -1,30 +1,92 @@
+// Package protomarshal reads and writes the proto messages of the jellydb protocol
+// as frames: the length of the message (4 bytes, little endian) and the message itself.
+// The bare messages of the legacy protocol without the length prefix are read by ReadBare.
 package protomarshal
 
 import (
+	"encoding/binary"
 	"io"
 
 	"github.com/pkg/errors"
 	"google.golang.org/protobuf/proto"
 )
 
+// FrameHeaderSize every message is written as a frame:
+// the message length (4 bytes, little endian) and the proto message itself.
+const FrameHeaderSize = 4
+
+// the versions of the protocol, the connection speaks the legacy one
+// until the client switches it by the hello request
+const (
+	// VersionLegacy the bare proto messages, a request message is read by one read
+	VersionLegacy = 1
+	// VersionFramed the proto messages written as the frames
+	VersionFramed = 2
+)
+
+// MaxResponseSize max size of the response messages of the server,
+// the clients read the responses not larger than it.
+const MaxResponseSize = 1 << 20
+
+// ErrFrameTooLarge the frame is larger than the allowed size,
+// the rest of the frame is left unread in the reader.
+var ErrFrameTooLarge = errors.New("frame is larger than allowed")
+
 type Encoder struct {
 	size int
 	r    io.Reader
 }
 
 func (e *Encoder) Encode(m proto.Message) error {
-	bb := make([]byte, e.size)
-	n, err := e.r.Read(bb)
+	frame, err := ReadFrame(e.r, e.size)
 	if err != nil {
-		return errors.Wrap(err, "read message by reader")
+		return err
+	}
+
+	return errors.Wrapf(proto.Unmarshal(frame[FrameHeaderSize:], m), "encode message by proto with size %d", e.size)
+}
+
+// ReadFrame reads the whole frame (with the header) with the message
+// not larger than size, the frame can be encoded later by the Encoder.
+func ReadFrame(r io.Reader, size int) ([]byte, error) {
+	header := make([]byte, FrameHeaderSize)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, errors.Wrap(err, "read frame header by reader")
+	}
+
+	length := binary.LittleEndian.Uint32(header)
+	if int64(length) > int64(size) {
+		return nil, errors.Wrapf(ErrFrameTooLarge, "frame size %d, allowed %d", length, size)
+	}
+
+	frame := make([]byte, FrameHeaderSize+int(length))
+	copy(frame, header)
+	_, err = io.ReadFull(r, frame[FrameHeaderSize:])
+	if err != nil {
+		return nil, errors.Wrap(err, "read message by reader")
+	}
+
+	return frame, nil
+}
+
+// ReadBare reads the bare message of the legacy protocol not larger than size by one read,
+// the message is returned as the frame to be encoded by the Encoder.
+func ReadBare(r io.Reader, size int) ([]byte, error) {
+	frame := make([]byte, FrameHeaderSize+size)
+	n, err := r.Read(frame[FrameHeaderSize:])
+	if err != nil {
+		return nil, errors.Wrap(err, "read message by reader")
 	}
 	if n == 0 {
-		return errors.New("empty message from reader")
+		return nil, errors.New("empty message from reader")
 	}
 
-	return errors.Wrapf(proto.Unmarshal(bb[:n], m), "encode message by proto with size %d", e.size)
+	binary.LittleEndian.PutUint32(frame, uint32(n))
+	return frame[:FrameHeaderSize+n], nil
 }
 
+// NewEncoder reads a frame not larger than size from the reader.
 func NewEncoder(reader io.Reader, size int) *Encoder { return &Encoder{r: reader, size: size} }
 
 type Decoder struct {
@@ -36,15 +98,13 @@ func (d *Decoder) Decode(m proto.Message) error {
 	if err != nil {
 		return errors.Wrap(err, "decode message by proto")
 	}
-	n, err := d.w.Write(bb)
-	if err != nil {
-		return errors.Wrap(err, "write message by writer")
-	}
-	if n == 0 {
-		return errors.New("empty message write")
-	}
 
-	return nil
+	frame := make([]byte, FrameHeaderSize, FrameHeaderSize+len(bb))
+	binary.LittleEndian.PutUint32(frame, uint32(len(bb)))
+	frame = append(frame, bb...)
+
+	_, err = d.w.Write(frame)
+	return errors.Wrap(err, "write message by writer")
 }
 
 func NewDecoder(writer io.Writer) *Decoder { return &Decoder{w: writer} }
@@ -0,0 +1,46 @@
+package protomarshal
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// the message types of the requests, every request is the type
+// of the request followed by the request message
+const (
+	SetMessageType = iota + 1
+	GetMessageType
+	CommitMessageType
+	ListMessageType
+	DeleteMessageType
+	PurgeMessageType
+	StatMessageType
+	AuthMessageType
+	UseMessageType
+	// the types over 9 are sent as the base 36 digits: 'a' is 10
+	PartitionMessageType
+	SetBatchMessageType
+	BeginMessageType
+	EndMessageType
+	DedupMessageType
+)
+
+// HelloMessageType the request switching the protocol of the connection, the type is
+// followed by one byte of the version and answered by the response of the version
+const HelloMessageType = 0
+
+// FormatType the message type sent before the request, one base 36 digit.
+func FormatType(typ int) []byte {
+	return []byte(strconv.FormatInt(int64(typ), 36))
+}
+
+// ParseType parses the message type sent before the request.
+func ParseType(bb []byte) (int, error) {
+	typ, err := strconv.ParseInt(string(bb), 36, 0)
+	if err != nil {
+		return 0, errors.Wrapf(err, "string unfolding - %s", bb)
+	}
+
+	return int(typ), nil
+}
@@ -0,0 +1,183 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+
+	certFile string
+	keyFile  string
+}
+
+// generateCert generates the certificate signed by the parent, nil parent makes the self-signed CA.
+func generateCert(t *testing.T, dir, name string, parent *testCert, usage x509.ExtKeyUsage) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	tc := &testCert{
+		cert:     cert,
+		key:      key,
+		certFile: filepath.Join(dir, name+".crt"),
+		keyFile:  filepath.Join(dir, name+".key"),
+	}
+	err = os.WriteFile(tc.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	require.NoError(t, err)
+	err = os.WriteFile(tc.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600)
+	require.NoError(t, err)
+
+	return tc
+}
+
+// handshake runs the tls handshake of the client and the server configs.
+func handshake(t *testing.T, server, client *tls.Config) error {
+	t.Helper()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer conn.Close()
+		serverErr <- conn.(*tls.Conn).Handshake()
+	}()
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), client)
+	if err != nil {
+		<-serverErr
+		return err
+	}
+	defer conn.Close()
+
+	// the server verifies the client certificate after the client handshake is done
+	return <-serverErr
+}
+
+func TestTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := generateCert(t, dir, "ca", nil, 0)
+	server := generateCert(t, dir, "server", ca, x509.ExtKeyUsageServerAuth)
+	client := generateCert(t, dir, "client", ca, x509.ExtKeyUsageClientAuth)
+	otherCA := generateCert(t, dir, "other-ca", nil, 0)
+	other := generateCert(t, dir, "other", otherCA, x509.ExtKeyUsageClientAuth)
+
+	tests := []struct {
+		Name       string
+		ClientCA   string
+		CA         string
+		ClientCert *testCert
+		WantErr    bool
+	}{
+		{
+			Name: "tls",
+			CA:   ca.certFile,
+		},
+		{
+			Name:    "unknown-server",
+			CA:      otherCA.certFile,
+			WantErr: true,
+		},
+		{
+			Name:       "mtls",
+			ClientCA:   ca.certFile,
+			CA:         ca.certFile,
+			ClientCert: client,
+		},
+		{
+			Name:     "mtls-no-client-cert",
+			ClientCA: ca.certFile,
+			CA:       ca.certFile,
+			WantErr:  true,
+		},
+		{
+			Name:       "mtls-unknown-client",
+			ClientCA:   ca.certFile,
+			CA:         ca.certFile,
+			ClientCert: other,
+			WantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			serverConfig, err := Server(server.certFile, server.keyFile, tt.ClientCA)
+			require.NoError(t, err)
+
+			certFile, keyFile := "", ""
+			if tt.ClientCert != nil {
+				certFile, keyFile = tt.ClientCert.certFile, tt.ClientCert.keyFile
+			}
+			clientConfig, err := Client(tt.CA, certFile, keyFile, "")
+			require.NoError(t, err)
+
+			err = handshake(t, serverConfig, clientConfig)
+			if tt.WantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	_, err := Server("", "", "")
+	require.Error(t, err)
+
+	_, err = Client("", "client.crt", "", "")
+	require.Error(t, err)
+
+	_, err = Client(filepath.Join(t.TempDir(), "undefined.crt"), "", "", "")
+	require.Error(t, err)
+}
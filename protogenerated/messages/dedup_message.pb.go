@@ -0,0 +1,232 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.27.1
+// 	protoc        v3.17.3
+// source: api/proto/dedup_message.proto
+
+package messages
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type DedupRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	// dedup window of the key in milliseconds to set, zero switches the deduplication off,
+	// no window only gets the dedup window of the key
+	WindowMs *int64 `protobuf:"varint,2,opt,name=window_ms,json=windowMs,proto3,oneof" json:"window_ms,omitempty"`
+	// namespace of the key, empty uses the namespace of the connection
+	Namespace string `protobuf:"bytes,3,opt,name=namespace,proto3" json:"namespace,omitempty"`
+}
+
+func (x *DedupRequest) Reset() {
+	*x = DedupRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_proto_dedup_message_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DedupRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DedupRequest) ProtoMessage() {}
+
+func (x *DedupRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_dedup_message_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DedupRequest.ProtoReflect.Descriptor instead.
+func (*DedupRequest) Descriptor() ([]byte, []int) {
+	return file_api_proto_dedup_message_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *DedupRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *DedupRequest) GetWindowMs() int64 {
+	if x != nil && x.WindowMs != nil {
+		return *x.WindowMs
+	}
+	return 0
+}
+
+func (x *DedupRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+type DedupResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// dedup window of the key in milliseconds, zero for the key not deduplicated
+	WindowMs int64 `protobuf:"varint,1,opt,name=window_ms,json=windowMs,proto3" json:"window_ms,omitempty"`
+}
+
+func (x *DedupResponse) Reset() {
+	*x = DedupResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_proto_dedup_message_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DedupResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DedupResponse) ProtoMessage() {}
+
+func (x *DedupResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_proto_dedup_message_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DedupResponse.ProtoReflect.Descriptor instead.
+func (*DedupResponse) Descriptor() ([]byte, []int) {
+	return file_api_proto_dedup_message_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *DedupResponse) GetWindowMs() int64 {
+	if x != nil {
+		return x.WindowMs
+	}
+	return 0
+}
+
+var File_api_proto_dedup_message_proto protoreflect.FileDescriptor
+
+var file_api_proto_dedup_message_proto_rawDesc = []byte{
+	0x0a, 0x1d, 0x61, 0x70, 0x69, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x64, 0x65, 0x64, 0x75,
+	0x70, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12,
+	0x09, 0x67, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x64, 0x22, 0x6e, 0x0a, 0x0c, 0x44, 0x65,
+	0x64, 0x75, 0x70, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65,
+	0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x20, 0x0a, 0x09,
+	0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x5f, 0x6d, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x48,
+	0x00, 0x52, 0x08, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x4d, 0x73, 0x88, 0x01, 0x01, 0x12, 0x1c,
+	0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x42, 0x0c, 0x0a, 0x0a,
+	0x5f, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x5f, 0x6d, 0x73, 0x22, 0x2c, 0x0a, 0x0d, 0x44, 0x65,
+	0x64, 0x75, 0x70, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x77,
+	0x69, 0x6e, 0x64, 0x6f, 0x77, 0x5f, 0x6d, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08,
+	0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x4d, 0x73, 0x42, 0x19, 0x5a, 0x17, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x67, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x64, 0x2f, 0x6d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x73, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_api_proto_dedup_message_proto_rawDescOnce sync.Once
+	file_api_proto_dedup_message_proto_rawDescData = file_api_proto_dedup_message_proto_rawDesc
+)
+
+func file_api_proto_dedup_message_proto_rawDescGZIP() []byte {
+	file_api_proto_dedup_message_proto_rawDescOnce.Do(func() {
+		file_api_proto_dedup_message_proto_rawDescData = protoimpl.X.CompressGZIP(file_api_proto_dedup_message_proto_rawDescData)
+	})
+	return file_api_proto_dedup_message_proto_rawDescData
+}
+
+var file_api_proto_dedup_message_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_api_proto_dedup_message_proto_goTypes = []interface{}{
+	(*DedupRequest)(nil),  // 0: generated.DedupRequest
+	(*DedupResponse)(nil), // 1: generated.DedupResponse
+}
+var file_api_proto_dedup_message_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_api_proto_dedup_message_proto_init() }
+func file_api_proto_dedup_message_proto_init() {
+	if File_api_proto_dedup_message_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_api_proto_dedup_message_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DedupRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_proto_dedup_message_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DedupResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_api_proto_dedup_message_proto_msgTypes[0].OneofWrappers = []interface{}{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_api_proto_dedup_message_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_api_proto_dedup_message_proto_goTypes,
+		DependencyIndexes: file_api_proto_dedup_message_proto_depIdxs,
+		MessageInfos:      file_api_proto_dedup_message_proto_msgTypes,
+	}.Build()
+	File_api_proto_dedup_message_proto = out.File
+	file_api_proto_dedup_message_proto_rawDesc = nil
+	file_api_proto_dedup_message_proto_goTypes = nil
+	file_api_proto_dedup_message_proto_depIdxs = nil
+}